@@ -0,0 +1,60 @@
+// Package backend 定义链码执行方式和调用身份, 由 gateway、simulator 和 sdk 共用。
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+)
+
+// 调用身份配置, 各种后端共用
+type Identity struct {
+	MSPID string `json:"msp_id"`
+	// peer 后端使用的 MSP 目录
+	MSPConfigPath string `json:"msp_path,omitempty"`
+	// mock 后端写入证书的属性, 例如 role
+	Attributes map[string]string `json:"attrs,omitempty"`
+	// 网关认证凭证: bearer token 的 sha256 十六进制摘要, 或 mTLS 客户端证书的 CN
+	TokenSHA256 string `json:"token_sha256,omitempty"`
+	ClientCN    string `json:"client_cn,omitempty"`
+}
+
+// 链码执行方式
+type Backend interface {
+	// 只读查询, 不提交交易
+	Query(identity, function string, args []string) ([]byte, error)
+	// 提交交易
+	Invoke(identity, function string, args []string) ([]byte, error)
+}
+
+var ErrUnknownIdentity = errors.New("unknown identity")
+
+// 链码返回的错误, 与网络或后端本身的错误区分
+type ChaincodeError struct {
+	Status  int32
+	Message string
+	// 链码返回的结构化错误, 包含错误码
+	Detail *food.Error
+}
+
+// 解析链码写在 message 中的结构化错误
+func NewChaincodeError(status int32, message string) *ChaincodeError {
+	ccErr := &ChaincodeError{Status: status, Message: message}
+	detail := new(food.Error)
+	if err := json.Unmarshal([]byte(message), detail); err == nil && detail.Code != "" {
+		ccErr.Message = detail.Message
+		ccErr.Detail = detail
+	}
+
+	return ccErr
+}
+
+func (e *ChaincodeError) Error() string {
+	if e.Detail != nil {
+		return fmt.Sprintf("chaincode error (%d %s): %s", e.Status, e.Detail.Code, e.Message)
+	}
+
+	return fmt.Sprintf("chaincode error (%d): %s", e.Status, e.Message)
+}
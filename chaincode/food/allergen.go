@@ -0,0 +1,154 @@
+package food
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// 食品过敏原标签
+type AllergenLabel struct {
+	FoodId    string              `json:"food_id"`
+	FoodName  string              `json:"food_name"`
+	Contains  []string            `json:"contains"`
+	FreeFrom  []string            `json:"free_from"`
+	Sources   map[string][]string `json:"sources"`
+	LabelText string              `json:"label_text"`
+}
+
+// 解析逗号分隔的过敏原列表, 统一为小写并去重排序
+func parseAllergens(raw string) []string {
+	allergens := make([]string, 0)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		allergens = append(allergens, item)
+	}
+
+	return mergeAllergens(nil, allergens)
+}
+
+// 过敏原并集
+func mergeAllergens(current []string, added []string) []string {
+	set := make(map[string]bool)
+	for _, a := range current {
+		set[a] = true
+	}
+	for _, a := range added {
+		set[a] = true
+	}
+
+	merged := make([]string, 0, len(set))
+	for a := range set {
+		merged = append(merged, a)
+	}
+	sort.Strings(merged)
+
+	return merged
+}
+
+// 原料中含有、但食品声明不含的过敏原
+func allergenConflicts(food *Food, allergens []string) []string {
+	conflicts := make([]string, 0)
+	for _, free := range food.AllergenFree {
+		for _, a := range allergens {
+			if a == free {
+				conflicts = append(conflicts, a)
+				break
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// 食品过敏原查询
+func (c *IngredientsExchangeCC) queryFoodAllergens(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	foodId := args[0]
+	if foodId == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	foodBytes, err := stub.GetState(constructFoodKey(foodId))
+	if err != nil || len(foodBytes) == 0 {
+		return errorResponse(notFound("food", foodId))
+	}
+
+	food := new(Food)
+	if err := unmarshalDoc(foodBytes, food); err != nil {
+		return errorResponse(fmt.Errorf("unmarshal food error: %s", err))
+	}
+
+	// 逐个食材追溯过敏原来源
+	sources := make(map[string][]string)
+	for _, ingredientId := range food.Ingredients {
+		ingredientBytes, err := stub.GetState(constructIngredientKey(ingredientId))
+		if err != nil || len(ingredientBytes) == 0 {
+			continue
+		}
+
+		ingredient := new(Ingredient)
+		if err := unmarshalDoc(ingredientBytes, ingredient); err != nil {
+			return errorResponse(fmt.Errorf("unmarshal ingredient error: %s", err))
+		}
+		for _, a := range ingredient.Allergens {
+			sources[a] = append(sources[a], ingredientId)
+		}
+	}
+	for _, subFoodId := range food.Foods {
+		subFoodBytes, err := stub.GetState(constructFoodKey(subFoodId))
+		if err != nil || len(subFoodBytes) == 0 {
+			continue
+		}
+
+		subFood := new(Food)
+		if err := unmarshalDoc(subFoodBytes, subFood); err != nil {
+			return errorResponse(fmt.Errorf("unmarshal food error: %s", err))
+		}
+		for _, a := range subFood.Allergens {
+			sources[a] = append(sources[a], subFoodId)
+		}
+	}
+
+	label := &AllergenLabel{
+		FoodId:   food.Id,
+		FoodName: food.Name,
+		Contains: mergeAllergens(nil, food.Allergens),
+		FreeFrom: mergeAllergens(nil, food.AllergenFree),
+		Sources:  sources,
+	}
+	label.LabelText = formatAllergenLabel(label)
+
+	labelBytes, err := json.Marshal(label)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(labelBytes)
+}
+
+// 生成可直接印在标签上的过敏原文字
+func formatAllergenLabel(label *AllergenLabel) string {
+	text := "Contains: none"
+	if len(label.Contains) != 0 {
+		text = fmt.Sprintf("Contains: %s", strings.Join(label.Contains, ", "))
+	}
+	if len(label.FreeFrom) != 0 {
+		text = fmt.Sprintf("%s. Free from: %s", text, strings.Join(label.FreeFrom, ", "))
+	}
+
+	return text
+}
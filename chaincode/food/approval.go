@@ -0,0 +1,481 @@
+package food
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	transferPending  = "pending"
+	transferExecuted = "executed"
+	transferRejected = "rejected"
+	transferExpired  = "expired"
+
+	decisionApprove = "approve"
+	decisionReject  = "reject"
+
+	// 证书中的角色属性, 由 Fabric CA 签发
+	roleAttribute = "role"
+
+	// 资产上待审批的转让: pendingTransfer [kind, assetId] -> transferId
+	pendingTransferType = "pendingTransfer"
+	// 资产的全部转让申请: assetTransfer [kind, assetId, transferId]
+	assetTransferType = "assetTransfer"
+
+	transferEvent = "transfer"
+)
+
+// 审批记录
+type Approval struct {
+	Identity  string `json:"identity"`
+	MSP       string `json:"msp"`
+	Role      string `json:"role"`
+	Decision  string `json:"decision"`
+	Comment   string `json:"comment,omitempty"`
+	TxId      string `json:"tx_id"`
+	Timestamp string `json:"timestamp"`
+}
+
+// 需要多方审批的转让申请
+type Transfer struct {
+	Id             string        `json:"id"`
+	Kind           string        `json:"kind"`
+	AssetId        string        `json:"asset_id"`
+	AssetClass     string        `json:"asset_class"`
+	OwnerId        string        `json:"owner_id"`
+	CurrentOwnerId string        `json:"current_owner_id"`
+	FacilityId     string        `json:"facility_id,omitempty"`
+	Required       int           `json:"required"`
+	Roles          ApprovalRoles `json:"roles"`
+	Status         string        `json:"status"`
+	Approvals      []*Approval   `json:"approvals"`
+	CreatedAt      string        `json:"created_at"`
+	ExpiresAt      string        `json:"expires_at,omitempty"`
+	ClosedAt       string        `json:"closed_at,omitempty"`
+	SchemaVersion  int           `json:"schema_version"`
+}
+
+func constructTransferKey(transferId string) string {
+	return fmt.Sprintf("transfer_%s", transferId)
+}
+
+func getTransfer(stub shim.ChaincodeStubInterface, transferId string) (*Transfer, error) {
+	transferBytes, err := stub.GetState(constructTransferKey(transferId))
+	if err != nil || len(transferBytes) == 0 {
+		return nil, err
+	}
+
+	transfer := new(Transfer)
+	if err := unmarshalDoc(transferBytes, transfer); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// 写入转让申请并发送事件
+func putTransfer(stub shim.ChaincodeStubInterface, transfer *Transfer) error {
+	transferBytes, err := marshalDoc(transfer)
+	if err != nil {
+		return fmt.Errorf("marshal transfer error: %s", err)
+	}
+	if err := stub.PutState(constructTransferKey(transfer.Id), transferBytes); err != nil {
+		return fmt.Errorf("save transfer error: %s", err)
+	}
+	if err := stub.SetEvent(transferEvent, transferBytes); err != nil {
+		return fmt.Errorf("set event error: %s", err)
+	}
+
+	return nil
+}
+
+// 申请是否已过期, 未设置过期时间的申请不会过期
+func (t *Transfer) expired(timestamp string) bool {
+	if t.ExpiresAt == "" {
+		return false
+	}
+
+	return timestamp > t.ExpiresAt
+}
+
+// 已批准的不同身份数
+func (t *Transfer) approvals() int {
+	count := 0
+	for _, approval := range t.Approvals {
+		if approval.Decision == decisionApprove {
+			count++
+		}
+	}
+
+	return count
+}
+
+// 以某个角色批准的身份数
+func (t *Transfer) roleApprovals(role string) int {
+	count := 0
+	for _, approval := range t.Approvals {
+		if approval.Decision == decisionApprove && approval.Role == role {
+			count++
+		}
+	}
+
+	return count
+}
+
+// 批准总数和每个角色的最少批准数都已满足
+func (t *Transfer) approved() bool {
+	if t.approvals() < t.Required {
+		return false
+	}
+	for role, minimum := range t.Roles {
+		if t.roleApprovals(role) < minimum {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 食品所属类别的审批策略, 类别取自食品 metadata 的 asset_class 字段
+func foodApprovalPolicy(stub shim.ChaincodeStubInterface, foodId string) (string, *ApprovalPolicy, error) {
+	food, err := getFood(stub, foodId)
+	if err != nil || food == nil {
+		return "", nil, err
+	}
+
+	class := metadataString(parseFoodMetadata(food.Metadata), "asset_class")
+	if class == "" {
+		return "", nil, nil
+	}
+
+	cfg, err := getConfig(stub)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return class, cfg.ApprovalPolicies[class], nil
+}
+
+// 调用者在申请中的角色: 证书的 role 属性, 或者所属MSP在配置中的角色
+// MSP拥有多个角色时优先计入尚未达到最少批准数的角色
+func approverRole(stub shim.ChaincodeStubInterface, cfg *Config, transfer *Transfer, msp string) string {
+	if attr, ok, err := cid.GetAttributeValue(stub, roleAttribute); err == nil && ok {
+		if _, found := transfer.Roles[attr]; found {
+			return attr
+		}
+	}
+
+	matched := ""
+	for _, role := range transfer.Roles.names() {
+		if !cfg.hasRole(role, msp) {
+			continue
+		}
+		if transfer.roleApprovals(role) < transfer.Roles[role] {
+			return role
+		}
+		if matched == "" {
+			matched = role
+		}
+	}
+
+	return matched
+}
+
+// 资产上仍然有效的待审批转让
+func pendingTransferOf(stub shim.ChaincodeStubInterface, k *assetKind, id, timestamp string) (*Transfer, error) {
+	key, err := stub.CreateCompositeKey(pendingTransferType, []string{k.name, id})
+	if err != nil {
+		return nil, err
+	}
+	transferId, err := stub.GetState(key)
+	if err != nil || len(transferId) == 0 {
+		return nil, err
+	}
+
+	transfer, err := getTransfer(stub, string(transferId))
+	if err != nil || transfer == nil {
+		return nil, err
+	}
+	if transfer.Status != transferPending || transfer.expired(timestamp) {
+		return nil, nil
+	}
+
+	return transfer, nil
+}
+
+// 发起需要审批的转让, 校验与直接转让相同, 但不修改拥有者
+func proposeTransfer(stub shim.ChaincodeStubInterface, k *assetKind, class string, policy *ApprovalPolicy, ownerId, id, currentOwnerId, facilityId string) (*Transfer, error) {
+	if _, _, err := checkExchangeAsset(stub, k, ownerId, id, currentOwnerId, facilityId); err != nil {
+		return nil, err
+	}
+
+	createdAt, err := txTimestamp(stub)
+	if err != nil {
+		return nil, fmt.Errorf("get tx timestamp error: %s", err)
+	}
+
+	pending, err := pendingTransferOf(stub, k, id, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("query transfer error: %s", err)
+	}
+	if pending != nil {
+		return nil, newError(CodeConflict, "transfer pending: %s", pending.Id).withEntity(id)
+	}
+
+	cfg, err := getConfig(stub)
+	if err != nil {
+		return nil, fmt.Errorf("get config error: %s", err)
+	}
+
+	transfer := &Transfer{
+		Id:             stub.GetTxID(),
+		Kind:           k.name,
+		AssetId:        id,
+		AssetClass:     class,
+		OwnerId:        ownerId,
+		CurrentOwnerId: currentOwnerId,
+		FacilityId:     facilityId,
+		Required:       policy.Required,
+		Roles:          policy.Roles,
+		Status:         transferPending,
+		Approvals:      make([]*Approval, 0),
+		CreatedAt:      createdAt,
+	}
+	if cfg.TransferExpirySeconds > 0 {
+		created, err := time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			return nil, err
+		}
+		transfer.ExpiresAt = created.Add(time.Duration(cfg.TransferExpirySeconds) * time.Second).Format(time.RFC3339)
+	}
+
+	//写入状态
+	if err := putTransfer(stub, transfer); err != nil {
+		return nil, err
+	}
+
+	pendingKey, err := stub.CreateCompositeKey(pendingTransferType, []string{k.name, id})
+	if err != nil {
+		return nil, fmt.Errorf("create key error: %s", err)
+	}
+	if err := stub.PutState(pendingKey, []byte(transfer.Id)); err != nil {
+		return nil, fmt.Errorf("save transfer index error: %s", err)
+	}
+
+	assetKey, err := stub.CreateCompositeKey(assetTransferType, []string{k.name, id, transfer.Id})
+	if err != nil {
+		return nil, fmt.Errorf("create key error: %s", err)
+	}
+	if err := stub.PutState(assetKey, []byte(transfer.Id)); err != nil {
+		return nil, fmt.Errorf("save transfer index error: %s", err)
+	}
+
+	return transfer, nil
+}
+
+// 关闭转让申请, 释放资产上的待审批标记
+func closeTransfer(stub shim.ChaincodeStubInterface, transfer *Transfer, status, timestamp string) error {
+	transfer.Status = status
+	transfer.ClosedAt = timestamp
+
+	pendingKey, err := stub.CreateCompositeKey(pendingTransferType, []string{transfer.Kind, transfer.AssetId})
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+	if err := stub.DelState(pendingKey); err != nil {
+		return fmt.Errorf("delete transfer index error: %s", err)
+	}
+
+	return putTransfer(stub, transfer)
+}
+
+// 审批转让申请
+// 参数: transferId, decision(approve|reject), 可选的备注
+// 批准总数和各角色的批准数达到策略要求时立即执行转让, 任一审批人拒绝则申请关闭
+func (c *IngredientsExchangeCC) transferApprove(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 2, 3); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	transferId := args[0]
+	decision := args[1]
+	if transferId == "" || (decision != decisionApprove && decision != decisionReject) {
+		return errorResponse(invalidArgs())
+	}
+	comment := ""
+	if len(args) == 3 {
+		comment = args[2]
+	}
+
+	//验证数据是否存在
+	transfer, err := getTransfer(stub, transferId)
+	if err != nil || transfer == nil {
+		return errorResponse(notFound("transfer", transferId))
+	}
+	if transfer.Status != transferPending {
+		return errorResponse(newError(CodeConflict, "transfer %s", transfer.Status).withEntity(transferId))
+	}
+
+	timestamp, err := txTimestamp(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get tx timestamp error: %s", err))
+	}
+	if transfer.expired(timestamp) {
+		return errorResponse(newError(CodeFailedPrecondition, "transfer expired").withEntity(transferId))
+	}
+
+	// 校验审批人
+	cfg, err := getConfig(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get config error: %s", err))
+	}
+	msp, err := clientMSPID(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get client identity error: %s", err))
+	}
+	identity, err := cid.GetID(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get client identity error: %s", err))
+	}
+	role := approverRole(stub, cfg, transfer, msp)
+	if role == "" {
+		return errorResponse(newError(CodeForbidden, "forbidden: %s is not an approver", msp))
+	}
+	for _, approval := range transfer.Approvals {
+		if approval.Identity == identity {
+			return errorResponse(newError(CodeConflict, "already approved").withEntity(transferId))
+		}
+	}
+
+	//写入状态
+	transfer.Approvals = append(transfer.Approvals, &Approval{
+		Identity:  identity,
+		MSP:       msp,
+		Role:      role,
+		Decision:  decision,
+		Comment:   comment,
+		TxId:      stub.GetTxID(),
+		Timestamp: timestamp,
+	})
+
+	switch {
+	case decision == decisionReject:
+		err = closeTransfer(stub, transfer, transferRejected, timestamp)
+	case transfer.approved():
+		k := lookupAssetKind(transfer.Kind)
+		if k == nil {
+			return errorResponse(fmt.Errorf("unknown asset kind %s", transfer.Kind))
+		}
+		if err := exchangeAsset(stub, k, transfer.OwnerId, transfer.AssetId, transfer.CurrentOwnerId, transfer.FacilityId); err != nil {
+			return errorResponse(err)
+		}
+		err = closeTransfer(stub, transfer, transferExecuted, timestamp)
+	default:
+		err = putTransfer(stub, transfer)
+	}
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	transferBytes, err := json.Marshal(transfer)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(transferBytes)
+}
+
+// 转让申请查询, 过期未处理的申请按 expired 返回
+func (c *IngredientsExchangeCC) queryTransfer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	transferId := args[0]
+	if transferId == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	transfer, err := getTransfer(stub, transferId)
+	if err != nil || transfer == nil {
+		return errorResponse(notFound("transfer", transferId))
+	}
+
+	timestamp, err := txTimestamp(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get tx timestamp error: %s", err))
+	}
+	if transfer.Status == transferPending && transfer.expired(timestamp) {
+		transfer.Status = transferExpired
+	}
+
+	transferBytes, err := json.Marshal(transfer)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(transferBytes)
+}
+
+// 查询资产的全部转让申请, 参数: kind, assetId
+func (c *IngredientsExchangeCC) queryTransfers(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	k := lookupAssetKind(args[0])
+	assetId := args[1]
+	if k == nil || assetId == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	timestamp, err := txTimestamp(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get tx timestamp error: %s", err))
+	}
+
+	result, err := stub.GetStateByPartialCompositeKey(assetTransferType, []string{k.name, assetId})
+	if err != nil {
+		return errorResponse(fmt.Errorf("query transfers error: %s", err))
+	}
+	defer result.Close()
+
+	transfers := make([]*Transfer, 0)
+	for result.HasNext() {
+		indexVal, err := result.Next()
+		if err != nil {
+			return errorResponse(fmt.Errorf("query error: %s", err))
+		}
+
+		transfer, err := getTransfer(stub, string(indexVal.GetValue()))
+		if err != nil {
+			return errorResponse(fmt.Errorf("get transfer error: %s", err))
+		}
+		if transfer == nil {
+			continue
+		}
+		if transfer.Status == transferPending && transfer.expired(timestamp) {
+			transfer.Status = transferExpired
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	transfersBytes, err := json.Marshal(transfers)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(transfersBytes)
+}
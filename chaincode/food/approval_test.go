@@ -0,0 +1,126 @@
+package food_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+	"github.com/Blockchain-book/Fabric-Food/simulator"
+)
+
+const approvalConfig = `{
+	"admin_msps": ["Org1MSP"],
+	"roles": {"sales_manager": ["SalesMSP"], "compliance": ["ComplianceMSP"]},
+	"approval_policies": {"premium": {"required": 2, "roles": {"sales_manager": 1, "compliance": 1}}}
+}`
+
+// 每个角色的最少批准数都满足后才执行转让
+func TestTransferApproveRoleMinimums(t *testing.T) {
+	sim, err := simulator.New(approvalConfig, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, msp := range map[string]string{"admin": "Org1MSP", "sales1": "SalesMSP", "sales2": "SalesMSP", "auditor": "ComplianceMSP"} {
+		if err := sim.AddIdentity(name, &gateway.Identity{MSPID: msp}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, req := range []food.Request{
+		&food.UserRegisterRequest{Name: "alice", Id: "u1"},
+		&food.UserRegisterRequest{Name: "bob", Id: "u2"},
+		&food.FoodEnrollRequest{Name: "wagyu", Id: "f1", Metadata: `{"asset_class":"premium"}`, OwnerId: "u1"},
+	} {
+		mustExecute(t, sim, req)
+	}
+
+	transfer := new(food.Transfer)
+	if err := json.Unmarshal(mustExecute(t, sim, &food.FoodExchangeRequest{OwnerId: "u1", FoodId: "f1", CurrentOwnerId: "u2"}), transfer); err != nil {
+		t.Fatal(err)
+	}
+	if transfer.Roles["sales_manager"] != 1 || transfer.Roles["compliance"] != 1 {
+		t.Fatalf("expected role minimums copied to the transfer, got %v", transfer.Roles)
+	}
+
+	tests := []struct {
+		approver string
+		status   string
+	}{
+		// 两个销售经理达到了总数, 但还缺合规审批
+		{"sales1", "pending"},
+		{"sales2", "pending"},
+		{"auditor", "executed"},
+	}
+	for _, tt := range tests {
+		req := &food.TransferApproveRequest{TransferId: transfer.Id, Decision: "approve"}
+		payload, err := sim.Invoke(tt.approver, req.Function(), req.Args())
+		if err != nil {
+			t.Fatalf("%s: %v", tt.approver, err)
+		}
+		result := new(food.Transfer)
+		if err := json.Unmarshal(payload, result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != tt.status {
+			t.Errorf("%s: expected %s, got %s", tt.approver, tt.status, result.Status)
+		}
+	}
+}
+
+// 需要审批的食品不能通过合并到其他食品绕过审批
+func TestFoodExchangeFoodRequiresApproval(t *testing.T) {
+	sim, err := simulator.New(approvalConfig, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.AddIdentity("admin", &gateway.Identity{MSPID: "Org1MSP"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []food.Request{
+		&food.UserRegisterRequest{Name: "alice", Id: "u1"},
+		&food.UserRegisterRequest{Name: "bob", Id: "u2"},
+		&food.FoodEnrollRequest{Name: "wagyu", Id: "f1", Metadata: `{"asset_class":"premium"}`, OwnerId: "u1"},
+		&food.FoodEnrollRequest{Name: "rice", Id: "f2", OwnerId: "u1"},
+		&food.FoodEnrollRequest{Name: "bento", Id: "f3", OwnerId: "u2"},
+	} {
+		mustExecute(t, sim, req)
+	}
+
+	tests := []struct {
+		foodId string
+		code   string
+	}{
+		{"f1", food.CodeFailedPrecondition},
+		{"f2", ""},
+	}
+	for _, tt := range tests {
+		_, code, err := execute(sim, &food.FoodExchangeFoodRequest{OwnerId: "u1", FoodId: tt.foodId, TargetFoodId: "f3"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("%s: expected %q, got %q", tt.foodId, tt.code, code)
+		}
+	}
+}
+
+func TestApprovalPolicyConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		valid  bool
+	}{
+		{"role list", `{"roles":{"qa":["QAMSP"]},"approval_policies":{"frozen":{"required":1,"roles":["qa"]}}}`, true},
+		{"role minimums", `{"approval_policies":{"frozen":{"required":2,"roles":{"qa":1,"sales":1}}}}`, true},
+		{"minimums exceed required", `{"approval_policies":{"frozen":{"required":1,"roles":{"qa":1,"sales":1}}}}`, false},
+		{"negative minimum", `{"approval_policies":{"frozen":{"required":1,"roles":{"qa":-1}}}}`, false},
+	}
+
+	for _, tt := range tests {
+		_, err := simulator.New(tt.config, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid=%v, got %v", tt.name, tt.valid, err)
+		}
+	}
+}
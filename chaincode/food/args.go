@@ -0,0 +1,231 @@
+package food
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 命名参数: 每个链码函数也接受一个JSON对象作为唯一参数, 字段名为请求结构的json标签,
+// 解析后由请求结构的 Args 转换为位置参数, 原有的位置参数调用方式不变
+//
+//	peer chaincode invoke -c '{"Args":["queryIngredientHistory","{\"ingredient_id\":\"ingredient1\",\"query_type\":\"exchange\"}"]}'
+
+var requestConstructors = map[string]func() Request{
+	"userRegister":            func() Request { return new(UserRegisterRequest) },
+	"userDestroy":             func() Request { return new(UserDestroyRequest) },
+	"queryUser":               func() Request { return new(QueryUserRequest) },
+	"ingredientEnroll":        func() Request { return new(IngredientEnrollRequest) },
+	"foodEnroll":              func() Request { return new(FoodEnrollRequest) },
+	"ingredientExchange":      func() Request { return new(IngredientExchangeRequest) },
+	"foodExchange":            func() Request { return new(FoodExchangeRequest) },
+	"ingredientExchangeFood":  func() Request { return new(IngredientExchangeFoodRequest) },
+	"foodExchangeFood":        func() Request { return new(FoodExchangeFoodRequest) },
+	"ingredientEnrollBatch":   func() Request { return new(IngredientEnrollBatchRequest) },
+	"foodEnrollBatch":         func() Request { return new(FoodEnrollBatchRequest) },
+	"ingredientExchangeBatch": func() Request { return new(IngredientExchangeBatchRequest) },
+	"queryIngredient":         func() Request { return new(QueryIngredientRequest) },
+	"queryFood":               func() Request { return new(QueryFoodRequest) },
+	"queryIngredientHistory":  func() Request { return new(QueryIngredientHistoryRequest) },
+	"queryFoodHistory":        func() Request { return new(QueryFoodHistoryRequest) },
+	"queryFoodProvenance":     func() Request { return new(QueryFoodProvenanceRequest) },
+	"queryFoodAllergens":      func() Request { return new(QueryFoodAllergensRequest) },
+	"verifyProduct":           func() Request { return new(VerifyProductRequest) },
+	"foodRecall":              func() Request { return new(FoodRecallRequest) },
+	"foodProcess":             func() Request { return new(FoodProcessRequest) },
+	"queryProcessStep":        func() Request { return new(QueryProcessStepRequest) },
+	"containerEnroll":         func() Request { return new(ContainerEnrollRequest) },
+	"containerPack":           func() Request { return new(ContainerPackRequest) },
+	"containerUnpack":         func() Request { return new(ContainerUnpackRequest) },
+	"containerExchange":       func() Request { return new(ContainerExchangeRequest) },
+	"queryContainer":          func() Request { return new(QueryContainerRequest) },
+	"queryContainerHistory":   func() Request { return new(QueryContainerHistoryRequest) },
+	"facilityRegister":        func() Request { return new(FacilityRegisterRequest) },
+	"queryFacility":           func() Request { return new(QueryFacilityRequest) },
+	"queryFacilityItems":      func() Request { return new(QueryFacilityItemsRequest) },
+	"certificateIssue":        func() Request { return new(CertificateIssueRequest) },
+	"certificateRevoke":       func() Request { return new(CertificateRevokeRequest) },
+	"queryCertificate":        func() Request { return new(QueryCertificateRequest) },
+	"queryCertificates":       func() Request { return new(QueryCertificatesRequest) },
+	"transferApprove":         func() Request { return new(TransferApproveRequest) },
+	"queryTransfer":           func() Request { return new(QueryTransferRequest) },
+	"queryTransfers":          func() Request { return new(QueryTransfersRequest) },
+	"queryEndorsement":        func() Request { return new(QueryEndorsementRequest) },
+	"updateConfig":            func() Request { return new(UpdateConfigRequest) },
+	"queryConfig":             func() Request { return new(QueryConfigRequest) },
+	"migrate":                 func() Request { return new(MigrateRequest) },
+	"checkConsistency":        func() Request { return new(CheckConsistencyRequest) },
+	"repairOrphan":            func() Request { return new(RepairOrphanRequest) },
+	"repairReference":         func() Request { return new(RepairReferenceRequest) },
+	"repairHoldings":          func() Request { return new(RepairHoldingsRequest) },
+	"queryRepairs":            func() Request { return new(QueryRepairsRequest) },
+}
+
+// 链码函数对应的请求结构, 未知函数返回 nil
+func NewRequest(function string) Request {
+	constructor, ok := requestConstructors[function]
+	if !ok {
+		return nil
+	}
+
+	return constructor()
+}
+
+// 单个字段的校验错误
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// 命名参数的校验错误, 列出每个有问题的字段
+type ArgsError struct {
+	Function string
+	Fields   []*FieldError
+}
+
+func (e *ArgsError) Error() string {
+	reasons := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		if f.Field == "" {
+			reasons = append(reasons, f.Reason)
+			continue
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %s", f.Field, f.Reason))
+	}
+
+	return fmt.Sprintf("invalid args: %s", strings.Join(reasons, "; "))
+}
+
+func (e *ArgsError) add(field, reason string) {
+	e.Fields = append(e.Fields, &FieldError{Field: field, Reason: reason})
+}
+
+// 是否以命名参数调用
+func isNamedArgs(function string, args []string) bool {
+	if len(args) != 1 || !strings.HasPrefix(strings.TrimSpace(args[0]), "{") {
+		return false
+	}
+	if _, ok := requestConstructors[function]; !ok {
+		return false
+	}
+
+	// updateConfig 的位置参数本身就是配置对象, 带 config 字段时才是命名参数
+	if function == "updateConfig" {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(args[0]), &fields); err != nil {
+			return false
+		}
+		_, ok := fields["config"]
+		return ok
+	}
+
+	return true
+}
+
+// 命名参数转换为位置参数, 位置参数原样返回
+func namedArgs(function string, args []string) ([]string, error) {
+	if !isNamedArgs(function, args) {
+		return args, nil
+	}
+
+	req := NewRequest(function)
+	if err := DecodeRequest([]byte(args[0]), req); err != nil {
+		return nil, err
+	}
+
+	return req.Args(), nil
+}
+
+// 严格解析命名参数, 未知字段、类型错误和缺少的必填字段都会列出
+// json标签不带 omitempty 的字符串、数组和对象字段为必填, 数值字段不检查
+func DecodeRequest(data []byte, req Request) error {
+	argsErr := &ArgsError{Function: req.Function()}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		argsErr.add("", fmt.Sprintf("malformed json object: %s", err))
+		return argsErr
+	}
+
+	v := reflect.ValueOf(req).Elem()
+	t := v.Type()
+	known := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")
+		name := tag[0]
+		known[name] = true
+		optional := len(tag) > 1 && tag[1] == "omitempty"
+
+		field := v.Field(i)
+		if raw, ok := fields[name]; ok && string(raw) != "null" {
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(field.Addr().Interface()); err != nil {
+				argsErr.add(name, fieldReason(err))
+				continue
+			}
+		}
+
+		if !optional && isEmptyArg(field) {
+			argsErr.add(name, "required")
+		}
+	}
+
+	unknown := make([]string, 0)
+	for name := range fields {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		argsErr.add(name, "unknown field")
+	}
+
+	if len(argsErr.Fields) != 0 {
+		return argsErr
+	}
+
+	return nil
+}
+
+// 类型错误说明期望的JSON类型, 嵌套字段带上字段路径
+func fieldReason(err error) string {
+	typeErr, ok := err.(*json.UnmarshalTypeError)
+	if !ok {
+		return strings.TrimPrefix(err.Error(), "json: ")
+	}
+
+	expected := "object"
+	switch typeErr.Type.Kind() {
+	case reflect.String:
+		expected = "string"
+	case reflect.Int, reflect.Int64:
+		expected = "integer"
+	case reflect.Float64:
+		expected = "number"
+	case reflect.Bool:
+		expected = "boolean"
+	case reflect.Slice:
+		expected = "array"
+	}
+	if typeErr.Field != "" {
+		return fmt.Sprintf("%s: expected %s", typeErr.Field, expected)
+	}
+
+	return fmt.Sprintf("expected %s", expected)
+}
+
+func isEmptyArg(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Ptr:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,380 @@
+package food
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+const (
+	// 旧版本中食材和食品共用的流通记录命名空间, 只读
+	legacyHistoryType = "history"
+	// 拥有者收到过的资产索引: holderAsset [holderId, kind, assetId]
+	// 重建用户名下的资产时只需检查这些资产, 不用扫描全部资产
+	holderAssetType = "holderAsset"
+)
+
+// 索引只用键, 值不能为空
+var indexValue = []byte{0x00}
+
+// 资产类型, 新的资产类型只需要在这里声明
+type assetKind struct {
+	// 资产名称, 同时作为状态键前缀和错误信息
+	name string
+	// 流通记录的组合键命名空间
+	historyType string
+	// 流通记录中资产id的字段名, 用于区分旧版本共用命名空间中的记录
+	idField string
+	// 转让时资产不存在的错误信息
+	notFound string
+	// 用户名下该类资产的索引
+	holdings func(user *User) *[]string
+	// 生成流通记录, facilityId 为空表示未指定设施, timestamp 为交易时间
+	newHistory func(id, from, to, facilityId, timestamp string) interface{}
+}
+
+var (
+	ingredientAsset = &assetKind{
+		name:        "ingredient",
+		historyType: "ingredientHistory",
+		idField:     "ingredient_id",
+		notFound:    "asset not found",
+		holdings: func(user *User) *[]string {
+			return &user.Ingredients
+		},
+		newHistory: func(id, from, to, facilityId, timestamp string) interface{} {
+			return &IngredientHistory{
+				IngredientId:   id,
+				OriginOwnerId:  from,
+				CurrentOwnerId: to,
+				FacilityId:     facilityId,
+				Timestamp:      timestamp,
+			}
+		},
+	}
+
+	foodAsset = &assetKind{
+		name:        "food",
+		historyType: "foodHistory",
+		idField:     "food_id",
+		notFound:    "food not found",
+		holdings: func(user *User) *[]string {
+			return &user.Foods
+		},
+		newHistory: func(id, from, to, facilityId, timestamp string) interface{} {
+			return &FoodHistory{
+				FoodId:         id,
+				OriginOwnerId:  from,
+				CurrentOwnerId: to,
+				FacilityId:     facilityId,
+				Timestamp:      timestamp,
+			}
+		},
+	}
+
+	// 按声明顺序排列的资产类型
+	assetKinds = []*assetKind{ingredientAsset, foodAsset, containerAsset}
+)
+
+// 按名称查找资产类型
+func lookupAssetKind(name string) *assetKind {
+	for _, kind := range assetKinds {
+		if kind.name == name {
+			return kind
+		}
+	}
+
+	return nil
+}
+
+func (k *assetKind) key(id string) string {
+	return fmt.Sprintf("%s_%s", k.name, id)
+}
+
+func (k *assetKind) exists(stub shim.ChaincodeStubInterface, id string) bool {
+	assetBytes, err := stub.GetState(k.key(id))
+
+	return err == nil && len(assetBytes) != 0
+}
+
+// 资产不存在的错误
+func (k *assetKind) notFoundError(id string) *Error {
+	return &Error{Code: CodeNotFound, Message: k.notFound, EntityId: id}
+}
+
+// 用户是否持有该资产
+func (k *assetKind) owns(user *User, id string) bool {
+	for _, aid := range *k.holdings(user) {
+		if aid == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (k *assetKind) addHolding(user *User, id string) {
+	holdings := k.holdings(user)
+	*holdings = append(*holdings, id)
+}
+
+// 从用户名下移除资产, 返回是否存在
+func (k *assetKind) removeHolding(user *User, id string) bool {
+	holdings := k.holdings(user)
+	ids := make([]string, 0)
+	found := false
+	for _, aid := range *holdings {
+		if aid == id {
+			found = true
+			continue
+		}
+
+		ids = append(ids, aid)
+	}
+	*holdings = ids
+
+	return found
+}
+
+func (k *assetKind) historyKey(stub shim.ChaincodeStubInterface, id, from, to string) (string, error) {
+	return stub.CreateCompositeKey(k.historyType, []string{id, from, to})
+}
+
+func (k *assetKind) holderIndexKey(stub shim.ChaincodeStubInterface, holderId, id string) (string, error) {
+	return stub.CreateCompositeKey(holderAssetType, []string{holderId, k.name, id})
+}
+
+// 写入流通记录, 记录交易时间, 同时写入新拥有者的资产索引, 指定设施时写入设施索引
+func (k *assetKind) putHistory(stub shim.ChaincodeStubInterface, id, from, to, facilityId string) error {
+	timestamp, err := txTimestamp(stub)
+	if err != nil {
+		return fmt.Errorf("get tx timestamp error: %s", err)
+	}
+
+	historyBytes, err := marshalDoc(k.newHistory(id, from, to, facilityId, timestamp))
+	if err != nil {
+		return fmt.Errorf("marshal %s history error: %s", k.name, err)
+	}
+
+	historyKey, err := k.historyKey(stub, id, from, to)
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+
+	if err := stub.PutState(historyKey, historyBytes); err != nil {
+		return fmt.Errorf("save %s history error: %s", k.name, err)
+	}
+
+	indexKey, err := k.holderIndexKey(stub, to, id)
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+	if err := stub.PutState(indexKey, indexValue); err != nil {
+		return fmt.Errorf("save holder index error: %s", err)
+	}
+
+	if facilityId == "" {
+		return nil
+	}
+
+	return putFacilityVisit(stub, k, id, from, to, facilityId)
+}
+
+// 查询流通记录, attrs 为组合键的前缀属性, 第一个为资产id
+// 先返回旧版本共用命名空间中属于该资产的记录, 再返回本类型命名空间中的记录
+func (k *assetKind) historyValues(stub shim.ChaincodeStubInterface, attrs ...string) ([][]byte, error) {
+	values := make([][]byte, 0)
+
+	for _, historyType := range []string{legacyHistoryType, k.historyType} {
+		result, err := stub.GetStateByPartialCompositeKey(historyType, attrs)
+		if err != nil {
+			return nil, err
+		}
+
+		for result.HasNext() {
+			historyVal, err := result.Next()
+			if err != nil {
+				result.Close()
+				return nil, err
+			}
+			if historyType == legacyHistoryType && !k.ownsHistory(historyVal.GetValue(), attrs[0]) {
+				continue
+			}
+			values = append(values, historyVal.GetValue())
+		}
+		result.Close()
+	}
+
+	return values, nil
+}
+
+// 旧版本记录是否属于该类资产
+func (k *assetKind) ownsHistory(value []byte, id string) bool {
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return false
+	}
+
+	return fields[k.idField] == id
+}
+
+// 按查询类型过滤流通记录: all 全部, enroll 登记, exchange 转让
+func (k *assetKind) histories(stub shim.ChaincodeStubInterface, id, queryType string) ([][]byte, error) {
+	attrs := []string{id}
+	if queryType == "enroll" {
+		attrs = append(attrs, originOwner)
+	}
+
+	values, err := k.historyValues(stub, attrs...)
+	if err != nil {
+		return nil, err
+	}
+	if queryType != "exchange" {
+		return values, nil
+	}
+
+	// 过滤掉不是转让的记录
+	exchanges := make([][]byte, 0)
+	for _, value := range values {
+		edge := new(historyEdge)
+		if err := json.Unmarshal(value, edge); err != nil {
+			return nil, err
+		}
+		if edge.OriginOwnerId == originOwner {
+			continue
+		}
+		exchanges = append(exchanges, value)
+	}
+
+	return exchanges, nil
+}
+
+// 解析流通记录查询参数, extraTypes 为该资产额外支持的查询类型
+func parseHistoryArgs(args []string, extraTypes ...string) (string, string, error) {
+	//检查参数的个数
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return "", "", err
+	}
+
+	//验证参数的正确性
+	id := args[0]
+	if id == "" {
+		return "", "", invalidArgs()
+	}
+
+	queryType := "all"
+	if len(args) == 2 {
+		queryType = args[1]
+	}
+
+	for _, t := range append([]string{"all", "enroll", "exchange"}, extraTypes...) {
+		if t == queryType {
+			return id, queryType, nil
+		}
+	}
+
+	return "", "", newError(CodeInvalidArgument, "queryType unknown %s", queryType).withField("query_type")
+}
+
+// 校验资产登记的前置条件, 返回资产的拥有者
+func checkEnrollAsset(stub shim.ChaincodeStubInterface, k *assetKind, id, ownerId string) (*User, error) {
+	//验证数据是否存在
+	userBytes, err := stub.GetState(constructUserKey(ownerId))
+	if err != nil || len(userBytes) == 0 {
+		return nil, notFound("user", ownerId)
+	}
+
+	if k.exists(stub, id) {
+		return nil, alreadyExists(k.name, id)
+	}
+
+	user := new(User)
+	// 反序列化用户
+	if err := unmarshalDoc(userBytes, user); err != nil {
+		return nil, fmt.Errorf("unmarshal user error: %s", err)
+	}
+
+	return user, nil
+}
+
+// 资产登记: 写入资产, 加入拥有者名下, 写入登记记录
+func enrollAsset(stub shim.ChaincodeStubInterface, k *assetKind, id string, doc interface{}, owner *User, facilityId string) error {
+	assetBytes, err := marshalDoc(doc)
+	if err != nil {
+		return fmt.Errorf("marshal %s error: %s", k.name, err)
+	}
+	if err := stub.PutState(k.key(id), assetBytes); err != nil {
+		return fmt.Errorf("save %s error: %s", k.name, err)
+	}
+	if err := setOwnerEndorsement(stub, k.key(id), owner); err != nil {
+		return err
+	}
+
+	k.addHolding(owner, id)
+	if err := putUser(stub, owner); err != nil {
+		return fmt.Errorf("update user error: %s", err)
+	}
+
+	return k.putHistory(stub, id, originOwner, owner.Id, facilityId)
+}
+
+// 校验资产转让的前置条件, 返回原拥有者和新拥有者
+func checkExchangeAsset(stub shim.ChaincodeStubInterface, k *assetKind, ownerId, id, currentOwnerId, facilityId string) (*User, *User, error) {
+	//验证数据是否存在
+	originOwner, err := getUser(stub, ownerId)
+	if err != nil || originOwner == nil {
+		return nil, nil, notFound("user", ownerId)
+	}
+
+	currentOwner, err := getUser(stub, currentOwnerId)
+	if err != nil || currentOwner == nil {
+		return nil, nil, notFound("user", currentOwnerId)
+	}
+
+	if !k.exists(stub, id) {
+		return nil, nil, k.notFoundError(id)
+	}
+
+	if err := checkFacility(stub, facilityId); err != nil {
+		return nil, nil, err
+	}
+
+	// 已装箱的资产只能随容器一起转让
+	if err := checkNotPacked(stub, k, id); err != nil {
+		return nil, nil, err
+	}
+
+	// 校验原始拥有者确实拥有当前变更的资产
+	if !k.owns(originOwner, id) {
+		return nil, nil, ownerMismatch(k.name, id)
+	}
+
+	return originOwner, currentOwner, nil
+}
+
+// 资产在用户之间转让
+func exchangeAsset(stub shim.ChaincodeStubInterface, k *assetKind, ownerId, id, currentOwnerId, facilityId string) error {
+	originOwner, currentOwner, err := checkExchangeAsset(stub, k, ownerId, id, currentOwnerId, facilityId)
+	if err != nil {
+		return err
+	}
+
+	//写入状态
+	k.removeHolding(originOwner, id)
+	if err := putUser(stub, originOwner); err != nil {
+		return fmt.Errorf("update user error: %s", err)
+	}
+
+	// 当前拥有者插入资产id
+	k.addHolding(currentOwner, id)
+	if err := putUser(stub, currentOwner); err != nil {
+		return fmt.Errorf("update user error: %s", err)
+	}
+	if err := setOwnerEndorsement(stub, k.key(id), currentOwner); err != nil {
+		return err
+	}
+
+	// 插入变更记录
+	return k.putHistory(stub, id, ownerId, currentOwnerId, facilityId)
+}
@@ -0,0 +1,281 @@
+package food_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+	"github.com/Blockchain-book/Fabric-Food/simulator"
+)
+
+// 三类资产的登记、转让和流通记录查询请求
+type assetCase struct {
+	name     string
+	idField  string
+	enroll   func(id, ownerId string) food.Request
+	exchange func(ownerId, id, currentOwnerId string) food.Request
+	history  func(id, queryType string) food.Request
+	// 旧版本共用 history 命名空间中的记录, 容器没有旧记录
+	legacy string
+}
+
+var assetCases = []assetCase{
+	{
+		name:    "ingredient",
+		idField: "ingredient_id",
+		enroll: func(id, ownerId string) food.Request {
+			return &food.IngredientEnrollRequest{Name: "rice", Id: id, OwnerId: ownerId}
+		},
+		exchange: func(ownerId, id, currentOwnerId string) food.Request {
+			return &food.IngredientExchangeRequest{OwnerId: ownerId, IngredientId: id, CurrentOwnerId: currentOwnerId}
+		},
+		history: func(id, queryType string) food.Request {
+			return &food.QueryIngredientHistoryRequest{IngredientId: id, QueryType: queryType}
+		},
+		legacy: `{"ingredient_id":"legacy1","origin_owner_id":"originPlaceholder","current_owner_id":"u1"}`,
+	},
+	{
+		name:    "food",
+		idField: "food_id",
+		enroll: func(id, ownerId string) food.Request {
+			return &food.FoodEnrollRequest{Name: "bread", Id: id, OwnerId: ownerId}
+		},
+		exchange: func(ownerId, id, currentOwnerId string) food.Request {
+			return &food.FoodExchangeRequest{OwnerId: ownerId, FoodId: id, CurrentOwnerId: currentOwnerId}
+		},
+		history: func(id, queryType string) food.Request {
+			return &food.QueryFoodHistoryRequest{FoodId: id, QueryType: queryType}
+		},
+		legacy: `{"food_id":"legacy1","origin_owner_id":"originPlaceholder","current_owner_id":"u2"}`,
+	},
+	{
+		name:    "container",
+		idField: "container_id",
+		enroll: func(id, ownerId string) food.Request {
+			return &food.ContainerEnrollRequest{Name: "box", Id: id, OwnerId: ownerId}
+		},
+		exchange: func(ownerId, id, currentOwnerId string) food.Request {
+			return &food.ContainerExchangeRequest{OwnerId: ownerId, ContainerId: id, CurrentOwnerId: currentOwnerId}
+		},
+		history: func(id, queryType string) food.Request {
+			return &food.QueryContainerHistoryRequest{ContainerId: id, QueryType: queryType}
+		},
+	},
+}
+
+// 注册了 u1、u2 两个用户的模拟账本
+func newAssetLedger(t *testing.T) *simulator.Simulator {
+	sim, err := simulator.New(`{"admin_msps":["Org1MSP"]}`, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.AddIdentity("admin", &gateway.Identity{MSPID: "Org1MSP"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []food.Request{
+		&food.UserRegisterRequest{Name: "alice", Id: "u1"},
+		&food.UserRegisterRequest{Name: "bob", Id: "u2"},
+	} {
+		mustExecute(t, sim, req)
+	}
+
+	return sim
+}
+
+// 执行请求, 返回链码的错误码, 成功时为空
+func execute(sim *simulator.Simulator, req food.Request) ([]byte, string, error) {
+	payload, err := sim.Invoke("admin", req.Function(), req.Args())
+	if err == nil {
+		return payload, "", nil
+	}
+	if ccErr, ok := err.(*gateway.ChaincodeError); ok && ccErr.Detail != nil {
+		return nil, ccErr.Detail.Code, nil
+	}
+
+	return nil, "", err
+}
+
+func mustExecute(t *testing.T, sim *simulator.Simulator, req food.Request) []byte {
+	payload, code, err := execute(sim, req)
+	if err != nil || code != "" {
+		t.Fatalf("%s: unexpected error %s %v", req.Function(), code, err)
+	}
+
+	return payload
+}
+
+// 流通记录的 (原拥有者, 新拥有者) 列表
+func historyOwners(t *testing.T, payload []byte) [][2]string {
+	records := make([]map[string]interface{}, 0)
+	if err := json.Unmarshal(payload, &records); err != nil {
+		t.Fatalf("unmarshal history error: %s: %s", err, payload)
+	}
+
+	owners := make([][2]string, 0, len(records))
+	for _, record := range records {
+		from, _ := record["origin_owner_id"].(string)
+		to, _ := record["current_owner_id"].(string)
+		owners = append(owners, [2]string{from, to})
+	}
+
+	return owners
+}
+
+func TestAssetEnroll(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		ownerId string
+		code    string
+	}{
+		{"enroll", "a1", "u1", ""},
+		{"duplicate id", "a1", "u2", food.CodeAlreadyExists},
+		{"unknown owner", "a2", "nobody", food.CodeNotFound},
+	}
+
+	for _, kind := range assetCases {
+		t.Run(kind.name, func(t *testing.T) {
+			sim := newAssetLedger(t)
+			for _, tt := range tests {
+				if _, code, err := execute(sim, kind.enroll(tt.id, tt.ownerId)); err != nil || code != tt.code {
+					t.Errorf("%s: expected code %q, got %q %v", tt.name, tt.code, code, err)
+				}
+			}
+
+			payload := mustExecute(t, sim, kind.history("a1", "enroll"))
+			if owners := historyOwners(t, payload); len(owners) != 1 || owners[0] != [2]string{"originPlaceholder", "u1"} {
+				t.Errorf("expected one enroll record to u1, got %v", owners)
+			}
+		})
+	}
+}
+
+func TestAssetExchange(t *testing.T) {
+	tests := []struct {
+		name           string
+		ownerId        string
+		id             string
+		currentOwnerId string
+		code           string
+	}{
+		{"not the owner", "u2", "a1", "u1", food.CodeOwnerMismatch},
+		{"unknown asset", "u1", "missing", "u2", food.CodeNotFound},
+		{"unknown new owner", "u1", "a1", "nobody", food.CodeNotFound},
+		{"exchange", "u1", "a1", "u2", ""},
+		{"previous owner", "u1", "a1", "u2", food.CodeOwnerMismatch},
+		{"exchange back", "u2", "a1", "u1", ""},
+	}
+
+	for _, kind := range assetCases {
+		t.Run(kind.name, func(t *testing.T) {
+			sim := newAssetLedger(t)
+			mustExecute(t, sim, kind.enroll("a1", "u1"))
+			for _, tt := range tests {
+				if _, code, err := execute(sim, kind.exchange(tt.ownerId, tt.id, tt.currentOwnerId)); err != nil || code != tt.code {
+					t.Errorf("%s: expected code %q, got %q %v", tt.name, tt.code, code, err)
+				}
+			}
+
+			user := new(food.User)
+			if err := json.Unmarshal(mustExecute(t, sim, &food.QueryUserRequest{Id: "u1"}), user); err != nil {
+				t.Fatal(err)
+			}
+			holdings := map[string][]string{"ingredient": user.Ingredients, "food": user.Foods, "container": user.Containers}
+			if ids := holdings[kind.name]; len(ids) != 1 || ids[0] != "a1" {
+				t.Errorf("expected u1 to hold a1 again, got %v", ids)
+			}
+		})
+	}
+}
+
+func TestAssetHistory(t *testing.T) {
+	tests := []struct {
+		queryType string
+		expected  [][2]string
+	}{
+		{"", [][2]string{{"originPlaceholder", "u1"}, {"u1", "u2"}}},
+		{"all", [][2]string{{"originPlaceholder", "u1"}, {"u1", "u2"}}},
+		{"enroll", [][2]string{{"originPlaceholder", "u1"}}},
+		{"exchange", [][2]string{{"u1", "u2"}}},
+	}
+
+	for _, kind := range assetCases {
+		t.Run(kind.name, func(t *testing.T) {
+			sim := newAssetLedger(t)
+			mustExecute(t, sim, kind.enroll("a1", "u1"))
+			mustExecute(t, sim, kind.exchange("u1", "a1", "u2"))
+
+			for _, tt := range tests {
+				owners := historyOwners(t, mustExecute(t, sim, kind.history("a1", tt.queryType)))
+				if len(owners) != len(tt.expected) {
+					t.Errorf("query %q: expected %v, got %v", tt.queryType, tt.expected, owners)
+					continue
+				}
+				for i := range owners {
+					if owners[i] != tt.expected[i] {
+						t.Errorf("query %q: expected %v, got %v", tt.queryType, tt.expected, owners)
+						break
+					}
+				}
+			}
+
+			if _, code, _ := execute(sim, kind.history("a1", "unknown")); code != food.CodeInvalidArgument {
+				t.Errorf("expected %s for an unknown query type, got %q", food.CodeInvalidArgument, code)
+			}
+		})
+	}
+}
+
+// 升级前写在共用 history 命名空间中的记录仍能按资产类型读到, 且不会混入同id的其他类型资产
+func TestAssetLegacyHistory(t *testing.T) {
+	sim := newAssetLedger(t)
+
+	// 旧版本的食材和食品, 同id的容器在新版本登记
+	stub := sim.Stub
+	stub.MockTransactionStart("legacy")
+	for _, kind := range assetCases {
+		if kind.legacy == "" {
+			continue
+		}
+		stub.PutState(kind.name+"_legacy1", []byte(`{"name":"old","id":"legacy1"}`))
+		record := make(map[string]string)
+		if err := json.Unmarshal([]byte(kind.legacy), &record); err != nil {
+			t.Fatal(err)
+		}
+		key, err := stub.CreateCompositeKey("history", []string{"legacy1", record["origin_owner_id"], record["current_owner_id"]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stub.PutState(key, []byte(kind.legacy))
+	}
+	stub.MockTransactionEnd("legacy")
+	mustExecute(t, sim, &food.ContainerEnrollRequest{Name: "box", Id: "legacy1", OwnerId: "u1"})
+
+	for _, kind := range assetCases {
+		t.Run(kind.name, func(t *testing.T) {
+			payload := mustExecute(t, sim, kind.history("legacy1", "all"))
+			records := make([]map[string]interface{}, 0)
+			if err := json.Unmarshal(payload, &records); err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 1 || records[0][kind.idField] != "legacy1" {
+				t.Fatalf("expected one %s record, got %s", kind.name, payload)
+			}
+			if kind.legacy == "" {
+				return
+			}
+
+			expected := make(map[string]interface{})
+			json.Unmarshal([]byte(kind.legacy), &expected)
+			if records[0]["current_owner_id"] != expected["current_owner_id"] {
+				t.Errorf("expected the legacy %s record, got %s", kind.name, payload)
+			}
+			// 旧记录读取时升级为当前版本
+			if records[0]["schema_version"] != float64(food.CurrentSchemaVersion) {
+				t.Errorf("expected legacy record upgraded to version %d, got %v", food.CurrentSchemaVersion, records[0]["schema_version"])
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+package food
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	defaultMaxBatchSize = 500
+)
+
+// 批量食材登记
+type IngredientEnrollItem struct {
+	Name           string   `json:"name"`
+	Id             string   `json:"id"`
+	Metadata       string   `json:"metadata"`
+	OwnerId        string   `json:"owner_id"`
+	Allergens      []string `json:"allergens"`
+	FacilityId     string   `json:"facility_id"`
+	CertificateIds []string `json:"certificate_ids"`
+}
+
+// 批量食品登记
+type FoodEnrollItem struct {
+	Name           string   `json:"name"`
+	Id             string   `json:"id"`
+	Metadata       string   `json:"metadata"`
+	OwnerId        string   `json:"owner_id"`
+	AllergenFree   []string `json:"allergen_free"`
+	Serial         string   `json:"serial"`
+	FacilityId     string   `json:"facility_id"`
+	CertificateIds []string `json:"certificate_ids"`
+}
+
+// 批量食材变更
+type IngredientExchangeItem struct {
+	OwnerId        string `json:"owner_id"`
+	IngredientId   string `json:"ingredient_id"`
+	CurrentOwnerId string `json:"current_owner_id"`
+	FacilityId     string `json:"facility_id"`
+}
+
+// 单条结果
+type BatchResult struct {
+	Index int    `json:"index"`
+	Id    string `json:"id"`
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// 批量操作的写集合
+// Fabric 在同一交易内读不到自己的写入, 所以先在内存中逐条应用, 最后统一写入
+type batch struct {
+	stub  shim.ChaincodeStubInterface
+	users map[string]*User
+	order []string
+	puts  map[string][]byte
+	keys  []string
+	// 资产键 -> 拥有者, 提交时设置背书策略
+	owners    map[string]string
+	ownerKeys []string
+	results   []*BatchResult
+	failed    bool
+}
+
+func newBatch(stub shim.ChaincodeStubInterface) *batch {
+	return &batch{
+		stub:      stub,
+		users:     make(map[string]*User),
+		order:     make([]string, 0),
+		puts:      make(map[string][]byte),
+		keys:      make([]string, 0),
+		owners:    make(map[string]string),
+		ownerKeys: make([]string, 0),
+		results:   make([]*BatchResult, 0),
+	}
+}
+
+// 批量大小上限, 由链码配置决定
+func maxBatchSize(stub shim.ChaincodeStubInterface) int {
+	cfg, err := getConfig(stub)
+	if err != nil {
+		return defaultMaxBatchSize
+	}
+
+	return cfg.MaxBatchSize
+}
+
+// 严格解析批量参数, 拒绝未知字段
+func decodeBatch(args []string, items interface{}) error {
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(args[0])))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(items); err != nil {
+		return newError(CodeInvalidArgument, "invalid batch: %s", err)
+	}
+
+	return nil
+}
+
+func checkBatchSize(stub shim.ChaincodeStubInterface, size int) error {
+	if size == 0 {
+		return newError(CodeInvalidArgument, "empty batch")
+	}
+	if limit := maxBatchSize(stub); size > limit {
+		return newError(CodeInvalidArgument, "batch size %d exceeds limit %d", size, limit)
+	}
+
+	return nil
+}
+
+// 读取用户, 同一批次内共享修改
+func (b *batch) user(userId string) (*User, error) {
+	if user, ok := b.users[userId]; ok {
+		return user, nil
+	}
+
+	userBytes, err := b.stub.GetState(constructUserKey(userId))
+	if err != nil || len(userBytes) == 0 {
+		return nil, notFound("user", userId)
+	}
+
+	user := new(User)
+	if err := unmarshalDoc(userBytes, user); err != nil {
+		return nil, fmt.Errorf("unmarshal user error: %s", err)
+	}
+	b.users[userId] = user
+	b.order = append(b.order, userId)
+
+	return user, nil
+}
+
+// 键是否已存在于账本或本批次中
+func (b *batch) exists(key string) bool {
+	if _, ok := b.puts[key]; ok {
+		return true
+	}
+	value, err := b.stub.GetState(key)
+
+	return err == nil && len(value) != 0
+}
+
+func (b *batch) put(key string, value interface{}) error {
+	valueBytes, err := marshalDoc(value)
+	if err != nil {
+		return err
+	}
+	b.putBytes(key, valueBytes)
+
+	return nil
+}
+
+func (b *batch) putBytes(key string, value []byte) {
+	if _, ok := b.puts[key]; !ok {
+		b.keys = append(b.keys, key)
+	}
+	b.puts[key] = value
+}
+
+func (b *batch) putHistory(k *assetKind, id, from, to, facilityId string) error {
+	historyKey, err := k.historyKey(b.stub, id, from, to)
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+	timestamp, err := txTimestamp(b.stub)
+	if err != nil {
+		return fmt.Errorf("get tx timestamp error: %s", err)
+	}
+	if err := b.put(historyKey, k.newHistory(id, from, to, facilityId, timestamp)); err != nil {
+		return err
+	}
+	indexKey, err := k.holderIndexKey(b.stub, to, id)
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+	b.putBytes(indexKey, indexValue)
+	if facilityId == "" {
+		return nil
+	}
+
+	visitKey, visit, err := newFacilityVisit(b.stub, k, id, from, to, facilityId)
+	if err != nil {
+		return err
+	}
+	visitBytes, err := json.Marshal(visit)
+	if err != nil {
+		return err
+	}
+	b.putBytes(visitKey, visitBytes)
+
+	return nil
+}
+
+// 记录资产键的最终拥有者
+func (b *batch) endorse(key, userId string) {
+	if _, ok := b.owners[key]; !ok {
+		b.ownerKeys = append(b.ownerKeys, key)
+	}
+	b.owners[key] = userId
+}
+
+func (b *batch) putClaims(k *assetKind, id string, claims []*CertificationClaim) error {
+	for _, claim := range claims {
+		claimKey, err := certClaimKey(b.stub, claim.CertificateId, k, id)
+		if err != nil {
+			return fmt.Errorf("create key error: %s", err)
+		}
+		b.putBytes(claimKey, []byte(id))
+	}
+
+	return nil
+}
+
+func (b *batch) result(index int, id string, err error) {
+	result := &BatchResult{Index: index, Id: id}
+	if err != nil {
+		result.Code = toError(err).Code
+		result.Error = err.Error()
+		b.failed = true
+	}
+	b.results = append(b.results, result)
+}
+
+// 所有条目都通过校验后统一写入
+func (b *batch) commit() pb.Response {
+	resultsBytes, err := json.Marshal(b.results)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+	if b.failed {
+		return errorResponse(newError(CodeBatchRejected, "batch rejected: %s", resultsBytes))
+	}
+
+	for _, userId := range b.order {
+		if err := b.put(constructUserKey(userId), b.users[userId]); err != nil {
+			return errorResponse(fmt.Errorf("marshal user error: %s", err))
+		}
+	}
+	for _, key := range b.keys {
+		if err := b.stub.PutState(key, b.puts[key]); err != nil {
+			return errorResponse(fmt.Errorf("put state error: %s", err))
+		}
+	}
+	for _, key := range b.ownerKeys {
+		if err := setOwnerEndorsement(b.stub, key, b.users[b.owners[key]]); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	return shim.Success(resultsBytes)
+}
+
+func (b *batch) enrollIngredient(item *IngredientEnrollItem) error {
+	if item.Name == "" || item.Id == "" || item.OwnerId == "" {
+		return invalidArgs()
+	}
+
+	user, err := b.user(item.OwnerId)
+	if err != nil {
+		return err
+	}
+	if b.exists(constructIngredientKey(item.Id)) {
+		return alreadyExists("ingredient", item.Id)
+	}
+	if err := checkFacility(b.stub, item.FacilityId); err != nil {
+		return err
+	}
+	certifications, err := checkCertificationClaims(b.stub, ingredientAsset, item.CertificateIds, item.OwnerId, item.FacilityId)
+	if err != nil {
+		return err
+	}
+	if err := b.putClaims(ingredientAsset, item.Id, certifications); err != nil {
+		return err
+	}
+
+	ingredient := &Ingredient{
+		Name:           item.Name,
+		Id:             item.Id,
+		Metadata:       item.Metadata,
+		Allergens:      parseAllergens(strings.Join(item.Allergens, ",")),
+		Certifications: certifications,
+	}
+	if err := b.put(constructIngredientKey(item.Id), ingredient); err != nil {
+		return fmt.Errorf("marshal ingredient error: %s", err)
+	}
+	ingredientAsset.addHolding(user, item.Id)
+	b.endorse(constructIngredientKey(item.Id), item.OwnerId)
+
+	return b.putHistory(ingredientAsset, item.Id, originOwner, item.OwnerId, item.FacilityId)
+}
+
+func (b *batch) enrollFood(item *FoodEnrollItem, enrolledAt string) error {
+	if item.Name == "" || item.Id == "" || item.OwnerId == "" {
+		return invalidArgs()
+	}
+
+	user, err := b.user(item.OwnerId)
+	if err != nil {
+		return err
+	}
+	if b.exists(constructFoodKey(item.Id)) {
+		return alreadyExists("food", item.Id)
+	}
+	if err := checkFacility(b.stub, item.FacilityId); err != nil {
+		return err
+	}
+	certifications, err := checkCertificationClaims(b.stub, foodAsset, item.CertificateIds, item.OwnerId, item.FacilityId)
+	if err != nil {
+		return err
+	}
+	if item.Serial != "" {
+		if b.exists(constructSerialKey(item.Serial)) {
+			return alreadyExists("serial", item.Serial)
+		}
+		b.putBytes(constructSerialKey(item.Serial), []byte(item.Id))
+	}
+
+	food := &Food{
+		Name:           item.Name,
+		Id:             item.Id,
+		Metadata:       item.Metadata,
+		Ingredients:    make([]string, 0),
+		Foods:          make([]string, 0),
+		Allergens:      make([]string, 0),
+		AllergenFree:   parseAllergens(strings.Join(item.AllergenFree, ",")),
+		Certifications: certifications,
+		Serial:         item.Serial,
+		EnrolledAt:     enrolledAt,
+	}
+	if err := b.put(constructFoodKey(item.Id), food); err != nil {
+		return fmt.Errorf("marshal food error: %s", err)
+	}
+	if err := b.putClaims(foodAsset, item.Id, certifications); err != nil {
+		return err
+	}
+	foodAsset.addHolding(user, item.Id)
+	b.endorse(constructFoodKey(item.Id), item.OwnerId)
+
+	return b.putHistory(foodAsset, item.Id, originOwner, item.OwnerId, item.FacilityId)
+}
+
+func (b *batch) exchangeIngredient(item *IngredientExchangeItem) error {
+	if item.OwnerId == "" || item.IngredientId == "" || item.CurrentOwnerId == "" {
+		return invalidArgs()
+	}
+
+	originOwner, err := b.user(item.OwnerId)
+	if err != nil {
+		return err
+	}
+	currentOwner, err := b.user(item.CurrentOwnerId)
+	if err != nil {
+		return err
+	}
+	if !b.exists(constructIngredientKey(item.IngredientId)) {
+		return notFound("ingredient", item.IngredientId)
+	}
+	if err := checkNotPacked(b.stub, ingredientAsset, item.IngredientId); err != nil {
+		return err
+	}
+	if err := checkFacility(b.stub, item.FacilityId); err != nil {
+		return err
+	}
+
+	if !ingredientAsset.removeHolding(originOwner, item.IngredientId) {
+		return ownerMismatch("ingredient", item.IngredientId)
+	}
+	ingredientAsset.addHolding(currentOwner, item.IngredientId)
+	b.endorse(constructIngredientKey(item.IngredientId), item.CurrentOwnerId)
+
+	return b.putHistory(ingredientAsset, item.IngredientId, item.OwnerId, item.CurrentOwnerId, item.FacilityId)
+}
+
+// 批量食材登记
+func (c *IngredientsExchangeCC) ingredientEnrollBatch(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	items := make([]*IngredientEnrollItem, 0)
+	if err := decodeBatch(args, &items); err != nil {
+		return errorResponse(err)
+	}
+	if err := checkBatchSize(stub, len(items)); err != nil {
+		return errorResponse(err)
+	}
+
+	b := newBatch(stub)
+	for i, item := range items {
+		b.result(i, item.Id, b.enrollIngredient(item))
+	}
+
+	return b.commit()
+}
+
+// 批量食品登记
+func (c *IngredientsExchangeCC) foodEnrollBatch(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	items := make([]*FoodEnrollItem, 0)
+	if err := decodeBatch(args, &items); err != nil {
+		return errorResponse(err)
+	}
+	if err := checkBatchSize(stub, len(items)); err != nil {
+		return errorResponse(err)
+	}
+
+	enrolledAt, err := txTimestamp(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get tx timestamp error: %s", err))
+	}
+
+	b := newBatch(stub)
+	for i, item := range items {
+		b.result(i, item.Id, b.enrollFood(item, enrolledAt))
+	}
+
+	return b.commit()
+}
+
+// 批量食材变更
+func (c *IngredientsExchangeCC) ingredientExchangeBatch(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	items := make([]*IngredientExchangeItem, 0)
+	if err := decodeBatch(args, &items); err != nil {
+		return errorResponse(err)
+	}
+	if err := checkBatchSize(stub, len(items)); err != nil {
+		return errorResponse(err)
+	}
+
+	b := newBatch(stub)
+	for i, item := range items {
+		b.result(i, item.IngredientId, b.exchangeIngredient(item))
+	}
+
+	return b.commit()
+}
@@ -0,0 +1,230 @@
+package food_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+	"github.com/Blockchain-book/Fabric-Food/simulator"
+)
+
+// 批量上限为 2, 注册了 u1、u2 两个用户, u1 持有食材 i0
+func newBatchLedger(t *testing.T) *simulator.Simulator {
+	sim, err := simulator.New(`{"admin_msps":["Org1MSP"],"max_batch_size":2}`, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.AddIdentity("admin", &gateway.Identity{MSPID: "Org1MSP"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []food.Request{
+		&food.UserRegisterRequest{Name: "alice", Id: "u1"},
+		&food.UserRegisterRequest{Name: "bob", Id: "u2"},
+		&food.IngredientEnrollRequest{Name: "flour", Id: "i0", OwnerId: "u1"},
+	} {
+		mustExecute(t, sim, req)
+	}
+
+	return sim
+}
+
+// 执行批量请求, 返回每条结果; 整批被拒绝时结果取自错误信息
+func executeBatch(t *testing.T, sim *simulator.Simulator, req food.Request) ([]*food.BatchResult, string) {
+	payload, err := sim.Invoke("admin", req.Function(), req.Args())
+	code := ""
+	if err != nil {
+		ccErr, ok := err.(*gateway.ChaincodeError)
+		if !ok || ccErr.Detail == nil {
+			t.Fatalf("%s: %v", req.Function(), err)
+		}
+		code = ccErr.Detail.Code
+		if code != food.CodeBatchRejected {
+			return nil, code
+		}
+		payload = []byte(strings.TrimPrefix(ccErr.Detail.Message, "batch rejected: "))
+	}
+
+	results := make([]*food.BatchResult, 0)
+	if err := json.Unmarshal(payload, &results); err != nil {
+		t.Fatalf("%s: unmarshal results error: %s: %s", req.Function(), err, payload)
+	}
+
+	return results, code
+}
+
+// 每条结果的错误码, 成功为空
+func resultCodes(results []*food.BatchResult) []string {
+	codes := make([]string, 0, len(results))
+	for i, result := range results {
+		if result.Index != i {
+			return nil
+		}
+		codes = append(codes, result.Code)
+	}
+
+	return codes
+}
+
+func userHoldings(t *testing.T, sim *simulator.Simulator, userId string) *food.User {
+	user := new(food.User)
+	if err := json.Unmarshal(mustExecute(t, sim, &food.QueryUserRequest{Id: userId}), user); err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+func TestBatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   food.Request
+		code  string
+		items []string
+		// 执行后 u1、u2 持有的食材和食品
+		u1 []string
+		u2 []string
+	}{
+		{
+			name: "enroll ingredients",
+			req: &food.IngredientEnrollBatchRequest{Items: []*food.IngredientEnrollItem{
+				{Name: "salt", Id: "i1", OwnerId: "u1"},
+				{Name: "sugar", Id: "i2", OwnerId: "u2"},
+			}},
+			items: []string{"", ""},
+			u1:    []string{"i0", "i1"},
+			u2:    []string{"i2"},
+		},
+		{
+			name: "duplicate ingredient in batch",
+			req: &food.IngredientEnrollBatchRequest{Items: []*food.IngredientEnrollItem{
+				{Name: "salt", Id: "i1", OwnerId: "u1"},
+				{Name: "salt", Id: "i1", OwnerId: "u1"},
+			}},
+			code:  food.CodeBatchRejected,
+			items: []string{"", food.CodeAlreadyExists},
+			u1:    []string{"i0"},
+		},
+		{
+			name: "ingredient already on ledger",
+			req: &food.IngredientEnrollBatchRequest{Items: []*food.IngredientEnrollItem{
+				{Name: "flour", Id: "i0", OwnerId: "u2"},
+				{Name: "salt", Id: "i1", OwnerId: "u2"},
+			}},
+			code:  food.CodeBatchRejected,
+			items: []string{food.CodeAlreadyExists, ""},
+			u1:    []string{"i0"},
+		},
+		{
+			name: "unknown owner",
+			req: &food.IngredientEnrollBatchRequest{Items: []*food.IngredientEnrollItem{
+				{Name: "salt", Id: "i1", OwnerId: "u1"},
+				{Name: "sugar", Id: "i2", OwnerId: "u9"},
+			}},
+			code:  food.CodeBatchRejected,
+			items: []string{"", food.CodeNotFound},
+			u1:    []string{"i0"},
+		},
+		{
+			name: "enroll foods",
+			req: &food.FoodEnrollBatchRequest{Items: []*food.FoodEnrollItem{
+				{Name: "bread", Id: "f1", OwnerId: "u1", Serial: "SN1"},
+				{Name: "cake", Id: "f2", OwnerId: "u2", Serial: "SN2"},
+			}},
+			items: []string{"", ""},
+			u1:    []string{"i0", "f1"},
+			u2:    []string{"f2"},
+		},
+		{
+			name: "duplicate serial in batch",
+			req: &food.FoodEnrollBatchRequest{Items: []*food.FoodEnrollItem{
+				{Name: "bread", Id: "f1", OwnerId: "u1", Serial: "SN1"},
+				{Name: "cake", Id: "f2", OwnerId: "u2", Serial: "SN1"},
+			}},
+			code:  food.CodeBatchRejected,
+			items: []string{"", food.CodeAlreadyExists},
+			u1:    []string{"i0"},
+		},
+		{
+			name: "exchange back and forth",
+			req: &food.IngredientExchangeBatchRequest{Items: []*food.IngredientExchangeItem{
+				{OwnerId: "u1", IngredientId: "i0", CurrentOwnerId: "u2"},
+				{OwnerId: "u2", IngredientId: "i0", CurrentOwnerId: "u1"},
+			}},
+			items: []string{"", ""},
+			u1:    []string{"i0"},
+		},
+		{
+			name: "exchange from wrong owner",
+			req: &food.IngredientExchangeBatchRequest{Items: []*food.IngredientExchangeItem{
+				{OwnerId: "u1", IngredientId: "i0", CurrentOwnerId: "u2"},
+				{OwnerId: "u1", IngredientId: "i0", CurrentOwnerId: "u2"},
+			}},
+			code:  food.CodeBatchRejected,
+			items: []string{"", food.CodeOwnerMismatch},
+			u1:    []string{"i0"},
+		},
+		{
+			name: "exchange unknown ingredient",
+			req: &food.IngredientExchangeBatchRequest{Items: []*food.IngredientExchangeItem{
+				{OwnerId: "u1", IngredientId: "i0", CurrentOwnerId: "u2"},
+				{OwnerId: "u1", IngredientId: "i9", CurrentOwnerId: "u2"},
+			}},
+			code:  food.CodeBatchRejected,
+			items: []string{"", food.CodeNotFound},
+			u1:    []string{"i0"},
+		},
+		{
+			name: "over max batch size",
+			req: &food.IngredientEnrollBatchRequest{Items: []*food.IngredientEnrollItem{
+				{Name: "salt", Id: "i1", OwnerId: "u1"},
+				{Name: "sugar", Id: "i2", OwnerId: "u1"},
+				{Name: "yeast", Id: "i3", OwnerId: "u1"},
+			}},
+			code: food.CodeInvalidArgument,
+			u1:   []string{"i0"},
+		},
+		{
+			name: "empty batch",
+			req:  &food.IngredientEnrollBatchRequest{Items: []*food.IngredientEnrollItem{}},
+			code: food.CodeInvalidArgument,
+			u1:   []string{"i0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newBatchLedger(t)
+			results, code := executeBatch(t, sim, tt.req)
+			if code != tt.code {
+				t.Fatalf("expected %q, got %q", tt.code, code)
+			}
+			if tt.items != nil && strings.Join(resultCodes(results), ",") != strings.Join(tt.items, ",") {
+				t.Errorf("expected item codes %q, got %+v", tt.items, results)
+			}
+
+			// 被拒绝的批次不写入任何条目
+			for userId, expected := range map[string][]string{"u1": tt.u1, "u2": tt.u2} {
+				user := userHoldings(t, sim, userId)
+				holdings := append(append([]string{}, user.Ingredients...), user.Foods...)
+				if strings.Join(holdings, ",") != strings.Join(expected, ",") {
+					t.Errorf("%s: expected holdings %v, got %v", userId, expected, holdings)
+				}
+			}
+			if tt.code != "" {
+				for _, id := range []string{"i1", "i2"} {
+					if _, code, _ := execute(sim, &food.QueryIngredientRequest{Id: id}); code != food.CodeNotFound {
+						t.Errorf("expected ingredient %s not enrolled, got %q", id, code)
+					}
+				}
+				for _, serial := range []string{"SN1", "SN2"} {
+					if _, code, _ := execute(sim, &food.VerifyProductRequest{Serial: serial}); code != food.CodeNotFound {
+						t.Errorf("expected serial %s not registered, got %q", serial, code)
+					}
+				}
+			}
+		})
+	}
+}
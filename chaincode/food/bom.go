@@ -0,0 +1,279 @@
+package food
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// 食品溯源树, 可以任意层级嵌套
+type FoodProvenance struct {
+	Food        *Food                   `json:"food"`
+	History     []*FoodHistory          `json:"history"`
+	Route       []*RouteStop            `json:"route"`
+	Ingredients []*IngredientProvenance `json:"ingredients"`
+	Foods       []*FoodProvenance       `json:"foods"`
+}
+
+// 食材溯源
+type IngredientProvenance struct {
+	Ingredient *Ingredient          `json:"ingredient"`
+	History    []*IngredientHistory `json:"history"`
+	Route      []*RouteStop         `json:"route"`
+}
+
+func getFood(stub shim.ChaincodeStubInterface, foodId string) (*Food, error) {
+	foodBytes, err := stub.GetState(constructFoodKey(foodId))
+	if err != nil {
+		return nil, err
+	}
+	if len(foodBytes) == 0 {
+		return nil, nil
+	}
+
+	food := new(Food)
+	if err := unmarshalDoc(foodBytes, food); err != nil {
+		return nil, err
+	}
+
+	return food, nil
+}
+
+// 判断 targetId 是否出现在 foodId 的配料树中(包含自身)
+func foodContains(stub shim.ChaincodeStubInterface, foodId, targetId string, visited map[string]bool) (bool, error) {
+	if foodId == targetId {
+		return true, nil
+	}
+	if visited[foodId] {
+		return false, nil
+	}
+	visited[foodId] = true
+
+	food, err := getFood(stub, foodId)
+	if err != nil || food == nil {
+		return false, err
+	}
+	for _, subFoodId := range food.Foods {
+		found, err := foodContains(stub, subFoodId, targetId, visited)
+		if err != nil || found {
+			return found, err
+		}
+	}
+
+	return false, nil
+}
+
+// 食品作为原料加入另一个食品
+func (c *IngredientsExchangeCC) foodExchangeFood(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 3, 4); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	ownerId := args[0]
+	foodId := args[1]
+	targetFoodId := args[2]
+	facilityId := facilityArg(args, 3)
+	if ownerId == "" || foodId == "" || targetFoodId == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	originOwnerBytes, err := stub.GetState(constructUserKey(ownerId))
+	if err != nil || len(originOwnerBytes) == 0 {
+		return errorResponse(notFound("user", ownerId))
+	}
+
+	food, err := getFood(stub, foodId)
+	if err != nil || food == nil {
+		return errorResponse(notFound("food", foodId))
+	}
+
+	targetFood, err := getFood(stub, targetFoodId)
+	if err != nil || targetFood == nil {
+		return errorResponse(notFound("target food", targetFoodId))
+	}
+
+	// 校验原始拥有者确实拥有当前变更的食品
+	originOwner := new(User)
+	// 反序列化用户
+	if err := unmarshalDoc(originOwnerBytes, originOwner); err != nil {
+		return errorResponse(fmt.Errorf("unmarshal user error: %s", err))
+	}
+	if !foodAsset.owns(originOwner, foodId) {
+		return errorResponse(ownerMismatch("food", foodId))
+	}
+	if err := checkNotPacked(stub, foodAsset, foodId); err != nil {
+		return errorResponse(err)
+	}
+	if err := checkFacility(stub, facilityId); err != nil {
+		return errorResponse(err)
+	}
+
+	// 需要多方审批的食品不能通过合并绕过审批
+	_, policy, err := foodApprovalPolicy(stub, foodId)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get approval policy error: %s", err))
+	}
+	if policy != nil {
+		return errorResponse(newError(CodeFailedPrecondition, "food %s requires approval, cannot be merged", foodId).withEntity(foodId))
+	}
+
+	// 检测配料环
+	cycle, err := foodContains(stub, foodId, targetFoodId, make(map[string]bool))
+	if err != nil {
+		return errorResponse(fmt.Errorf("query food error: %s", err))
+	}
+	if cycle {
+		return errorResponse(newError(CodeFailedPrecondition, "food composition cycle detected").withEntity(foodId))
+	}
+
+	// 校验过敏原与目标食品的无过敏原声明不冲突
+	if conflicts := allergenConflicts(targetFood, food.Allergens); len(conflicts) != 0 {
+		return errorResponse(newError(CodeFailedPrecondition, "allergen conflict: food declared free of %s", strings.Join(conflicts, ",")).withEntity(targetFoodId))
+	}
+
+	//写入状态
+	foodAsset.removeHolding(originOwner, foodId)
+	if err := putUser(stub, originOwner); err != nil {
+		return errorResponse(fmt.Errorf("update user error: %s", err))
+	}
+
+	// 目标食品插入食品id, 合并过敏原
+	targetFood.Foods = append(targetFood.Foods, foodId)
+	targetFood.Allergens = mergeAllergens(targetFood.Allergens, food.Allergens)
+
+	if err := putFood(stub, targetFood); err != nil {
+		return errorResponse(fmt.Errorf("update food error: %s", err))
+	}
+
+	// 插入变更记录
+	if err := foodAsset.putHistory(stub, foodId, ownerId, targetFoodId, facilityId); err != nil {
+		return errorResponse(err)
+	}
+	if err := followEndorsement(stub, constructFoodKey(foodId), constructFoodKey(targetFoodId)); err != nil {
+		return errorResponse(err)
+	}
+
+	return shim.Success(nil)
+}
+
+// 递归构建食品溯源树
+func buildFoodProvenance(stub shim.ChaincodeStubInterface, food *Food, visited map[string]bool) (*FoodProvenance, error) {
+	visited[food.Id] = true
+
+	provenance := &FoodProvenance{
+		Food:        food,
+		History:     make([]*FoodHistory, 0),
+		Ingredients: make([]*IngredientProvenance, 0),
+		Foods:       make([]*FoodProvenance, 0),
+	}
+
+	values, err := foodAsset.historyValues(stub, food.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		history := new(FoodHistory)
+		if err := unmarshalDoc(value, history); err != nil {
+			return nil, err
+		}
+		provenance.History = append(provenance.History, history)
+	}
+	if provenance.Route, err = buildRoute(stub, values); err != nil {
+		return nil, err
+	}
+
+	for _, ingredientId := range food.Ingredients {
+		ingredientBytes, err := stub.GetState(constructIngredientKey(ingredientId))
+		if err != nil {
+			return nil, err
+		}
+
+		ingredient := &Ingredient{Id: ingredientId}
+		if len(ingredientBytes) != 0 {
+			if err := unmarshalDoc(ingredientBytes, ingredient); err != nil {
+				return nil, err
+			}
+		}
+
+		ingredientProvenance := &IngredientProvenance{
+			Ingredient: ingredient,
+			History:    make([]*IngredientHistory, 0),
+		}
+		values, err := ingredientAsset.historyValues(stub, ingredientId)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			history := new(IngredientHistory)
+			if err := unmarshalDoc(value, history); err != nil {
+				return nil, err
+			}
+			ingredientProvenance.History = append(ingredientProvenance.History, history)
+		}
+		if ingredientProvenance.Route, err = buildRoute(stub, values); err != nil {
+			return nil, err
+		}
+		provenance.Ingredients = append(provenance.Ingredients, ingredientProvenance)
+	}
+
+	for _, subFoodId := range food.Foods {
+		// 防御账本中已存在的环
+		if visited[subFoodId] {
+			continue
+		}
+
+		subFood, err := getFood(stub, subFoodId)
+		if err != nil {
+			return nil, err
+		}
+		if subFood == nil {
+			continue
+		}
+
+		subProvenance, err := buildFoodProvenance(stub, subFood, visited)
+		if err != nil {
+			return nil, err
+		}
+		provenance.Foods = append(provenance.Foods, subProvenance)
+	}
+
+	return provenance, nil
+}
+
+// 食品溯源查询
+func (c *IngredientsExchangeCC) queryFoodProvenance(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	foodId := args[0]
+	if foodId == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	food, err := getFood(stub, foodId)
+	if err != nil || food == nil {
+		return errorResponse(notFound("food", foodId))
+	}
+
+	provenance, err := buildFoodProvenance(stub, food, make(map[string]bool))
+	if err != nil {
+		return errorResponse(fmt.Errorf("query provenance error: %s", err))
+	}
+
+	provenanceBytes, err := json.Marshal(provenance)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(provenanceBytes)
+}
@@ -0,0 +1,482 @@
+package food
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// 认证机构角色, 在配置的 roles 中登记认证机构的MSP
+	roleCertifier = "certifier"
+
+	// 证书可以颁发给设施
+	holderFacility = "facility"
+
+	certificateActive  = "active"
+	certificateRevoked = "revoked"
+
+	// 认证声明的状态
+	claimCertified   = "certified"
+	claimUncertified = "uncertified"
+
+	// 证书持有者索引: certHolder [holderType, holderId, certificateId]
+	certHolderIndexType = "certHolder"
+	// 声明了证书的资产索引: certClaim [certificateId, kind, assetId]
+	certClaimIndexType = "certClaim"
+)
+
+// 认证证书, 颁发给用户或设施
+type Certificate struct {
+	Id            string   `json:"id"`
+	Scheme        string   `json:"scheme"`
+	IssuerMSP     string   `json:"issuer_msp"`
+	HolderType    string   `json:"holder_type"`
+	HolderId      string   `json:"holder_id"`
+	Scope         []string `json:"scope"`
+	ValidFrom     string   `json:"valid_from"`
+	ValidTo       string   `json:"valid_to"`
+	Status        string   `json:"status"`
+	IssuedAt      string   `json:"issued_at"`
+	RevokedAt     string   `json:"revoked_at,omitempty"`
+	RevokeReason  string   `json:"revoke_reason,omitempty"`
+	SchemaVersion int      `json:"schema_version"`
+}
+
+// 资产登记时声明的认证
+type CertificationClaim struct {
+	CertificateId string `json:"certificate_id"`
+	Scheme        string `json:"scheme"`
+	Status        string `json:"status"`
+}
+
+func constructCertificateKey(certificateId string) string {
+	return fmt.Sprintf("certificate_%s", certificateId)
+}
+
+func getCertificate(stub shim.ChaincodeStubInterface, certificateId string) (*Certificate, error) {
+	certificateBytes, err := stub.GetState(constructCertificateKey(certificateId))
+	if err != nil || len(certificateBytes) == 0 {
+		return nil, err
+	}
+
+	certificate := new(Certificate)
+	if err := unmarshalDoc(certificateBytes, certificate); err != nil {
+		return nil, err
+	}
+
+	return certificate, nil
+}
+
+func putCertificate(stub shim.ChaincodeStubInterface, certificate *Certificate) error {
+	certificateBytes, err := marshalDoc(certificate)
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(constructCertificateKey(certificate.Id), certificateBytes)
+}
+
+// 证书是否覆盖该类资产
+func (cert *Certificate) covers(kind string) bool {
+	for _, scope := range cert.Scope {
+		if scope == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 证书在给定时间是否有效
+func (cert *Certificate) validAt(timestamp string) bool {
+	if cert.Status != certificateActive {
+		return false
+	}
+
+	now, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return false
+	}
+	validFrom, err := time.Parse(time.RFC3339, cert.ValidFrom)
+	if err != nil {
+		return false
+	}
+	validTo, err := time.Parse(time.RFC3339, cert.ValidTo)
+	if err != nil {
+		return false
+	}
+
+	return !now.Before(validFrom) && !now.After(validTo)
+}
+
+// 校验登记时声明的认证
+// 证书必须在交易时间有效, 覆盖该类资产, 并且颁发给资产拥有者或登记设施
+func checkCertificationClaims(stub shim.ChaincodeStubInterface, k *assetKind, certificateIds []string, ownerId, facilityId string) ([]*CertificationClaim, error) {
+	claims := make([]*CertificationClaim, 0)
+	if len(certificateIds) == 0 {
+		return claims, nil
+	}
+
+	timestamp, err := txTimestamp(stub)
+	if err != nil {
+		return nil, fmt.Errorf("get tx timestamp error: %s", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, certificateId := range certificateIds {
+		if seen[certificateId] {
+			continue
+		}
+		seen[certificateId] = true
+
+		cert, err := getCertificate(stub, certificateId)
+		if err != nil || cert == nil {
+			return nil, notFound("certificate", certificateId)
+		}
+		if !cert.validAt(timestamp) {
+			return nil, newError(CodeFailedPrecondition, "certificate not valid: %s", certificateId).withEntity(certificateId)
+		}
+		if !cert.covers(k.name) {
+			return nil, newError(CodeFailedPrecondition, "certificate scope not match: %s", certificateId).withEntity(certificateId)
+		}
+		holderMatch := cert.HolderType == holderUser && cert.HolderId == ownerId
+		if facilityId != "" && cert.HolderType == holderFacility && cert.HolderId == facilityId {
+			holderMatch = true
+		}
+		if !holderMatch {
+			return nil, newError(CodeFailedPrecondition, "certificate holder not match: %s", certificateId).withEntity(certificateId)
+		}
+
+		claims = append(claims, &CertificationClaim{
+			CertificateId: cert.Id,
+			Scheme:        cert.Scheme,
+			Status:        claimCertified,
+		})
+	}
+
+	return claims, nil
+}
+
+func certClaimKey(stub shim.ChaincodeStubInterface, certificateId string, k *assetKind, assetId string) (string, error) {
+	return stub.CreateCompositeKey(certClaimIndexType, []string{certificateId, k.name, assetId})
+}
+
+// 写入资产的认证声明索引, 撤销证书时据此找到依赖的资产
+func putCertificationClaims(stub shim.ChaincodeStubInterface, k *assetKind, assetId string, claims []*CertificationClaim) error {
+	for _, claim := range claims {
+		key, err := certClaimKey(stub, claim.CertificateId, k, assetId)
+		if err != nil {
+			return fmt.Errorf("create key error: %s", err)
+		}
+		if err := stub.PutState(key, []byte(assetId)); err != nil {
+			return fmt.Errorf("save certification claim error: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// 把资产上该证书的声明标记为未认证, 返回是否有变化
+func uncertifyClaims(claims []*CertificationClaim, certificateId string) bool {
+	changed := false
+	for _, claim := range claims {
+		if claim.CertificateId == certificateId && claim.Status != claimUncertified {
+			claim.Status = claimUncertified
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// 撤销证书后更新依赖的资产
+func uncertifyAsset(stub shim.ChaincodeStubInterface, kind, assetId, certificateId string) (bool, error) {
+	switch kind {
+	case ingredientAsset.name:
+		ingredientBytes, err := stub.GetState(constructIngredientKey(assetId))
+		if err != nil || len(ingredientBytes) == 0 {
+			return false, err
+		}
+		ingredient := new(Ingredient)
+		if err := unmarshalDoc(ingredientBytes, ingredient); err != nil {
+			return false, err
+		}
+		if !uncertifyClaims(ingredient.Certifications, certificateId) {
+			return false, nil
+		}
+		ingredientBytes, err = marshalDoc(ingredient)
+		if err != nil {
+			return false, err
+		}
+		return true, stub.PutState(constructIngredientKey(assetId), ingredientBytes)
+	case foodAsset.name:
+		food, err := getFood(stub, assetId)
+		if err != nil || food == nil {
+			return false, err
+		}
+		if !uncertifyClaims(food.Certifications, certificateId) {
+			return false, nil
+		}
+		return true, putFood(stub, food)
+	default:
+		return false, nil
+	}
+}
+
+// 证书颁发, 仅限认证机构
+// 参数: certificateId, scheme(organic|halal|pdo...), holderType(user|facility), holderId, 逗号分隔的scope(ingredient,food), validFrom, validTo
+func (c *IngredientsExchangeCC) certificateIssue(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 7, 7); err != nil {
+		return errorResponse(err)
+	}
+
+	cfg, err := getConfig(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get config error: %s", err))
+	}
+	msp, err := clientMSPID(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get client identity error: %s", err))
+	}
+	if !cfg.hasRole(roleCertifier, msp) {
+		return errorResponse(newError(CodeForbidden, "forbidden: %s is not a certification body", msp))
+	}
+
+	//验证参数的正确性
+	certificateId := args[0]
+	scheme := strings.ToLower(strings.TrimSpace(args[1]))
+	holderType := args[2]
+	holderId := args[3]
+	if certificateId == "" || scheme == "" || holderId == "" {
+		return errorResponse(invalidArgs())
+	}
+	if holderType != holderUser && holderType != holderFacility {
+		return errorResponse(invalidArgs())
+	}
+
+	scope := parseIdList(args[4])
+	if len(scope) == 0 {
+		return errorResponse(newError(CodeInvalidArgument, "invalid scope").withField("scope"))
+	}
+	for _, kind := range scope {
+		if kind != ingredientAsset.name && kind != foodAsset.name {
+			return errorResponse(newError(CodeInvalidArgument, "invalid scope").withField("scope"))
+		}
+	}
+
+	validFrom, err := time.Parse(time.RFC3339, args[5])
+	if err != nil {
+		return errorResponse(newError(CodeInvalidArgument, "invalid valid_from").withField("valid_from"))
+	}
+	validTo, err := time.Parse(time.RFC3339, args[6])
+	if err != nil || !validTo.After(validFrom) {
+		return errorResponse(newError(CodeInvalidArgument, "invalid valid_to").withField("valid_to"))
+	}
+
+	//验证数据是否存在
+	switch holderType {
+	case holderUser:
+		if user, err := getUser(stub, holderId); err != nil || user == nil {
+			return errorResponse(notFound("user", holderId))
+		}
+	case holderFacility:
+		if err := checkFacility(stub, holderId); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	if cert, err := getCertificate(stub, certificateId); err == nil && cert != nil {
+		return errorResponse(alreadyExists("certificate", certificateId))
+	}
+
+	issuedAt, err := txTimestamp(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get tx timestamp error: %s", err))
+	}
+
+	//写入状态
+	cert := &Certificate{
+		Id:         certificateId,
+		Scheme:     scheme,
+		IssuerMSP:  msp,
+		HolderType: holderType,
+		HolderId:   holderId,
+		Scope:      scope,
+		ValidFrom:  validFrom.UTC().Format(time.RFC3339),
+		ValidTo:    validTo.UTC().Format(time.RFC3339),
+		Status:     certificateActive,
+		IssuedAt:   issuedAt,
+	}
+	if err := putCertificate(stub, cert); err != nil {
+		return errorResponse(fmt.Errorf("save certificate error: %s", err))
+	}
+
+	holderKey, err := stub.CreateCompositeKey(certHolderIndexType, []string{holderType, holderId, certificateId})
+	if err != nil {
+		return errorResponse(fmt.Errorf("create key error: %s", err))
+	}
+	if err := stub.PutState(holderKey, []byte(certificateId)); err != nil {
+		return errorResponse(fmt.Errorf("save certificate index error: %s", err))
+	}
+
+	return shim.Success(nil)
+}
+
+// 证书撤销, 仅限颁发机构或管理员
+// 声明了该证书的食材/食品标记为未认证, 返回受影响的资产
+func (c *IngredientsExchangeCC) certificateRevoke(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	certificateId := args[0]
+	reason := args[1]
+	if certificateId == "" || reason == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	cert, err := getCertificate(stub, certificateId)
+	if err != nil || cert == nil {
+		return errorResponse(notFound("certificate", certificateId))
+	}
+	if cert.Status == certificateRevoked {
+		return errorResponse(newError(CodeConflict, "certificate already revoked").withEntity(certificateId))
+	}
+
+	cfg, err := getConfig(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get config error: %s", err))
+	}
+	msp, err := clientMSPID(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get client identity error: %s", err))
+	}
+	if msp != cert.IssuerMSP && !cfg.isAdmin(msp) {
+		return errorResponse(newError(CodeForbidden, "forbidden: %s is not the issuer", msp))
+	}
+
+	//写入状态
+	cert.Status = certificateRevoked
+	cert.RevokeReason = reason
+	cert.RevokedAt, err = txTimestamp(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get tx timestamp error: %s", err))
+	}
+	if err := putCertificate(stub, cert); err != nil {
+		return errorResponse(fmt.Errorf("save certificate error: %s", err))
+	}
+
+	result, err := stub.GetStateByPartialCompositeKey(certClaimIndexType, []string{certificateId})
+	if err != nil {
+		return errorResponse(fmt.Errorf("query claims error: %s", err))
+	}
+	defer result.Close()
+
+	uncertified := make([]string, 0)
+	for result.HasNext() {
+		claimVal, err := result.Next()
+		if err != nil {
+			return errorResponse(fmt.Errorf("query error: %s", err))
+		}
+		_, attrs, err := stub.SplitCompositeKey(claimVal.GetKey())
+		if err != nil || len(attrs) != 3 {
+			return errorResponse(fmt.Errorf("invalid claim key: %s", claimVal.GetKey()))
+		}
+
+		changed, err := uncertifyAsset(stub, attrs[1], attrs[2], certificateId)
+		if err != nil {
+			return errorResponse(fmt.Errorf("update %s %s error: %s", attrs[1], attrs[2], err))
+		}
+		if changed {
+			uncertified = append(uncertified, fmt.Sprintf("%s_%s", attrs[1], attrs[2]))
+		}
+	}
+
+	uncertifiedBytes, err := json.Marshal(uncertified)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(uncertifiedBytes)
+}
+
+// 证书查询
+func (c *IngredientsExchangeCC) queryCertificate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	certificateId := args[0]
+	if certificateId == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	certificateBytes, err := stub.GetState(constructCertificateKey(certificateId))
+	if err != nil || len(certificateBytes) == 0 {
+		return errorResponse(notFound("certificate", certificateId))
+	}
+
+	// 旧版本文档按当前结构返回
+	certificateBytes, err = upgradeDoc(docCertificate, certificateBytes)
+	if err != nil {
+		return errorResponse(fmt.Errorf("upgrade error: %s", err))
+	}
+
+	return shim.Success(certificateBytes)
+}
+
+// 查询颁发给用户或设施的全部证书
+func (c *IngredientsExchangeCC) queryCertificates(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	holderType := args[0]
+	holderId := args[1]
+	if (holderType != holderUser && holderType != holderFacility) || holderId == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	result, err := stub.GetStateByPartialCompositeKey(certHolderIndexType, []string{holderType, holderId})
+	if err != nil {
+		return errorResponse(fmt.Errorf("query certificates error: %s", err))
+	}
+	defer result.Close()
+
+	certs := make([]*Certificate, 0)
+	for result.HasNext() {
+		indexVal, err := result.Next()
+		if err != nil {
+			return errorResponse(fmt.Errorf("query error: %s", err))
+		}
+
+		cert, err := getCertificate(stub, string(indexVal.GetValue()))
+		if err != nil {
+			return errorResponse(fmt.Errorf("get certificate error: %s", err))
+		}
+		if cert != nil {
+			certs = append(certs, cert)
+		}
+	}
+
+	certsBytes, err := json.Marshal(certs)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(certsBytes)
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func main() {
+	err := shim.Start(new(food.IngredientsExchangeCC))
+	if err != nil {
+		fmt.Printf("Error starting AssertsExchange chaincode: %s", err)
+	}
+}
@@ -0,0 +1,368 @@
+package food
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// 保留键, 不会与 user_ food_ 等前缀冲突
+	configKey        = "config"
+	configHistoryKey = "configHistory"
+
+	maxBatchSizeLimit = 10000
+
+	// 删除用户时如何处理名下资产
+	deletionPolicyCascade = "cascade" // 删除名下食材和容器, 先拆箱
+	deletionPolicyReject  = "reject"  // 名下还有资产时拒绝删除
+	deletionPolicyRetain  = "retain"  // 只删除用户, 保留资产
+)
+
+// 多方审批策略: 持有 roles 中任一角色的 required 个不同身份批准, 且每个角色的批准数不少于其最少数后才执行
+type ApprovalPolicy struct {
+	Required int           `json:"required"`
+	Roles    ApprovalRoles `json:"roles"`
+}
+
+// 审批角色 -> 该角色最少的批准数, 例如 {"sales_manager":1,"compliance":1}
+type ApprovalRoles map[string]int
+
+// 兼容旧配置和旧申请中的角色列表, 列表中的角色没有最少数
+func (r *ApprovalRoles) UnmarshalJSON(data []byte) error {
+	list := make([]string, 0)
+	if err := json.Unmarshal(data, &list); err == nil {
+		roles := make(ApprovalRoles, len(list))
+		for _, role := range list {
+			roles[role] = 0
+		}
+		*r = roles
+		return nil
+	}
+
+	minimums := make(map[string]int)
+	if err := json.Unmarshal(data, &minimums); err != nil {
+		return err
+	}
+	*r = minimums
+
+	return nil
+}
+
+// 按名称排序的角色, 保证各背书节点上的遍历顺序一致
+func (r ApprovalRoles) names() []string {
+	names := make([]string, 0, len(r))
+	for role := range r {
+		names = append(names, role)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// 链码配置, 在Init时写入, 之后由管理员通过updateConfig修改
+type Config struct {
+	Version               int                 `json:"version"`
+	AdminMSPs             []string            `json:"admin_msps"`
+	Roles                 map[string][]string `json:"roles"`
+	MaxBatchSize          int                 `json:"max_batch_size"`
+	TransferExpirySeconds int64               `json:"transfer_expiry_seconds"`
+	DeletionPolicy        string              `json:"deletion_policy"`
+	Features              map[string]bool     `json:"features"`
+	// 资产类别 -> 多方审批策略
+	ApprovalPolicies map[string]*ApprovalPolicy `json:"approval_policies"`
+	// 资产键由拥有者所在组织背书
+	KeyLevelEndorsement bool   `json:"key_level_endorsement"`
+	UpdatedAt           string `json:"updated_at,omitempty"`
+	UpdatedBy           string `json:"updated_by,omitempty"`
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		AdminMSPs:        make([]string, 0),
+		Roles:            make(map[string][]string),
+		MaxBatchSize:     defaultMaxBatchSize,
+		DeletionPolicy:   deletionPolicyCascade,
+		Features:         make(map[string]bool),
+		ApprovalPolicies: make(map[string]*ApprovalPolicy),
+	}
+}
+
+// 读取当前配置, 尚未初始化时返回默认配置
+func getConfig(stub shim.ChaincodeStubInterface) (*Config, error) {
+	configBytes, err := stub.GetState(configKey)
+	if err != nil {
+		return nil, err
+	}
+
+	config := defaultConfig()
+	if len(configBytes) == 0 {
+		return config, nil
+	}
+	if err := json.Unmarshal(configBytes, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// 严格解析配置, 拒绝未知字段
+func parseConfig(raw string) (*Config, error) {
+	config := defaultConfig()
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, newError(CodeInvalidArgument, "invalid config: %s", err).withField("config")
+	}
+
+	return config, nil
+}
+
+func (cfg *Config) validate() error {
+	for _, msp := range cfg.AdminMSPs {
+		if msp == "" {
+			return newError(CodeInvalidArgument, "invalid config: empty admin msp").withField("config")
+		}
+	}
+	for role, msps := range cfg.Roles {
+		if role == "" {
+			return newError(CodeInvalidArgument, "invalid config: empty role name").withField("config")
+		}
+		for _, msp := range msps {
+			if msp == "" {
+				return newError(CodeInvalidArgument, "invalid config: empty msp in role %s", role).withField("config")
+			}
+		}
+	}
+	if cfg.MaxBatchSize < 1 || cfg.MaxBatchSize > maxBatchSizeLimit {
+		return newError(CodeInvalidArgument, "invalid config: max_batch_size must be between 1 and %d", maxBatchSizeLimit).withField("config")
+	}
+	if cfg.TransferExpirySeconds < 0 {
+		return newError(CodeInvalidArgument, "invalid config: transfer_expiry_seconds must not be negative").withField("config")
+	}
+	switch cfg.DeletionPolicy {
+	case deletionPolicyCascade, deletionPolicyReject, deletionPolicyRetain:
+	default:
+		return newError(CodeInvalidArgument, "invalid config: unknown deletion_policy %s", cfg.DeletionPolicy).withField("config")
+	}
+	for class, policy := range cfg.ApprovalPolicies {
+		if class == "" || policy == nil {
+			return newError(CodeInvalidArgument, "invalid config: empty approval policy").withField("config")
+		}
+		if policy.Required < 1 {
+			return newError(CodeInvalidArgument, "invalid config: approval policy %s requires at least one approval", class).withField("config")
+		}
+		if len(policy.Roles) == 0 {
+			return newError(CodeInvalidArgument, "invalid config: approval policy %s has no roles", class).withField("config")
+		}
+		minimums := 0
+		for role, minimum := range policy.Roles {
+			if role == "" {
+				return newError(CodeInvalidArgument, "invalid config: empty role in approval policy %s", class).withField("config")
+			}
+			if minimum < 0 {
+				return newError(CodeInvalidArgument, "invalid config: negative minimum for role %s in approval policy %s", role, class).withField("config")
+			}
+			minimums += minimum
+		}
+		if minimums > policy.Required {
+			return newError(CodeInvalidArgument, "invalid config: role minimums of approval policy %s exceed required", class).withField("config")
+		}
+	}
+
+	return nil
+}
+
+// 功能开关, 未配置的功能默认开启
+func (cfg *Config) enabled(feature string) bool {
+	enabled, ok := cfg.Features[feature]
+	return !ok || enabled
+}
+
+// MSP是否拥有某个角色
+func (cfg *Config) hasRole(role, msp string) bool {
+	for _, m := range cfg.Roles[role] {
+		if m == msp {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (cfg *Config) isAdmin(msp string) bool {
+	for _, m := range cfg.AdminMSPs {
+		if m == msp {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 调用者所属的MSP
+func clientMSPID(stub shim.ChaincodeStubInterface) (string, error) {
+	return cid.GetMSPID(stub)
+}
+
+// 校验调用者是管理员
+func checkAdmin(stub shim.ChaincodeStubInterface, cfg *Config) (string, error) {
+	msp, err := clientMSPID(stub)
+	if err != nil {
+		return "", fmt.Errorf("get client identity error: %s", err)
+	}
+	if !cfg.isAdmin(msp) {
+		return "", newError(CodeForbidden, "forbidden: %s is not an admin msp", msp)
+	}
+
+	return msp, nil
+}
+
+// 写入新版本配置, 同时保留历史版本
+func putConfig(stub shim.ChaincodeStubInterface, cfg *Config) error {
+	sort.Strings(cfg.AdminMSPs)
+
+	configBytes, err := json.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("marshal config error: %s", err)
+	}
+	if err := stub.PutState(configKey, configBytes); err != nil {
+		return fmt.Errorf("save config error: %s", err)
+	}
+
+	historyKey, err := stub.CreateCompositeKey(configHistoryKey, []string{fmt.Sprintf("%010d", cfg.Version)})
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+	if err := stub.PutState(historyKey, configBytes); err != nil {
+		return fmt.Errorf("save config history error: %s", err)
+	}
+
+	return nil
+}
+
+// 实例化或升级时初始化配置
+func (c *IngredientsExchangeCC) initConfig(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if err := checkArgCount(args, 0, 1); err != nil {
+		return errorResponse(err)
+	}
+
+	current, err := getConfig(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get config error: %s", err))
+	}
+
+	// 升级时未传配置, 保留原有配置
+	if len(args) == 0 || args[0] == "" {
+		if current.Version != 0 {
+			return shim.Success(nil)
+		}
+		args = []string{"{}"}
+	}
+
+	cfg, err := parseConfig(args[0])
+	if err != nil {
+		return errorResponse(err)
+	}
+	if err := cfg.validate(); err != nil {
+		return errorResponse(err)
+	}
+
+	cfg.Version = current.Version + 1
+	cfg.UpdatedAt, err = txTimestamp(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get tx timestamp error: %s", err))
+	}
+	cfg.UpdatedBy, err = clientMSPID(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get client identity error: %s", err))
+	}
+	if err := putConfig(stub, cfg); err != nil {
+		return errorResponse(err)
+	}
+
+	return shim.Success(nil)
+}
+
+// 更新配置, 仅限管理员
+func (c *IngredientsExchangeCC) updateConfig(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return errorResponse(err)
+	}
+
+	current, err := getConfig(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get config error: %s", err))
+	}
+
+	msp, err := checkAdmin(stub, current)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	cfg, err := parseConfig(args[0])
+	if err != nil {
+		return errorResponse(err)
+	}
+	if err := cfg.validate(); err != nil {
+		return errorResponse(err)
+	}
+
+	// 乐观锁: 提交的版本号必须是当前版本
+	if cfg.Version != current.Version {
+		return errorResponse(newError(CodeConflict, "config version conflict: current %d, got %d", current.Version, cfg.Version).withField("version"))
+	}
+
+	cfg.Version = current.Version + 1
+	cfg.UpdatedBy = msp
+	cfg.UpdatedAt, err = txTimestamp(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get tx timestamp error: %s", err))
+	}
+	if err := putConfig(stub, cfg); err != nil {
+		return errorResponse(err)
+	}
+
+	return shim.Success(nil)
+}
+
+// 配置查询, 可以指定历史版本
+func (c *IngredientsExchangeCC) queryConfig(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 0, 1); err != nil {
+		return errorResponse(err)
+	}
+
+	if len(args) == 0 {
+		cfg, err := getConfig(stub)
+		if err != nil {
+			return errorResponse(fmt.Errorf("get config error: %s", err))
+		}
+		configBytes, err := json.Marshal(cfg)
+		if err != nil {
+			return errorResponse(fmt.Errorf("marshal error: %s", err))
+		}
+		return shim.Success(configBytes)
+	}
+
+	version := 0
+	if _, err := fmt.Sscanf(args[0], "%d", &version); err != nil || version < 1 {
+		return errorResponse(invalidArgs())
+	}
+	historyKey, err := stub.CreateCompositeKey(configHistoryKey, []string{fmt.Sprintf("%010d", version)})
+	if err != nil {
+		return errorResponse(fmt.Errorf("create key error: %s", err))
+	}
+	configBytes, err := stub.GetState(historyKey)
+	if err != nil || len(configBytes) == 0 {
+		return errorResponse(notFound("config version", args[0]))
+	}
+
+	return shim.Success(configBytes)
+}
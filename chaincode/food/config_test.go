@@ -0,0 +1,37 @@
+package food_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Init 与 updateConfig 一样记录更新时间和调用者
+func TestInitConfigRecordsCaller(t *testing.T) {
+	sim := newAssetLedger(t)
+	req := &food.QueryConfigRequest{}
+	payload, err := sim.Query("admin", req.Function(), req.Args())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := new(food.Config)
+	if err := json.Unmarshal(payload, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Version != 1 || cfg.UpdatedAt != "2024-01-01T00:00:00Z" || cfg.UpdatedBy != "SimulatorMSP" {
+		t.Fatalf("unexpected config after init: version %d, updated %s by %s", cfg.Version, cfg.UpdatedAt, cfg.UpdatedBy)
+	}
+
+	// 读不到调用者身份时 Init 失败, 不写入没有 updated_by 的配置
+	stub := shim.NewMockStub("food", new(food.IngredientsExchangeCC))
+	res := stub.MockInit("init", [][]byte{[]byte("init"), []byte(`{"admin_msps":["Org1MSP"]}`)})
+	if res.Status == shim.OK || !strings.Contains(res.Message, "client identity") {
+		t.Fatalf("expected init to fail without a client identity, got %d %s", res.Status, res.Message)
+	}
+	if configBytes, _ := stub.GetState("config"); len(configBytes) != 0 {
+		t.Fatalf("expected no config written, got %s", configBytes)
+	}
+}
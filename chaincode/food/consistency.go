@@ -0,0 +1,427 @@
+package food
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// 资产没有任何拥有者
+	violationOrphanAsset = "orphan_asset"
+	// 资产被多个拥有者引用
+	violationMultipleOwners = "multiple_owners"
+	// 用户引用了不存在的资产
+	violationMissingAsset = "missing_asset"
+	// 食品引用了不存在的食材
+	violationDanglingIngredient = "dangling_ingredient"
+	// 食品引用了不存在的子食品
+	violationDanglingFood = "dangling_food"
+	// 容器中装有不存在的资产
+	violationDanglingContent = "dangling_content"
+	// 流通记录的最后拥有者与实际拥有者不一致
+	violationHistoryMismatch = "history_owner_mismatch"
+
+	holderUser      = "user"
+	holderFood      = "food"
+	holderContainer = "container"
+
+	defaultCheckPageSize = 100
+	maxCheckPageSize     = 1000
+)
+
+// 资产拥有者, 用户或者食品
+type AssetOwner struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+// 不一致项
+type Violation struct {
+	Type         string        `json:"type"`
+	Kind         string        `json:"kind"`
+	Id           string        `json:"id"`
+	Ref          string        `json:"ref,omitempty"`
+	Owners       []*AssetOwner `json:"owners,omitempty"`
+	HistoryOwner string        `json:"history_owner,omitempty"`
+}
+
+// 一致性检查结果
+type ConsistencyReport struct {
+	Scanned    int          `json:"scanned"`
+	Violations []*Violation `json:"violations"`
+	Bookmark   string       `json:"bookmark"`
+}
+
+// 流通记录, 同时兼容食材和食品
+type historyEdge struct {
+	IngredientId   string `json:"ingredient_id"`
+	FoodId         string `json:"food_id"`
+	OriginOwnerId  string `json:"origin_owner_id"`
+	CurrentOwnerId string `json:"current_owner_id"`
+	FacilityId     string `json:"facility_id"`
+	Type           string `json:"type"`
+}
+
+// 读取资产的流通记录, 跳过加工步骤
+func historyEdges(stub shim.ChaincodeStubInterface, kind *assetKind, id string) ([]*historyEdge, error) {
+	values, err := kind.historyValues(stub, id)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := make([]*historyEdge, 0, len(values))
+	for _, value := range values {
+		edge := new(historyEdge)
+		if err := json.Unmarshal(value, edge); err != nil {
+			return nil, err
+		}
+		if edge.Type == processStepType {
+			continue
+		}
+		edges = append(edges, edge)
+	}
+
+	return edges, nil
+}
+
+// 按流通记录推算资产的最后拥有者, 没有记录时返回false
+// 流通记录的键不含时间, 从登记记录开始沿 origin -> current 依次连接
+func lastOwnerOf(edges []*historyEdge) (string, bool) {
+	current := originOwner
+	used := make([]bool, len(edges))
+	for {
+		next := -1
+		for i, edge := range edges {
+			if !used[i] && edge.OriginOwnerId == current {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		used[next] = true
+		current = edges[next].CurrentOwnerId
+	}
+	if current == originOwner {
+		return "", false
+	}
+
+	return current, true
+}
+
+func historyLastOwner(stub shim.ChaincodeStubInterface, kind *assetKind, id string) (string, bool, error) {
+	edges, err := historyEdges(stub, kind, id)
+	if err != nil {
+		return "", false, err
+	}
+
+	lastOwner, ok := lastOwnerOf(edges)
+	return lastOwner, ok, nil
+}
+
+// 流通记录中出现过的拥有者id
+func historyParties(edges []*historyEdge) []string {
+	seen := make(map[string]bool)
+	parties := make([]string, 0)
+	for _, edge := range edges {
+		for _, id := range []string{edge.OriginOwnerId, edge.CurrentOwnerId} {
+			if id == originOwner || seen[id] {
+				continue
+			}
+			seen[id] = true
+			parties = append(parties, id)
+		}
+	}
+	sort.Strings(parties)
+
+	return parties
+}
+
+// 资产的拥有者, 只检查流通记录中出现过的用户和食品, 不扫描全账本
+// 不在流通记录中的拥有者由 checkHolder 从拥有者一侧发现
+func assetOwners(stub shim.ChaincodeStubInterface, kind *assetKind, id string, edges []*historyEdge) ([]*AssetOwner, error) {
+	owners := make([]*AssetOwner, 0)
+	for _, party := range historyParties(edges) {
+		user, err := getUser(stub, party)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil && containsId(*kind.holdings(user), id) {
+			owners = append(owners, &AssetOwner{Type: holderUser, Id: party})
+		}
+
+		if kind == containerAsset {
+			continue
+		}
+		food, err := getFood(stub, party)
+		if err != nil {
+			return nil, err
+		}
+		if food == nil {
+			continue
+		}
+		refs := food.Ingredients
+		if kind == foodAsset {
+			refs = food.Foods
+		}
+		if containsId(refs, id) {
+			owners = append(owners, &AssetOwner{Type: holderFood, Id: party})
+		}
+	}
+
+	sort.Slice(owners, func(i, j int) bool {
+		if owners[i].Type != owners[j].Type {
+			return owners[i].Type < owners[j].Type
+		}
+		return owners[i].Id < owners[j].Id
+	})
+
+	return owners, nil
+}
+
+func containsId(ids []string, id string) bool {
+	for _, aid := range ids {
+		if aid == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 检查资产的拥有者和流通记录
+func checkAsset(stub shim.ChaincodeStubInterface, kind *assetKind, id string) ([]*Violation, error) {
+	edges, err := historyEdges(stub, kind, id)
+	if err != nil {
+		return nil, err
+	}
+	owners, err := assetOwners(stub, kind, id, edges)
+	if err != nil {
+		return nil, err
+	}
+
+	violations := make([]*Violation, 0)
+	switch len(owners) {
+	case 0:
+		violations = append(violations, &Violation{
+			Type: violationOrphanAsset,
+			Kind: kind.name,
+			Id:   id,
+		})
+	case 1:
+	default:
+		violations = append(violations, &Violation{
+			Type:   violationMultipleOwners,
+			Kind:   kind.name,
+			Id:     id,
+			Owners: owners,
+		})
+	}
+
+	lastOwner, ok := lastOwnerOf(edges)
+	if !ok {
+		return violations, nil
+	}
+	for _, owner := range owners {
+		if owner.Id == lastOwner {
+			return violations, nil
+		}
+	}
+	violations = append(violations, &Violation{
+		Type:         violationHistoryMismatch,
+		Kind:         kind.name,
+		Id:           id,
+		Owners:       owners,
+		HistoryOwner: lastOwner,
+	})
+
+	return violations, nil
+}
+
+// 检查拥有者引用的资产是否存在, 以及拥有者是否出现在资产的流通记录中
+func checkHolder(stub shim.ChaincodeStubInterface, holder string, id string, ingredients, foods, containers []string) ([]*Violation, error) {
+	violations := make([]*Violation, 0)
+
+	refs := []struct {
+		kind      *assetKind
+		ids       []string
+		violation string
+	}{
+		{ingredientAsset, ingredients, violationDanglingIngredient},
+		{foodAsset, foods, violationDanglingFood},
+		{containerAsset, containers, violationMissingAsset},
+	}
+	for _, ref := range refs {
+		for _, refId := range ref.ids {
+			if !ref.kind.exists(stub, refId) {
+				violation := ref.violation
+				if holder == holderUser {
+					violation = violationMissingAsset
+				}
+				violations = append(violations, &Violation{
+					Type: violation,
+					Kind: holder,
+					Id:   id,
+					Ref:  ref.kind.key(refId),
+				})
+				continue
+			}
+
+			// 出现在流通记录中的拥有者由 checkAsset 检查
+			edges, err := historyEdges(stub, ref.kind, refId)
+			if err != nil {
+				return nil, err
+			}
+			if containsId(historyParties(edges), id) {
+				continue
+			}
+			lastOwner, _ := lastOwnerOf(edges)
+			violations = append(violations, &Violation{
+				Type:         violationHistoryMismatch,
+				Kind:         ref.kind.name,
+				Id:           refId,
+				Owners:       []*AssetOwner{{Type: holder, Id: id}},
+				HistoryOwner: lastOwner,
+			})
+		}
+	}
+
+	return violations, nil
+}
+
+// 检查容器中的资产是否存在
+func checkContainer(stub shim.ChaincodeStubInterface, container *Container) ([]*Violation, error) {
+	violations := make([]*Violation, 0)
+
+	for _, kind := range assetKinds {
+		for _, id := range *container.items(kind) {
+			if kind.exists(stub, id) {
+				continue
+			}
+			violations = append(violations, &Violation{
+				Type: violationDanglingContent,
+				Kind: holderContainer,
+				Id:   container.Id,
+				Ref:  kind.key(id),
+			})
+		}
+	}
+
+	return violations, nil
+}
+
+// 检查单个状态键, 只读取该键相关的资产、拥有者和流通记录
+func checkKey(stub shim.ChaincodeStubInterface, key string, value []byte) ([]*Violation, error) {
+	switch {
+	case strings.HasPrefix(key, "user_"):
+		user := new(User)
+		if err := unmarshalDoc(value, user); err != nil {
+			return nil, err
+		}
+		return checkHolder(stub, holderUser, user.Id, user.Ingredients, user.Foods, user.Containers)
+	case strings.HasPrefix(key, "ingredient_"):
+		ingredient := new(Ingredient)
+		if err := unmarshalDoc(value, ingredient); err != nil {
+			return nil, err
+		}
+		return checkAsset(stub, ingredientAsset, ingredient.Id)
+	case strings.HasPrefix(key, "food_"):
+		food := new(Food)
+		if err := unmarshalDoc(value, food); err != nil {
+			return nil, err
+		}
+		violations, err := checkAsset(stub, foodAsset, food.Id)
+		if err != nil {
+			return nil, err
+		}
+		dangling, err := checkHolder(stub, holderFood, food.Id, food.Ingredients, food.Foods, nil)
+		if err != nil {
+			return nil, err
+		}
+		return append(violations, dangling...), nil
+	case strings.HasPrefix(key, "container_"):
+		container := new(Container)
+		if err := unmarshalDoc(value, container); err != nil {
+			return nil, err
+		}
+		violations, err := checkAsset(stub, containerAsset, container.Id)
+		if err != nil {
+			return nil, err
+		}
+		dangling, err := checkContainer(stub, container)
+		if err != nil {
+			return nil, err
+		}
+		return append(violations, dangling...), nil
+	default:
+		return nil, nil
+	}
+}
+
+// 账本一致性检查, 只读
+// 按状态键分页扫描用户/食材/食品/容器, bookmark 为空表示扫描完成
+// 每页只读取本页实体相关的状态, 不建立全账本的拥有者索引
+func (c *IngredientsExchangeCC) checkConsistency(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 0, 2); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	pageSize := defaultCheckPageSize
+	if len(args) >= 1 && args[0] != "" {
+		var err error
+		pageSize, err = strconv.Atoi(args[0])
+		if err != nil || pageSize < 1 || pageSize > maxCheckPageSize {
+			return errorResponse(invalidArgs())
+		}
+	}
+	bookmark := ""
+	if len(args) == 2 {
+		bookmark = args[1]
+	}
+
+	iter, err := stub.GetStateByRange(bookmark, string(utf8.MaxRune))
+	if err != nil {
+		return errorResponse(fmt.Errorf("query state error: %s", err))
+	}
+	defer iter.Close()
+
+	report := &ConsistencyReport{Violations: make([]*Violation, 0)}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return errorResponse(fmt.Errorf("query error: %s", err))
+		}
+		key := kv.GetKey()
+		if !strings.HasPrefix(key, "user_") && !strings.HasPrefix(key, "ingredient_") && !strings.HasPrefix(key, "food_") && !strings.HasPrefix(key, "container_") {
+			continue
+		}
+		if report.Scanned == pageSize {
+			report.Bookmark = key
+			break
+		}
+		report.Scanned++
+
+		violations, err := checkKey(stub, key, kv.GetValue())
+		if err != nil {
+			return errorResponse(fmt.Errorf("check %s error: %s", key, err))
+		}
+		report.Violations = append(report.Violations, violations...)
+	}
+
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(reportBytes)
+}
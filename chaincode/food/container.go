@@ -0,0 +1,646 @@
+package food
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// 资产所在容器的索引: packed [kind, id] -> containerId
+	packedIndexType = "packed"
+)
+
+// 包装/运输容器, 可以装入食材/食品和其他容器
+type Container struct {
+	Name          string   `json:"name"`
+	Id            string   `json:"id"`
+	Metadata      string   `json:"metadata"`
+	Ingredients   []string `json:"ingredients"`
+	Foods         []string `json:"foods"`
+	Containers    []string `json:"containers"`
+	SchemaVersion int      `json:"schema_version"`
+}
+
+// 容器流通
+type ContainerHistory struct {
+	ContainerId    string `json:"container_id"`
+	OriginOwnerId  string `json:"origin_owner_id"`
+	CurrentOwnerId string `json:"current_owner_id"`
+	FacilityId     string `json:"facility_id,omitempty"`
+	Timestamp      string `json:"timestamp,omitempty"`
+	SchemaVersion  int    `json:"schema_version"`
+}
+
+// 容器内容, 嵌套的容器递归展开
+type ContainerContents struct {
+	Container   *Container           `json:"container"`
+	Ingredients []*Ingredient        `json:"ingredients"`
+	Foods       []*Food              `json:"foods"`
+	Containers  []*ContainerContents `json:"containers"`
+}
+
+var containerAsset = &assetKind{
+	name:        "container",
+	historyType: "containerHistory",
+	idField:     "container_id",
+	notFound:    "container not found",
+	holdings: func(user *User) *[]string {
+		return &user.Containers
+	},
+	newHistory: func(id, from, to, facilityId, timestamp string) interface{} {
+		return &ContainerHistory{
+			ContainerId:    id,
+			OriginOwnerId:  from,
+			CurrentOwnerId: to,
+			FacilityId:     facilityId,
+			Timestamp:      timestamp,
+		}
+	},
+}
+
+func constructContainerKey(containerId string) string {
+	return containerAsset.key(containerId)
+}
+
+// 容器中某类资产的列表
+func (c *Container) items(kind *assetKind) *[]string {
+	switch kind {
+	case ingredientAsset:
+		return &c.Ingredients
+	case foodAsset:
+		return &c.Foods
+	default:
+		return &c.Containers
+	}
+}
+
+func getContainer(stub shim.ChaincodeStubInterface, containerId string) (*Container, error) {
+	containerBytes, err := stub.GetState(constructContainerKey(containerId))
+	if err != nil || len(containerBytes) == 0 {
+		return nil, err
+	}
+
+	container := new(Container)
+	if err := unmarshalDoc(containerBytes, container); err != nil {
+		return nil, err
+	}
+
+	return container, nil
+}
+
+func putContainer(stub shim.ChaincodeStubInterface, container *Container) error {
+	containerBytes, err := marshalDoc(container)
+	if err != nil {
+		return fmt.Errorf("marshal container error: %s", err)
+	}
+
+	return stub.PutState(constructContainerKey(container.Id), containerBytes)
+}
+
+func packedKey(stub shim.ChaincodeStubInterface, kind *assetKind, id string) (string, error) {
+	return stub.CreateCompositeKey(packedIndexType, []string{kind.name, id})
+}
+
+// 查询资产所在的容器, 未装箱返回空
+func packedIn(stub shim.ChaincodeStubInterface, kind *assetKind, id string) (string, error) {
+	key, err := packedKey(stub, kind, id)
+	if err != nil {
+		return "", err
+	}
+	containerId, err := stub.GetState(key)
+	if err != nil {
+		return "", err
+	}
+
+	return string(containerId), nil
+}
+
+// 已装箱的资产只能随容器一起转让
+func checkNotPacked(stub shim.ChaincodeStubInterface, kind *assetKind, id string) error {
+	containerId, err := packedIn(stub, kind, id)
+	if err != nil {
+		return fmt.Errorf("query container error: %s", err)
+	}
+	if containerId != "" {
+		return newError(CodeConflict, "%s packed in container %s", kind.name, containerId).withEntity(id)
+	}
+
+	return nil
+}
+
+// 删除资产前拆箱: 删除装箱索引, 所在容器保留时从容器中移除, 修改过的容器记在 changed 中由调用方统一写入
+func unpackForDelete(stub shim.ChaincodeStubInterface, kind *assetKind, id string, deleted map[string]bool, changed map[string]*Container) error {
+	containerId, err := packedIn(stub, kind, id)
+	if err != nil {
+		return fmt.Errorf("query container error: %s", err)
+	}
+	if containerId == "" {
+		return nil
+	}
+
+	key, err := packedKey(stub, kind, id)
+	if err != nil {
+		return fmt.Errorf("create key error: %s", err)
+	}
+	if err := stub.DelState(key); err != nil {
+		return fmt.Errorf("delete container index error: %s", err)
+	}
+	if deleted[constructContainerKey(containerId)] {
+		return nil
+	}
+
+	container := changed[containerId]
+	if container == nil {
+		container, err = getContainer(stub, containerId)
+		if err != nil {
+			return fmt.Errorf("query container error: %s", err)
+		}
+		if container == nil {
+			return nil
+		}
+		changed[containerId] = container
+	}
+	items := container.items(kind)
+	*items, _ = removeId(*items, id)
+
+	return nil
+}
+
+// 删除容器前清除其中全部资产的装箱索引
+func clearPackedIndex(stub shim.ChaincodeStubInterface, container *Container) error {
+	for _, kind := range []*assetKind{ingredientAsset, foodAsset, containerAsset} {
+		for _, itemId := range *container.items(kind) {
+			key, err := packedKey(stub, kind, itemId)
+			if err != nil {
+				return fmt.Errorf("create key error: %s", err)
+			}
+			if err := stub.DelState(key); err != nil {
+				return fmt.Errorf("delete container index error: %s", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// 容器内容项
+type containerItem struct {
+	kind *assetKind
+	id   string
+}
+
+// 递归收集容器及其全部内容
+func collectContainerItems(stub shim.ChaincodeStubInterface, containerId string, visited map[string]bool) ([]*containerItem, error) {
+	if visited[containerId] {
+		return nil, nil
+	}
+	visited[containerId] = true
+
+	container, err := getContainer(stub, containerId)
+	if err != nil {
+		return nil, err
+	}
+	if container == nil {
+		return nil, notFound("container", containerId)
+	}
+
+	items := []*containerItem{{containerAsset, containerId}}
+	for _, id := range container.Ingredients {
+		items = append(items, &containerItem{ingredientAsset, id})
+	}
+	for _, id := range container.Foods {
+		items = append(items, &containerItem{foodAsset, id})
+	}
+	for _, id := range container.Containers {
+		nested, err := collectContainerItems(stub, id, visited)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, nested...)
+	}
+
+	return items, nil
+}
+
+// 判断 targetId 是否为 containerId 或嵌套在其中
+func containerContains(stub shim.ChaincodeStubInterface, containerId, targetId string, visited map[string]bool) (bool, error) {
+	if containerId == targetId {
+		return true, nil
+	}
+	if visited[containerId] {
+		return false, nil
+	}
+	visited[containerId] = true
+
+	container, err := getContainer(stub, containerId)
+	if err != nil || container == nil {
+		return false, err
+	}
+	for _, id := range container.Containers {
+		found, err := containerContains(stub, id, targetId, visited)
+		if err != nil || found {
+			return found, err
+		}
+	}
+
+	return false, nil
+}
+
+// 容器登记
+func (c *IngredientsExchangeCC) containerEnroll(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 4, 5); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	containerName := args[0]
+	containerId := args[1]
+	metadata := args[2]
+	ownerId := args[3]
+	facilityId := facilityArg(args, 4)
+	if containerName == "" || containerId == "" || ownerId == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	user, err := checkEnrollAsset(stub, containerAsset, containerId, ownerId)
+	if err != nil {
+		return errorResponse(err)
+	}
+	if err := checkFacility(stub, facilityId); err != nil {
+		return errorResponse(err)
+	}
+
+	//写入状态
+	container := &Container{
+		Name:        containerName,
+		Id:          containerId,
+		Metadata:    metadata,
+		Ingredients: make([]string, 0),
+		Foods:       make([]string, 0),
+		Containers:  make([]string, 0),
+	}
+	if err := enrollAsset(stub, containerAsset, containerId, container, user, facilityId); err != nil {
+		return errorResponse(err)
+	}
+
+	return shim.Success(nil)
+}
+
+// 装箱, 参数: ownerId, containerId, kind(ingredient|food|container), 逗号分隔的资产id
+func (c *IngredientsExchangeCC) containerPack(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 4, 4); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	ownerId := args[0]
+	containerId := args[1]
+	kind := lookupAssetKind(args[2])
+	itemIds := parseIdList(args[3])
+	if ownerId == "" || containerId == "" || kind == nil || len(itemIds) == 0 {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	owner, err := getUser(stub, ownerId)
+	if err != nil || owner == nil {
+		return errorResponse(notFound("user", ownerId))
+	}
+
+	container, err := getContainer(stub, containerId)
+	if err != nil || container == nil {
+		return errorResponse(notFound("container", containerId))
+	}
+
+	if !containerAsset.owns(owner, containerId) {
+		return errorResponse(ownerMismatch("container", containerId))
+	}
+
+	seen := make(map[string]bool)
+	for _, itemId := range itemIds {
+		if seen[itemId] {
+			return errorResponse(invalidArgs())
+		}
+		seen[itemId] = true
+		if !kind.exists(stub, itemId) {
+			return errorResponse(notFound(kind.name, itemId))
+		}
+		// 装箱不改变拥有者, 只能装入自己的资产
+		if !kind.owns(owner, itemId) {
+			return errorResponse(ownerMismatch(kind.name, itemId))
+		}
+		if err := checkNotPacked(stub, kind, itemId); err != nil {
+			return errorResponse(err)
+		}
+		// 检测容器嵌套环
+		if kind == containerAsset {
+			cycle, err := containerContains(stub, itemId, containerId, make(map[string]bool))
+			if err != nil {
+				return errorResponse(fmt.Errorf("query container error: %s", err))
+			}
+			if cycle {
+				return errorResponse(newError(CodeFailedPrecondition, "container nesting cycle detected").withEntity(itemId))
+			}
+		}
+	}
+
+	//写入状态
+	items := container.items(kind)
+	for _, itemId := range itemIds {
+		*items = append(*items, itemId)
+
+		key, err := packedKey(stub, kind, itemId)
+		if err != nil {
+			return errorResponse(fmt.Errorf("create key error: %s", err))
+		}
+		if err := stub.PutState(key, []byte(containerId)); err != nil {
+			return errorResponse(fmt.Errorf("save container index error: %s", err))
+		}
+	}
+	if err := putContainer(stub, container); err != nil {
+		return errorResponse(fmt.Errorf("update container error: %s", err))
+	}
+
+	return shim.Success(nil)
+}
+
+// 拆箱, 参数: ownerId, containerId, kind(ingredient|food|container), 逗号分隔的资产id
+func (c *IngredientsExchangeCC) containerUnpack(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 4, 4); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	ownerId := args[0]
+	containerId := args[1]
+	kind := lookupAssetKind(args[2])
+	itemIds := parseIdList(args[3])
+	if ownerId == "" || containerId == "" || kind == nil || len(itemIds) == 0 {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	owner, err := getUser(stub, ownerId)
+	if err != nil || owner == nil {
+		return errorResponse(notFound("user", ownerId))
+	}
+
+	container, err := getContainer(stub, containerId)
+	if err != nil || container == nil {
+		return errorResponse(notFound("container", containerId))
+	}
+
+	if !containerAsset.owns(owner, containerId) {
+		return errorResponse(ownerMismatch("container", containerId))
+	}
+
+	//写入状态
+	items := container.items(kind)
+	for _, itemId := range itemIds {
+		remaining, found := removeId(*items, itemId)
+		if !found {
+			return errorResponse(newError(CodeFailedPrecondition, "%s not in container", kind.name).withEntity(itemId))
+		}
+		*items = remaining
+
+		key, err := packedKey(stub, kind, itemId)
+		if err != nil {
+			return errorResponse(fmt.Errorf("create key error: %s", err))
+		}
+		if err := stub.DelState(key); err != nil {
+			return errorResponse(fmt.Errorf("delete container index error: %s", err))
+		}
+	}
+	if err := putContainer(stub, container); err != nil {
+		return errorResponse(fmt.Errorf("update container error: %s", err))
+	}
+
+	return shim.Success(nil)
+}
+
+// 容器转让, 容器内的全部资产(包括嵌套容器)一起转让并各自记录流通记录
+func (c *IngredientsExchangeCC) containerExchange(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 3, 4); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	ownerId := args[0]
+	containerId := args[1]
+	currentOwnerId := args[2]
+	facilityId := facilityArg(args, 3)
+	if ownerId == "" || containerId == "" || currentOwnerId == "" {
+		return errorResponse(invalidArgs())
+	}
+	if ownerId == currentOwnerId {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	originOwner, err := getUser(stub, ownerId)
+	if err != nil || originOwner == nil {
+		return errorResponse(notFound("user", ownerId))
+	}
+
+	currentOwner, err := getUser(stub, currentOwnerId)
+	if err != nil || currentOwner == nil {
+		return errorResponse(notFound("user", currentOwnerId))
+	}
+
+	if !containerAsset.exists(stub, containerId) {
+		return errorResponse(containerAsset.notFoundError(containerId))
+	}
+
+	// 只能转让最外层的容器
+	if !containerAsset.owns(originOwner, containerId) {
+		return errorResponse(ownerMismatch("container", containerId))
+	}
+	if err := checkNotPacked(stub, containerAsset, containerId); err != nil {
+		return errorResponse(err)
+	}
+	if err := checkFacility(stub, facilityId); err != nil {
+		return errorResponse(err)
+	}
+
+	items, err := collectContainerItems(stub, containerId, make(map[string]bool))
+	if err != nil {
+		return errorResponse(fmt.Errorf("query container error: %s", err))
+	}
+
+	// 需要多方审批的食品不能随容器绕过审批
+	for _, item := range items {
+		if item.kind != foodAsset {
+			continue
+		}
+		_, policy, err := foodApprovalPolicy(stub, item.id)
+		if err != nil {
+			return errorResponse(fmt.Errorf("get approval policy error: %s", err))
+		}
+		if policy != nil {
+			return errorResponse(newError(CodeFailedPrecondition, "food %s requires approval, unpack before transfer", item.id).withEntity(item.id))
+		}
+	}
+
+	//写入状态
+	for _, item := range items {
+		if !item.kind.removeHolding(originOwner, item.id) {
+			return errorResponse(newError(CodeOwnerMismatch, "%s %s owner not match", item.kind.name, item.id).withEntity(item.id))
+		}
+		item.kind.addHolding(currentOwner, item.id)
+
+		if err := item.kind.putHistory(stub, item.id, ownerId, currentOwnerId, facilityId); err != nil {
+			return errorResponse(err)
+		}
+		if err := setOwnerEndorsement(stub, item.kind.key(item.id), currentOwner); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	// Fabric 在同一交易内读不到自己的写入, 用户只写一次
+	if err := putUser(stub, originOwner); err != nil {
+		return errorResponse(fmt.Errorf("update user error: %s", err))
+	}
+	if err := putUser(stub, currentOwner); err != nil {
+		return errorResponse(fmt.Errorf("update user error: %s", err))
+	}
+
+	return shim.Success(nil)
+}
+
+// 递归展开容器内容
+func buildContainerContents(stub shim.ChaincodeStubInterface, container *Container, visited map[string]bool) (*ContainerContents, error) {
+	visited[container.Id] = true
+
+	contents := &ContainerContents{
+		Container:   container,
+		Ingredients: make([]*Ingredient, 0),
+		Foods:       make([]*Food, 0),
+		Containers:  make([]*ContainerContents, 0),
+	}
+
+	for _, ingredientId := range container.Ingredients {
+		ingredientBytes, err := stub.GetState(constructIngredientKey(ingredientId))
+		if err != nil {
+			return nil, err
+		}
+
+		ingredient := &Ingredient{Id: ingredientId}
+		if len(ingredientBytes) != 0 {
+			if err := unmarshalDoc(ingredientBytes, ingredient); err != nil {
+				return nil, err
+			}
+		}
+		contents.Ingredients = append(contents.Ingredients, ingredient)
+	}
+
+	for _, foodId := range container.Foods {
+		food, err := getFood(stub, foodId)
+		if err != nil {
+			return nil, err
+		}
+		if food == nil {
+			food = &Food{Id: foodId}
+		}
+		contents.Foods = append(contents.Foods, food)
+	}
+
+	for _, nestedId := range container.Containers {
+		// 防御账本中已存在的环
+		if visited[nestedId] {
+			continue
+		}
+
+		nested, err := getContainer(stub, nestedId)
+		if err != nil {
+			return nil, err
+		}
+		if nested == nil {
+			continue
+		}
+
+		nestedContents, err := buildContainerContents(stub, nested, visited)
+		if err != nil {
+			return nil, err
+		}
+		contents.Containers = append(contents.Containers, nestedContents)
+	}
+
+	return contents, nil
+}
+
+// 容器内容查询
+func (c *IngredientsExchangeCC) queryContainer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	containerId := args[0]
+	if containerId == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	container, err := getContainer(stub, containerId)
+	if err != nil || container == nil {
+		return errorResponse(notFound("container", containerId))
+	}
+
+	contents, err := buildContainerContents(stub, container, make(map[string]bool))
+	if err != nil {
+		return errorResponse(fmt.Errorf("query container error: %s", err))
+	}
+
+	contentsBytes, err := json.Marshal(contents)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(contentsBytes)
+}
+
+// 容器流通记录查询
+func (c *IngredientsExchangeCC) queryContainerHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数
+	containerId, queryType, err := parseHistoryArgs(args)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	//验证数据是否存在
+	if !containerAsset.exists(stub, containerId) {
+		return errorResponse(notFound("container", containerId))
+	}
+
+	// 查询相关数据
+	values, err := containerAsset.histories(stub, containerId, queryType)
+	if err != nil {
+		return errorResponse(fmt.Errorf("query history error: %s", err))
+	}
+
+	histories := make([]*ContainerHistory, 0)
+	for _, value := range values {
+		history := new(ContainerHistory)
+		if err := unmarshalDoc(value, history); err != nil {
+			return errorResponse(fmt.Errorf("unmarshal error: %s", err))
+		}
+
+		histories = append(histories, history)
+	}
+
+	historiesBytes, err := json.Marshal(histories)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(historiesBytes)
+}
@@ -0,0 +1,146 @@
+package food
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/statebased"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// 资产键的背书信息
+type KeyEndorsement struct {
+	Key  string   `json:"key"`
+	Orgs []string `json:"orgs"`
+}
+
+// 是否启用键级背书, 需要通道开启 V1_3 以上的应用能力
+func keyLevelEndorsement(stub shim.ChaincodeStubInterface) (bool, error) {
+	cfg, err := getConfig(stub)
+	if err != nil {
+		return false, err
+	}
+
+	return cfg.KeyLevelEndorsement, nil
+}
+
+// 只需要该组织的peer背书的策略
+func orgEndorsementPolicy(msp string) ([]byte, error) {
+	ep, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := ep.AddOrgs(statebased.RoleTypePeer, msp); err != nil {
+		return nil, err
+	}
+
+	return ep.Policy()
+}
+
+// 资产键改由拥有者所在组织背书
+// 修改键的背书策略本身要满足原有策略, 因此转让必须得到原拥有者组织的背书
+// 没有记录组织的旧用户或未启用键级背书时保持原有策略, 但仍写入资产键
+func setOwnerEndorsement(stub shim.ChaincodeStubInterface, key string, owner *User) error {
+	enabled, err := keyLevelEndorsement(stub)
+	if err != nil {
+		return err
+	}
+	if !enabled || owner.MSP == "" {
+		return touchAsset(stub, key)
+	}
+
+	policy, err := orgEndorsementPolicy(owner.MSP)
+	if err != nil {
+		return fmt.Errorf("create endorsement policy error: %s", err)
+	}
+	if err := stub.SetStateValidationParameter(key, policy); err != nil {
+		return fmt.Errorf("set endorsement policy error: %s", err)
+	}
+
+	return nil
+}
+
+// 原样写回资产文档
+// 拥有者变化的交易必须写入资产键, 否则不受键上原有背书策略的约束
+// 本交易中新写入的资产读不到, 已由调用方写入, 不再处理
+func touchAsset(stub shim.ChaincodeStubInterface, key string) error {
+	assetBytes, err := stub.GetState(key)
+	if err != nil {
+		return fmt.Errorf("get state error: %s", err)
+	}
+	if len(assetBytes) == 0 {
+		return nil
+	}
+	if err := stub.PutState(key, assetBytes); err != nil {
+		return fmt.Errorf("put state error: %s", err)
+	}
+
+	return nil
+}
+
+// 资产并入食品后, 沿用该食品的背书策略, 食品没有键级策略时仍写入资产键
+func followEndorsement(stub shim.ChaincodeStubInterface, key, holderKey string) error {
+	enabled, err := keyLevelEndorsement(stub)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return touchAsset(stub, key)
+	}
+
+	policy, err := stub.GetStateValidationParameter(holderKey)
+	if err != nil {
+		return fmt.Errorf("get endorsement policy error: %s", err)
+	}
+	if len(policy) == 0 {
+		return touchAsset(stub, key)
+	}
+	if err := stub.SetStateValidationParameter(key, policy); err != nil {
+		return fmt.Errorf("set endorsement policy error: %s", err)
+	}
+
+	return nil
+}
+
+// 查询资产键的背书组织, 未设置键级策略时 orgs 为空, 由链码背书策略决定
+// 参数: kind(ingredient|food|container), assetId
+func (c *IngredientsExchangeCC) queryEndorsement(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	k := lookupAssetKind(args[0])
+	assetId := args[1]
+	if k == nil || assetId == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	if !k.exists(stub, assetId) {
+		return errorResponse(k.notFoundError(assetId))
+	}
+
+	policy, err := stub.GetStateValidationParameter(k.key(assetId))
+	if err != nil {
+		return errorResponse(fmt.Errorf("get endorsement policy error: %s", err))
+	}
+
+	endorsement := &KeyEndorsement{Key: k.key(assetId), Orgs: make([]string, 0)}
+	if len(policy) != 0 {
+		ep, err := statebased.NewStateEP(policy)
+		if err != nil {
+			return errorResponse(fmt.Errorf("parse endorsement policy error: %s", err))
+		}
+		endorsement.Orgs = append(endorsement.Orgs, ep.ListOrgs()...)
+	}
+
+	endorsementBytes, err := json.Marshal(endorsement)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(endorsementBytes)
+}
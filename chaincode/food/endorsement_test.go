@@ -0,0 +1,53 @@
+package food_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+	"github.com/Blockchain-book/Fabric-Food/simulator"
+)
+
+// 转让到没有记录组织的旧用户或没有键级策略的食品时, 资产键仍被写入, 转让受键上原有背书策略的约束
+func TestHolderChangeWritesAssetKey(t *testing.T) {
+	sim, err := simulator.New(`{"admin_msps":["Org1MSP"],"key_level_endorsement":true}`, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.AddIdentity("admin", &gateway.Identity{MSPID: "Org1MSP"}); err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, sim, &food.UserRegisterRequest{Name: "alice", Id: "u1"})
+
+	// 升级前注册的用户没有记录组织
+	sim.Stub.MockTransactionStart("legacy")
+	sim.Stub.PutState("user_legacy", []byte(`{"name":"old","id":"legacy","ingredients":[],"foods":["target"],"containers":[]}`))
+	sim.Stub.PutState("food_target", []byte(`{"name":"soup","id":"target","metadata":"","ingredients":[],"foods":[],"allergens":[]}`))
+	sim.Stub.MockTransactionEnd("legacy")
+
+	for _, req := range []food.Request{
+		&food.IngredientEnrollRequest{Name: "rice", Id: "i1", OwnerId: "u1"},
+		&food.IngredientEnrollRequest{Name: "salt", Id: "i2", OwnerId: "u1"},
+		&food.FoodEnrollRequest{Name: "stock", Id: "f1", OwnerId: "u1"},
+	} {
+		mustExecute(t, sim, req)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		req  food.Request
+	}{
+		{"exchange to a user without msp", "ingredient_i1", &food.IngredientExchangeRequest{OwnerId: "u1", IngredientId: "i1", CurrentOwnerId: "legacy"}},
+		{"ingredient into a food without policy", "ingredient_i2", &food.IngredientExchangeFoodRequest{OwnerId: "u1", IngredientId: "i2", FoodId: "target"}},
+		{"food into a food without policy", "food_f1", &food.FoodExchangeFoodRequest{OwnerId: "u1", FoodId: "f1", TargetFoodId: "target"}},
+	}
+	for _, tt := range tests {
+		before := len(sim.History(tt.key))
+		mustExecute(t, sim, tt.req)
+		if after := len(sim.History(tt.key)); after != before+1 {
+			t.Errorf("%s: expected %s written, modifications %d -> %d", tt.name, tt.key, before, after)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package food
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// 错误码, 客户端按错误码处理错误, 不再匹配错误信息
+const (
+	// 参数缺失、个数不对或格式错误
+	CodeInvalidArgument = "INVALID_ARGUMENT"
+	// 数据不存在
+	CodeNotFound = "NOT_FOUND"
+	// 数据已存在
+	CodeAlreadyExists = "ALREADY_EXISTS"
+	// 资产不属于调用参数中的拥有者
+	CodeOwnerMismatch = "OWNER_MISMATCH"
+	// 调用身份没有权限
+	CodeForbidden = "FORBIDDEN"
+	// 功能被链码配置关闭
+	CodeFunctionDisabled = "FUNCTION_DISABLED"
+	// 与当前状态冲突, 例如配置版本冲突、重复审批、资产已装箱
+	CodeConflict = "CONFLICT"
+	// 不满足业务规则, 例如过敏原冲突、证书失效、转让过期
+	CodeFailedPrecondition = "FAILED_PRECONDITION"
+	// 批量操作中有条目失败, 整批拒绝
+	CodeBatchRejected = "BATCH_REJECTED"
+	// 账本读写、序列化等内部错误
+	CodeInternal = "INTERNAL"
+)
+
+// 链码错误, 以JSON写入响应的 message
+//
+//	{"code":"NOT_FOUND","message":"food not found","entity_id":"food1"}
+type Error struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Field    string `json:"field,omitempty"`
+	EntityId string `json:"entity_id,omitempty"`
+	// 命名参数校验失败时的每个字段
+	Fields []*FieldError `json:"fields,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(code, format string, a ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// 出错的参数名
+func (e *Error) withField(field string) *Error {
+	e.Field = field
+	return e
+}
+
+// 出错的数据id
+func (e *Error) withEntity(id string) *Error {
+	e.EntityId = id
+	return e
+}
+
+func notFound(kind, id string) *Error {
+	return newError(CodeNotFound, "%s not found", kind).withEntity(id)
+}
+
+func alreadyExists(kind, id string) *Error {
+	return newError(CodeAlreadyExists, "%s already exist", kind).withEntity(id)
+}
+
+func ownerMismatch(kind, id string) *Error {
+	return newError(CodeOwnerMismatch, "%s owner not match", kind).withEntity(id)
+}
+
+func invalidArgs() *Error {
+	return newError(CodeInvalidArgument, "invalid args")
+}
+
+// 检查参数的个数, 参数应有 min 到 max 个
+func checkArgCount(args []string, min, max int) error {
+	if len(args) < min {
+		return newError(CodeInvalidArgument, "not enough args: expected at least %d, got %d", min, len(args))
+	}
+	if len(args) > max {
+		return newError(CodeInvalidArgument, "too many args: expected at most %d, got %d", max, len(args))
+	}
+
+	return nil
+}
+
+// 转换为链码错误, 其他错误视为内部错误
+func toError(err error) *Error {
+	switch e := err.(type) {
+	case *Error:
+		return e
+	case *ArgsError:
+		chaincodeErr := &Error{Code: CodeInvalidArgument, Message: e.Error(), Fields: e.Fields}
+		if len(e.Fields) == 1 {
+			chaincodeErr.Field = e.Fields[0].Field
+		}
+		return chaincodeErr
+	default:
+		return &Error{Code: CodeInternal, Message: err.Error()}
+	}
+}
+
+// 错误响应, message 为错误的JSON
+func errorResponse(err error) pb.Response {
+	errBytes, marshalErr := json.Marshal(toError(err))
+	if marshalErr != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Error(string(errBytes))
+}
@@ -0,0 +1,6 @@
+package food
+
+// 供 food_test 包中的测试使用的内部函数
+var UnmarshalDoc = unmarshalDoc
+
+const CurrentSchemaVersion = currentSchemaVersion
@@ -0,0 +1,322 @@
+package food
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	facilityFarm      = "farm"
+	facilityFactory   = "factory"
+	facilityWarehouse = "warehouse"
+	facilityStore     = "store"
+
+	// 经过设施的资产索引: facilityVisit [facilityId, kind, assetId, from, to]
+	facilityVisitType = "facilityVisit"
+)
+
+// 设施: 农场/工厂/仓库/门店
+type Facility struct {
+	Id            string  `json:"id"`
+	Name          string  `json:"name"`
+	Type          string  `json:"type"`
+	OwnerId       string  `json:"owner_id"`
+	Address       string  `json:"address"`
+	Latitude      float64 `json:"latitude"`
+	Longitude     float64 `json:"longitude"`
+	License       string  `json:"license"`
+	RegisteredAt  string  `json:"registered_at"`
+	SchemaVersion int     `json:"schema_version"`
+}
+
+// 资产经过设施的记录
+type FacilityVisit struct {
+	FacilityId     string `json:"facility_id"`
+	Kind           string `json:"kind"`
+	AssetId        string `json:"asset_id"`
+	OriginOwnerId  string `json:"origin_owner_id"`
+	CurrentOwnerId string `json:"current_owner_id"`
+	Timestamp      string `json:"timestamp"`
+}
+
+// 地图路线上的一个点
+type RouteStop struct {
+	FacilityId string  `json:"facility_id"`
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	OwnerId    string  `json:"owner_id"`
+}
+
+func constructFacilityKey(facilityId string) string {
+	return fmt.Sprintf("facility_%s", facilityId)
+}
+
+func validFacilityType(facilityType string) bool {
+	switch facilityType {
+	case facilityFarm, facilityFactory, facilityWarehouse, facilityStore:
+		return true
+	default:
+		return false
+	}
+}
+
+func getFacility(stub shim.ChaincodeStubInterface, facilityId string) (*Facility, error) {
+	facilityBytes, err := stub.GetState(constructFacilityKey(facilityId))
+	if err != nil || len(facilityBytes) == 0 {
+		return nil, err
+	}
+
+	facility := new(Facility)
+	if err := unmarshalDoc(facilityBytes, facility); err != nil {
+		return nil, err
+	}
+
+	return facility, nil
+}
+
+// 校验可选的设施参数, 为空表示未指定
+func checkFacility(stub shim.ChaincodeStubInterface, facilityId string) error {
+	if facilityId == "" {
+		return nil
+	}
+
+	facility, err := getFacility(stub, facilityId)
+	if err != nil || facility == nil {
+		return notFound("facility", facilityId)
+	}
+
+	return nil
+}
+
+// 取可选的设施参数
+func facilityArg(args []string, index int) string {
+	if len(args) > index {
+		return args[index]
+	}
+
+	return ""
+}
+
+// 生成经过设施的索引记录
+func newFacilityVisit(stub shim.ChaincodeStubInterface, k *assetKind, id, from, to, facilityId string) (string, *FacilityVisit, error) {
+	key, err := stub.CreateCompositeKey(facilityVisitType, []string{facilityId, k.name, id, from, to})
+	if err != nil {
+		return "", nil, fmt.Errorf("create key error: %s", err)
+	}
+
+	timestamp, err := txTimestamp(stub)
+	if err != nil {
+		return "", nil, fmt.Errorf("get tx timestamp error: %s", err)
+	}
+
+	return key, &FacilityVisit{
+		FacilityId:     facilityId,
+		Kind:           k.name,
+		AssetId:        id,
+		OriginOwnerId:  from,
+		CurrentOwnerId: to,
+		Timestamp:      timestamp,
+	}, nil
+}
+
+// 写入经过设施的索引
+func putFacilityVisit(stub shim.ChaincodeStubInterface, k *assetKind, id, from, to, facilityId string) error {
+	visitKey, visit, err := newFacilityVisit(stub, k, id, from, to, facilityId)
+	if err != nil {
+		return err
+	}
+	visitBytes, err := json.Marshal(visit)
+	if err != nil {
+		return fmt.Errorf("marshal facility visit error: %s", err)
+	}
+	if err := stub.PutState(visitKey, visitBytes); err != nil {
+		return fmt.Errorf("save facility visit error: %s", err)
+	}
+
+	return nil
+}
+
+// 由流通记录中的设施生成地图路线
+func buildRoute(stub shim.ChaincodeStubInterface, values [][]byte) ([]*RouteStop, error) {
+	route := make([]*RouteStop, 0)
+	for _, value := range values {
+		edge := new(historyEdge)
+		if err := json.Unmarshal(value, edge); err != nil {
+			return nil, err
+		}
+		if edge.FacilityId == "" {
+			continue
+		}
+
+		facility, err := getFacility(stub, edge.FacilityId)
+		if err != nil {
+			return nil, err
+		}
+		if facility == nil {
+			continue
+		}
+		route = append(route, &RouteStop{
+			FacilityId: facility.Id,
+			Name:       facility.Name,
+			Type:       facility.Type,
+			Latitude:   facility.Latitude,
+			Longitude:  facility.Longitude,
+			OwnerId:    edge.CurrentOwnerId,
+		})
+	}
+
+	return route, nil
+}
+
+// 设施登记
+// 参数: facilityId, name, type(farm|factory|warehouse|store), ownerId, address, latitude, longitude, license
+func (c *IngredientsExchangeCC) facilityRegister(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 8, 8); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	facilityId := args[0]
+	name := args[1]
+	facilityType := args[2]
+	ownerId := args[3]
+	address := args[4]
+	license := args[7]
+	if facilityId == "" || name == "" || !validFacilityType(facilityType) || ownerId == "" || license == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	latitude, err := strconv.ParseFloat(args[5], 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return errorResponse(newError(CodeInvalidArgument, "invalid latitude").withField("latitude"))
+	}
+	longitude, err := strconv.ParseFloat(args[6], 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return errorResponse(newError(CodeInvalidArgument, "invalid longitude").withField("longitude"))
+	}
+
+	//验证数据是否存在
+	owner, err := getUser(stub, ownerId)
+	if err != nil || owner == nil {
+		return errorResponse(notFound("user", ownerId))
+	}
+
+	if facility, err := getFacility(stub, facilityId); err == nil && facility != nil {
+		return errorResponse(alreadyExists("facility", facilityId))
+	}
+
+	registeredAt, err := txTimestamp(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get tx timestamp error: %s", err))
+	}
+
+	//写入状态
+	facility := &Facility{
+		Id:           facilityId,
+		Name:         name,
+		Type:         facilityType,
+		OwnerId:      ownerId,
+		Address:      address,
+		Latitude:     latitude,
+		Longitude:    longitude,
+		License:      license,
+		RegisteredAt: registeredAt,
+	}
+	facilityBytes, err := marshalDoc(facility)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal facility error: %s", err))
+	}
+	if err := stub.PutState(constructFacilityKey(facilityId), facilityBytes); err != nil {
+		return errorResponse(fmt.Errorf("save facility error: %s", err))
+	}
+
+	return shim.Success(nil)
+}
+
+// 设施查询
+func (c *IngredientsExchangeCC) queryFacility(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	facilityId := args[0]
+	if facilityId == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	facilityBytes, err := stub.GetState(constructFacilityKey(facilityId))
+	if err != nil || len(facilityBytes) == 0 {
+		return errorResponse(notFound("facility", facilityId))
+	}
+
+	// 旧版本文档按当前结构返回
+	facilityBytes, err = upgradeDoc(docFacility, facilityBytes)
+	if err != nil {
+		return errorResponse(fmt.Errorf("upgrade error: %s", err))
+	}
+
+	return shim.Success(facilityBytes)
+}
+
+// 查询经过设施的全部资产, 可按资产类型过滤
+func (c *IngredientsExchangeCC) queryFacilityItems(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 1, 2); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	facilityId := args[0]
+	if facilityId == "" {
+		return errorResponse(invalidArgs())
+	}
+	keys := []string{facilityId}
+	if len(args) == 2 && args[1] != "" {
+		if lookupAssetKind(args[1]) == nil {
+			return errorResponse(invalidArgs())
+		}
+		keys = append(keys, args[1])
+	}
+
+	//验证数据是否存在
+	if err := checkFacility(stub, facilityId); err != nil {
+		return errorResponse(err)
+	}
+
+	result, err := stub.GetStateByPartialCompositeKey(facilityVisitType, keys)
+	if err != nil {
+		return errorResponse(fmt.Errorf("query facility error: %s", err))
+	}
+	defer result.Close()
+
+	visits := make([]*FacilityVisit, 0)
+	for result.HasNext() {
+		visitVal, err := result.Next()
+		if err != nil {
+			return errorResponse(fmt.Errorf("query error: %s", err))
+		}
+
+		visit := new(FacilityVisit)
+		if err := json.Unmarshal(visitVal.GetValue(), visit); err != nil {
+			return errorResponse(fmt.Errorf("unmarshal error: %s", err))
+		}
+		visits = append(visits, visit)
+	}
+
+	visitsBytes, err := json.Marshal(visits)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(visitsBytes)
+}
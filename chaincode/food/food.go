@@ -1,8 +1,10 @@
-package main
+package food
 
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
@@ -16,25 +18,38 @@ const (
 
 // 用户
 type User struct {
-	Name        string   `json:"name"`
-	Id          string   `json:"id"`
-	Ingredients []string `json:"ingredients"`
-	Foods       []string `json:"foods"`
+	Name          string   `json:"name"`
+	Id            string   `json:"id"`
+	Ingredients   []string `json:"ingredients"`
+	Foods         []string `json:"foods"`
+	Containers    []string `json:"containers"`
+	MSP           string   `json:"msp,omitempty"`
+	SchemaVersion int      `json:"schema_version"`
 }
 
 // 食品
 type Food struct {
-	Name        string   `json:"name"`
-	Id          string   `json:"id"`
-	Metadata    string   `json:"metadata"`
-	Ingredients []string `json:"ingredients"`
+	Name           string                `json:"name"`
+	Id             string                `json:"id"`
+	Metadata       string                `json:"metadata"`
+	Ingredients    []string              `json:"ingredients"`
+	Foods          []string              `json:"foods"`
+	Allergens      []string              `json:"allergens"`
+	AllergenFree   []string              `json:"allergen_free"`
+	Certifications []*CertificationClaim `json:"certifications"`
+	Serial         string                `json:"serial,omitempty"`
+	EnrolledAt     string                `json:"enrolled_at,omitempty"`
+	SchemaVersion  int                   `json:"schema_version"`
 }
 
 // 食材
 type Ingredient struct {
-	Name     string `json:"name"`
-	Id       string `json:"id"`
-	Metadata string `json:"metadata"`
+	Name           string                `json:"name"`
+	Id             string                `json:"id"`
+	Metadata       string                `json:"metadata"`
+	Allergens      []string              `json:"allergens"`
+	Certifications []*CertificationClaim `json:"certifications"`
+	SchemaVersion  int                   `json:"schema_version"`
 }
 
 // 食材流通
@@ -42,48 +57,97 @@ type IngredientHistory struct {
 	IngredientId   string `json:"ingredient_id"`
 	OriginOwnerId  string `json:"origin_owner_id"`
 	CurrentOwnerId string `json:"current_owner_id"`
+	FacilityId     string `json:"facility_id,omitempty"`
+	Timestamp      string `json:"timestamp,omitempty"`
+	SchemaVersion  int    `json:"schema_version"`
 }
 
 // 食品流通
 type FoodHistory struct {
-	FoodId         string `json:"food_id"`
-	OriginOwnerId  string `json:"origin_owner_id"`
-	CurrentOwnerId string `json:"current_owner_id"`
+	FoodId         string       `json:"food_id"`
+	OriginOwnerId  string       `json:"origin_owner_id"`
+	CurrentOwnerId string       `json:"current_owner_id"`
+	FacilityId     string       `json:"facility_id,omitempty"`
+	Timestamp      string       `json:"timestamp,omitempty"`
+	Type           string       `json:"type,omitempty"`
+	Step           *ProcessStep `json:"step,omitempty"`
+	SchemaVersion  int          `json:"schema_version"`
 }
 
 func constructUserKey(userId string) string {
 	return fmt.Sprintf("user_%s", userId)
 }
 
-func constructFOODKey(foodId string) string {
-	return fmt.Sprintf("food_%s", foodId)
-}
-
 func constructIngredientKey(ingredientId string) string {
-	return fmt.Sprintf("ingredient_%s", ingredientId)
+	return ingredientAsset.key(ingredientId)
 }
 
 func constructFoodKey(foodId string) string {
-	return fmt.Sprintf("food_%s", foodId)
+	return foodAsset.key(foodId)
+}
+
+// 用户或资产的状态键, kind 为 user 或资产类型名, 未知类型返回空
+// 供链下工具把查询到的文档写入模拟账本
+func StateKey(kind, id string) string {
+	if kind == "user" {
+		return constructUserKey(id)
+	}
+	if k := lookupAssetKind(kind); k != nil {
+		return k.key(id)
+	}
+
+	return ""
+}
+
+func getUser(stub shim.ChaincodeStubInterface, userId string) (*User, error) {
+	userBytes, err := stub.GetState(constructUserKey(userId))
+	if err != nil || len(userBytes) == 0 {
+		return nil, err
+	}
+
+	user := new(User)
+	if err := unmarshalDoc(userBytes, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func putUser(stub shim.ChaincodeStubInterface, user *User) error {
+	userBytes, err := marshalDoc(user)
+	if err != nil {
+		return fmt.Errorf("marshal user error: %s", err)
+	}
+
+	return stub.PutState(constructUserKey(user.Id), userBytes)
+}
+
+func putFood(stub shim.ChaincodeStubInterface, food *Food) error {
+	foodBytes, err := marshalDoc(food)
+	if err != nil {
+		return fmt.Errorf("marshal food error: %s", err)
+	}
+
+	return stub.PutState(constructFoodKey(food.Id), foodBytes)
 }
 
 // 用户注册
 func (c *IngredientsExchangeCC) userRegister(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	//检查参数的个数
-	if len(args) != 2 {
-		return shim.Error("not enough args")
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return errorResponse(err)
 	}
 
 	//验证参数的正确性
 	name := args[0]
 	id := args[1]
 	if name == "" || id == "" {
-		return shim.Error("invalid args")
+		return errorResponse(invalidArgs())
 	}
 
 	//验证数据是否存在
 	if userBytes, err := stub.GetState(constructUserKey(id)); err == nil && len(userBytes) != 0 {
-		return shim.Error("user already exist")
+		return errorResponse(alreadyExists("user", id))
 	}
 
 	//写入状态
@@ -92,16 +156,23 @@ func (c *IngredientsExchangeCC) userRegister(stub shim.ChaincodeStubInterface, a
 		Id:          id,
 		Ingredients: make([]string, 0),
 		Foods:       make([]string, 0),
+		Containers:  make([]string, 0),
 	}
+	// 注册者所在组织, 用于资产键的背书策略
+	msp, err := clientMSPID(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get client identity error: %s", err))
+	}
+	user.MSP = msp
 
 	// 序列化对象
-	userBytes, err := json.Marshal(user)
+	userBytes, err := marshalDoc(user)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal user error %s", err))
+		return errorResponse(fmt.Errorf("marshal user error %s", err))
 	}
 
 	if err := stub.PutState(constructUserKey(id), userBytes); err != nil {
-		return shim.Error(fmt.Sprintf("put user error %s", err))
+		return errorResponse(fmt.Errorf("put user error %s", err))
 	}
 
 	// 成功返回
@@ -111,35 +182,92 @@ func (c *IngredientsExchangeCC) userRegister(stub shim.ChaincodeStubInterface, a
 // 删除用户
 func (c *IngredientsExchangeCC) userDestroy(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	//检查参数的个数
-	if len(args) != 1 {
-		return shim.Error("not enough args")
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return errorResponse(err)
 	}
 
 	//验证参数的正确性
 	id := args[0]
 	if id == "" {
-		return shim.Error("invalid args")
+		return errorResponse(invalidArgs())
 	}
 
 	//验证数据是否存在
 	userBytes, err := stub.GetState(constructUserKey(id))
 	if err != nil || len(userBytes) == 0 {
-		return shim.Error("user not found")
+		return errorResponse(notFound("user", id))
+	}
+
+	user := new(User)
+	if err := unmarshalDoc(userBytes, user); err != nil {
+		return errorResponse(fmt.Errorf("unmarshal user error: %s", err))
+	}
+
+	// 按配置的删除策略处理名下资产
+	cfg, err := getConfig(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get config error: %s", err))
+	}
+	if cfg.DeletionPolicy == deletionPolicyReject && len(user.Ingredients)+len(user.Foods)+len(user.Containers) != 0 {
+		return errorResponse(newError(CodeConflict, "user still owns assets").withEntity(id))
 	}
 
 	//写入状态
 	if err := stub.DelState(constructUserKey(id)); err != nil {
-		return shim.Error(fmt.Sprintf("delete user error: %s", err))
+		return errorResponse(fmt.Errorf("delete user error: %s", err))
 	}
 
-	// 删除用户名下的食材
-	user := new(User)
-	if err := json.Unmarshal(userBytes, user); err != nil {
-		return shim.Error(fmt.Sprintf("unmarshal user error: %s", err))
+	if cfg.DeletionPolicy == deletionPolicyRetain {
+		return shim.Success(nil)
 	}
+
+	// 删除用户名下的食材和容器, 先拆箱以免装箱索引和容器内容指向已删除的资产
+	deleted := make(map[string]bool)
 	for _, ingredientid := range user.Ingredients {
+		deleted[constructIngredientKey(ingredientid)] = true
+	}
+	for _, containerId := range user.Containers {
+		deleted[constructContainerKey(containerId)] = true
+	}
+
+	changed := make(map[string]*Container)
+	for _, ingredientid := range user.Ingredients {
+		if err := unpackForDelete(stub, ingredientAsset, ingredientid, deleted, changed); err != nil {
+			return errorResponse(err)
+		}
 		if err := stub.DelState(constructIngredientKey(ingredientid)); err != nil {
-			return shim.Error(fmt.Sprintf("delete ingredient error: %s", err))
+			return errorResponse(fmt.Errorf("delete ingredient error: %s", err))
+		}
+	}
+
+	for _, containerId := range user.Containers {
+		container, err := getContainer(stub, containerId)
+		if err != nil {
+			return errorResponse(fmt.Errorf("query container error: %s", err))
+		}
+		// 容器中保留的资产(如食品)随之拆箱
+		if container != nil {
+			if err := clearPackedIndex(stub, container); err != nil {
+				return errorResponse(err)
+			}
+		}
+		if err := unpackForDelete(stub, containerAsset, containerId, deleted, changed); err != nil {
+			return errorResponse(err)
+		}
+		if err := stub.DelState(constructContainerKey(containerId)); err != nil {
+			return errorResponse(fmt.Errorf("delete container error: %s", err))
+		}
+	}
+
+	// Fabric 在同一交易内读不到自己的写入, 每个容器只写一次
+	changedIds := make([]string, 0, len(changed))
+	for containerId := range changed {
+		changedIds = append(changedIds, containerId)
+	}
+	sort.Strings(changedIds)
+	for _, containerId := range changedIds {
+		if err := putContainer(stub, changed[containerId]); err != nil {
+			return errorResponse(fmt.Errorf("update container error: %s", err))
 		}
 	}
 
@@ -149,8 +277,8 @@ func (c *IngredientsExchangeCC) userDestroy(stub shim.ChaincodeStubInterface, ar
 // 食材登记
 func (c *IngredientsExchangeCC) ingredientEnroll(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	//检查参数的个数
-	if len(args) != 4 {
-		return shim.Error("not enough args")
+	if err := checkArgCount(args, 4, 7); err != nil {
+		return errorResponse(err)
 	}
 
 	//验证参数的正确性
@@ -159,80 +287,60 @@ func (c *IngredientsExchangeCC) ingredientEnroll(stub shim.ChaincodeStubInterfac
 	metadata := args[2]
 	ownerId := args[3]
 	if ingredientName == "" || ingredientId == "" || ownerId == "" {
-		return shim.Error("invalid args")
+		return errorResponse(invalidArgs())
 	}
 
-	//验证数据是否存在
-	userBytes, err := stub.GetState(constructUserKey(ownerId))
-	if err != nil || len(userBytes) == 0 {
-		return shim.Error("user not found")
+	// 可选的过敏原声明
+	allergens := make([]string, 0)
+	if len(args) >= 5 {
+		allergens = parseAllergens(args[4])
 	}
 
-	if ingredientBytes, err := stub.GetState(constructIngredientKey(ingredientId)); err == nil && len(ingredientBytes) != 0 {
-		return shim.Error("ingredient already exist")
-	}
+	// 可选的登记设施
+	facilityId := facilityArg(args, 5)
 
-	//写入状态
-	ingredient := &Ingredient{
-		Name:     ingredientName,
-		Id:       ingredientId,
-		Metadata: metadata,
-	}
-	ingredientBytes, err := json.Marshal(ingredient)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal ingredient error: %s", err))
-	}
-	if err := stub.PutState(constructIngredientKey(ingredientId), ingredientBytes); err != nil {
-		return shim.Error(fmt.Sprintf("save ingredient error: %s", err))
+	// 可选的认证声明, 逗号分隔的证书id
+	certificateIds := make([]string, 0)
+	if len(args) == 7 {
+		certificateIds = parseIdList(args[6])
 	}
 
-	user := new(User)
-	// 反序列化用户
-	if err := json.Unmarshal(userBytes, user); err != nil {
-		return shim.Error(fmt.Sprintf("unmarshal user error: %s", err))
-	}
-	user.Ingredients = append(user.Ingredients, ingredientId)
-	// 序列化用户
-	userBytes, err = json.Marshal(user)
+	//验证数据是否存在
+	user, err := checkEnrollAsset(stub, ingredientAsset, ingredientId, ownerId)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal user error: %s", err))
-	}
-	if err := stub.PutState(constructUserKey(user.Id), userBytes); err != nil {
-		return shim.Error(fmt.Sprintf("update user error: %s", err))
+		return errorResponse(err)
 	}
-
-	// 食材变更历史
-	history := &IngredientHistory{
-		IngredientId:   ingredientId,
-		OriginOwnerId:  originOwner,
-		CurrentOwnerId: ownerId,
+	if err := checkFacility(stub, facilityId); err != nil {
+		return errorResponse(err)
 	}
-	historyBytes, err := json.Marshal(history)
+	certifications, err := checkCertificationClaims(stub, ingredientAsset, certificateIds, ownerId, facilityId)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal ingredient history error: %s", err))
+		return errorResponse(err)
 	}
 
-	historyKey, err := stub.CreateCompositeKey("history", []string{
-		ingredientId,
-		originOwner,
-		ownerId,
-	})
-	if err != nil {
-		return shim.Error(fmt.Sprintf("create key error: %s", err))
+	//写入状态
+	ingredient := &Ingredient{
+		Name:           ingredientName,
+		Id:             ingredientId,
+		Metadata:       metadata,
+		Allergens:      allergens,
+		Certifications: certifications,
 	}
-
-	if err := stub.PutState(historyKey, historyBytes); err != nil {
-		return shim.Error(fmt.Sprintf("save ingredient history error: %s", err))
+	if err := enrollAsset(stub, ingredientAsset, ingredientId, ingredient, user, facilityId); err != nil {
+		return errorResponse(err)
+	}
+	if err := putCertificationClaims(stub, ingredientAsset, ingredientId, certifications); err != nil {
+		return errorResponse(err)
 	}
 
 	return shim.Success(nil)
 }
 
-//食材登记
+// 食材登记
 func (c *IngredientsExchangeCC) foodEnroll(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	//检查参数的个数
-	if len(args) != 4 {
-		return shim.Error("not enough args")
+	if err := checkArgCount(args, 4, 8); err != nil {
+		return errorResponse(err)
 	}
 
 	//验证参数的正确性
@@ -241,70 +349,79 @@ func (c *IngredientsExchangeCC) foodEnroll(stub shim.ChaincodeStubInterface, arg
 	metadata := args[2]
 	ownerId := args[3]
 	if foodName == "" || foodId == "" || ownerId == "" {
-		return shim.Error("invalid args")
+		return errorResponse(invalidArgs())
 	}
 
-	//验证数据是否存在
-	userBytes, err := stub.GetState(constructUserKey(ownerId))
-	if err != nil || len(userBytes) == 0 {
-		return shim.Error("user not found")
+	// 可选的无过敏原声明
+	allergenFree := make([]string, 0)
+	if len(args) >= 5 {
+		allergenFree = parseAllergens(args[4])
 	}
 
-	if foodBytes, err := stub.GetState(constructFOODKey(foodId)); err == nil && len(foodBytes) != 0 {
-		return shim.Error("food already exist")
+	// 可选的公开产品序列号
+	serial := ""
+	if len(args) >= 6 {
+		serial = args[5]
 	}
 
-	//写入状态
-	food := &Food{
-		Name:     foodName,
-		Id:       foodId,
-		Metadata: metadata,
+	// 可选的登记设施
+	facilityId := facilityArg(args, 6)
+
+	// 可选的认证声明, 逗号分隔的证书id
+	certificateIds := make([]string, 0)
+	if len(args) == 8 {
+		certificateIds = parseIdList(args[7])
 	}
-	foodBytes, err := json.Marshal(food)
+
+	//验证数据是否存在
+	user, err := checkEnrollAsset(stub, foodAsset, foodId, ownerId)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal food error: %s", err))
+		return errorResponse(err)
 	}
-	if err := stub.PutState(constructFoodKey(foodId), foodBytes); err != nil {
-		return shim.Error(fmt.Sprintf("save food error: %s", err))
-	}
-
-	user := new(User)
-	// 反序列化用户
-	if err := json.Unmarshal(userBytes, user); err != nil {
-		return shim.Error(fmt.Sprintf("unmarshal user error: %s", err))
+	if err := checkFacility(stub, facilityId); err != nil {
+		return errorResponse(err)
 	}
-	user.Foods = append(user.Foods, foodId)
-	// 序列化用户
-	userBytes, err = json.Marshal(user)
+	certifications, err := checkCertificationClaims(stub, foodAsset, certificateIds, ownerId, facilityId)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal user error: %s", err))
-	}
-	if err := stub.PutState(constructUserKey(user.Id), userBytes); err != nil {
-		return shim.Error(fmt.Sprintf("update user error: %s", err))
+		return errorResponse(err)
 	}
 
-	//食品变更历史
-	history := &FoodHistory{
-		FoodId:         foodId,
-		OriginOwnerId:  originOwner,
-		CurrentOwnerId: ownerId,
-	}
-	historyBytes, err := json.Marshal(history)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal food history error: %s", err))
+	if serial != "" {
+		if serialBytes, err := stub.GetState(constructSerialKey(serial)); err == nil && len(serialBytes) != 0 {
+			return errorResponse(alreadyExists("serial", serial))
+		}
 	}
 
-	historyKey, err := stub.CreateCompositeKey("history", []string{
-		foodId,
-		originOwner,
-		ownerId,
-	})
+	enrolledAt, err := txTimestamp(stub)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("create key error: %s", err))
+		return errorResponse(fmt.Errorf("get tx timestamp error: %s", err))
 	}
 
-	if err := stub.PutState(historyKey, historyBytes); err != nil {
-		return shim.Error(fmt.Sprintf("save food history error: %s", err))
+	//写入状态
+	food := &Food{
+		Name:           foodName,
+		Id:             foodId,
+		Metadata:       metadata,
+		Ingredients:    make([]string, 0),
+		Foods:          make([]string, 0),
+		Allergens:      make([]string, 0),
+		AllergenFree:   allergenFree,
+		Certifications: certifications,
+		Serial:         serial,
+		EnrolledAt:     enrolledAt,
+	}
+	if err := enrollAsset(stub, foodAsset, foodId, food, user, facilityId); err != nil {
+		return errorResponse(err)
+	}
+	if err := putCertificationClaims(stub, foodAsset, foodId, certifications); err != nil {
+		return errorResponse(err)
+	}
+
+	// 登记序列号到食品的映射
+	if serial != "" {
+		if err := stub.PutState(constructSerialKey(serial), []byte(foodId)); err != nil {
+			return errorResponse(fmt.Errorf("save serial error: %s", err))
+		}
 	}
 
 	return shim.Success(nil)
@@ -313,108 +430,21 @@ func (c *IngredientsExchangeCC) foodEnroll(stub shim.ChaincodeStubInterface, arg
 // 食材变更
 func (c *IngredientsExchangeCC) ingredientExchange(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	//检查参数的个数
-	if len(args) != 3 {
-		return shim.Error("not enough args")
+	if err := checkArgCount(args, 3, 4); err != nil {
+		return errorResponse(err)
 	}
 
 	//验证参数的正确性
 	ownerId := args[0]
-	ingredientId := args[1]
+	assetId := args[1]
 	currentOwnerId := args[2]
-	if ownerId == "" || ingredientId == "" || currentOwnerId == "" {
-		return shim.Error("invalid args")
-	}
-
-	//验证数据是否存在
-	originOwnerBytes, err := stub.GetState(constructUserKey(ownerId))
-	if err != nil || len(originOwnerBytes) == 0 {
-		return shim.Error("user not found")
-	}
-
-	currentOwnerBytes, err := stub.GetState(constructUserKey(currentOwnerId))
-	if err != nil || len(currentOwnerBytes) == 0 {
-		return shim.Error("user not found")
-	}
-
-	assetBytes, err := stub.GetState(constructIngredientKey(ingredientId))
-	if err != nil || len(assetBytes) == 0 {
-		return shim.Error("asset not found")
-	}
-
-	// 校验原始拥有者确实拥有当前变更的食材
-	originOwner := new(User)
-	// 反序列化用户
-	if err := json.Unmarshal(originOwnerBytes, originOwner); err != nil {
-		return shim.Error(fmt.Sprintf("unmarshal user error: %s", err))
-	}
-	aidexist := false
-	for _, aid := range originOwner.Ingredients {
-		if aid == ingredientId {
-			aidexist = true
-			break
-		}
-	}
-	if !aidexist {
-		return shim.Error("ingredient owner not match")
-	}
-
-	//写入状态
-	ingredientIds := make([]string, 0)
-	for _, aid := range originOwner.Ingredients {
-		if aid == ingredientId {
-			continue
-		}
-
-		ingredientIds = append(ingredientIds, aid)
-	}
-	originOwner.Ingredients = ingredientIds
-
-	originOwnerBytes, err = json.Marshal(originOwner)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal user error: %s", err))
-	}
-	if err := stub.PutState(constructUserKey(ownerId), originOwnerBytes); err != nil {
-		return shim.Error(fmt.Sprintf("update user error: %s", err))
-	}
-
-	// 当前拥有者插入食材id
-	currentOwner := new(User)
-	// 反序列化用户
-	if err := json.Unmarshal(currentOwnerBytes, currentOwner); err != nil {
-		return shim.Error(fmt.Sprintf("unmarshal user error: %s", err))
-	}
-	currentOwner.Ingredients = append(currentOwner.Ingredients, ingredientId)
-
-	currentOwnerBytes, err = json.Marshal(currentOwner)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal user error: %s", err))
-	}
-	if err := stub.PutState(constructUserKey(currentOwnerId), currentOwnerBytes); err != nil {
-		return shim.Error(fmt.Sprintf("update user error: %s", err))
-	}
-
-	// 插入食材变更记录
-	history := &IngredientHistory{
-		IngredientId:   ingredientId,
-		OriginOwnerId:  ownerId,
-		CurrentOwnerId: currentOwnerId,
-	}
-	historyBytes, err := json.Marshal(history)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal ingredient history error: %s", err))
+	facilityId := facilityArg(args, 3)
+	if ownerId == "" || assetId == "" || currentOwnerId == "" {
+		return errorResponse(invalidArgs())
 	}
 
-	historyKey, err := stub.CreateCompositeKey("history", []string{
-		ingredientId,
-		ownerId,
-		currentOwnerId,
-	})
-	if err != nil {
-		return shim.Error(fmt.Sprintf("create key error: %s", err))
-	}
-
-	if err := stub.PutState(historyKey, historyBytes); err != nil {
-		return shim.Error(fmt.Sprintf("save ingredient history error: %s", err))
+	if err := exchangeAsset(stub, ingredientAsset, ownerId, assetId, currentOwnerId, facilityId); err != nil {
+		return errorResponse(err)
 	}
 
 	return shim.Success(nil)
@@ -423,108 +453,85 @@ func (c *IngredientsExchangeCC) ingredientExchange(stub shim.ChaincodeStubInterf
 // 食材变更
 func (c *IngredientsExchangeCC) ingredientExchangeFood(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	//检查参数的个数
-	if len(args) != 3 {
-		return shim.Error("not enough args")
+	if err := checkArgCount(args, 3, 4); err != nil {
+		return errorResponse(err)
 	}
 
 	//验证参数的正确性
 	ownerId := args[0]
 	ingredientId := args[1]
 	currentOwnerId := args[2]
+	facilityId := facilityArg(args, 3)
 	if ownerId == "" || ingredientId == "" || currentOwnerId == "" {
-		return shim.Error("invalid args")
+		return errorResponse(invalidArgs())
 	}
 
 	//验证数据是否存在
 	originOwnerBytes, err := stub.GetState(constructUserKey(ownerId))
 	if err != nil || len(originOwnerBytes) == 0 {
-		return shim.Error("user not found")
+		return errorResponse(notFound("user", ownerId))
 	}
 
-	currentOwnerBytes, err := stub.GetState(constructFOODKey(currentOwnerId))
+	currentOwnerBytes, err := stub.GetState(constructFoodKey(currentOwnerId))
 	if err != nil || len(currentOwnerBytes) == 0 {
-		return shim.Error("user not found")
+		return errorResponse(notFound("food", currentOwnerId))
 	}
 
 	assetBytes, err := stub.GetState(constructIngredientKey(ingredientId))
 	if err != nil || len(assetBytes) == 0 {
-		return shim.Error("ingredient not found")
+		return errorResponse(notFound("ingredient", ingredientId))
 	}
 
 	// 校验原始拥有者确实拥有当前变更的食材
 	originOwner := new(User)
 	// 反序列化用户
-	if err := json.Unmarshal(originOwnerBytes, originOwner); err != nil {
-		return shim.Error(fmt.Sprintf("unmarshal user error: %s", err))
-	}
-	aidexist := false
-	for _, aid := range originOwner.Ingredients {
-		if aid == ingredientId {
-			aidexist = true
-			break
-		}
+	if err := unmarshalDoc(originOwnerBytes, originOwner); err != nil {
+		return errorResponse(fmt.Errorf("unmarshal user error: %s", err))
 	}
-	if !aidexist {
-		return shim.Error("ingredient owner not match")
+	if !ingredientAsset.owns(originOwner, ingredientId) {
+		return errorResponse(ownerMismatch("ingredient", ingredientId))
 	}
-
-	//写入状态
-	ingredientIds := make([]string, 0)
-	for _, aid := range originOwner.Ingredients {
-		if aid == ingredientId {
-			continue
-		}
-
-		ingredientIds = append(ingredientIds, aid)
+	if err := checkNotPacked(stub, ingredientAsset, ingredientId); err != nil {
+		return errorResponse(err)
+	}
+	if err := checkFacility(stub, facilityId); err != nil {
+		return errorResponse(err)
 	}
-	originOwner.Ingredients = ingredientIds
 
-	originOwnerBytes, err = json.Marshal(originOwner)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal user error: %s", err))
+	// 校验过敏原与食品的无过敏原声明不冲突
+	ingredient := new(Ingredient)
+	if err := unmarshalDoc(assetBytes, ingredient); err != nil {
+		return errorResponse(fmt.Errorf("unmarshal ingredient error: %s", err))
 	}
-	if err := stub.PutState(constructUserKey(ownerId), originOwnerBytes); err != nil {
-		return shim.Error(fmt.Sprintf("update user error: %s", err))
+	currentOwner := new(Food)
+	if err := unmarshalDoc(currentOwnerBytes, currentOwner); err != nil {
+		return errorResponse(fmt.Errorf("unmarshal food error: %s", err))
+	}
+	if conflicts := allergenConflicts(currentOwner, ingredient.Allergens); len(conflicts) != 0 {
+		return errorResponse(newError(CodeFailedPrecondition, "allergen conflict: food declared free of %s", strings.Join(conflicts, ",")).withEntity(currentOwnerId))
 	}
 
-	// 当前拥有者插入食材id
-	currentOwner := new(Food)
-	// 反序列化用户
-	if err := json.Unmarshal(currentOwnerBytes, currentOwner); err != nil {
-		return shim.Error(fmt.Sprintf("unmarshal user error: %s", err))
+	//写入状态
+	ingredientAsset.removeHolding(originOwner, ingredientId)
+	if err := putUser(stub, originOwner); err != nil {
+		return errorResponse(fmt.Errorf("update user error: %s", err))
 	}
+
+	// 当前拥有者插入食材id
 	currentOwner.Ingredients = append(currentOwner.Ingredients, ingredientId)
+	// 合并食材的过敏原到食品
+	currentOwner.Allergens = mergeAllergens(currentOwner.Allergens, ingredient.Allergens)
 
-	currentOwnerBytes, err = json.Marshal(currentOwner)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal user error: %s", err))
-	}
-	if err := stub.PutState(constructFOODKey(currentOwnerId), currentOwnerBytes); err != nil {
-		return shim.Error(fmt.Sprintf("update user error: %s", err))
+	if err := putFood(stub, currentOwner); err != nil {
+		return errorResponse(fmt.Errorf("update food error: %s", err))
 	}
 
 	// 插入食材变更记录
-	history := &IngredientHistory{
-		IngredientId:   ingredientId,
-		OriginOwnerId:  ownerId,
-		CurrentOwnerId: currentOwnerId,
+	if err := ingredientAsset.putHistory(stub, ingredientId, ownerId, currentOwnerId, facilityId); err != nil {
+		return errorResponse(err)
 	}
-	historyBytes, err := json.Marshal(history)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal ingredient history error: %s", err))
-	}
-
-	historyKey, err := stub.CreateCompositeKey("history", []string{
-		ingredientId,
-		ownerId,
-		currentOwnerId,
-	})
-	if err != nil {
-		return shim.Error(fmt.Sprintf("create key error: %s", err))
-	}
-
-	if err := stub.PutState(historyKey, historyBytes); err != nil {
-		return shim.Error(fmt.Sprintf("save ingredient history error: %s", err))
+	if err := followEndorsement(stub, constructIngredientKey(ingredientId), constructFoodKey(currentOwnerId)); err != nil {
+		return errorResponse(err)
 	}
 
 	return shim.Success(nil)
@@ -533,107 +540,38 @@ func (c *IngredientsExchangeCC) ingredientExchangeFood(stub shim.ChaincodeStubIn
 // 食品变更
 func (c *IngredientsExchangeCC) foodExchange(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	//检查参数的个数
-	if len(args) != 3 {
-		return shim.Error("not enough args")
+	if err := checkArgCount(args, 3, 4); err != nil {
+		return errorResponse(err)
 	}
 
 	//验证参数的正确性
 	ownerId := args[0]
-	foodId := args[1]
+	assetId := args[1]
 	currentOwnerId := args[2]
-	if ownerId == "" || foodId == "" || currentOwnerId == "" {
-		return shim.Error("invalid args")
-	}
-
-	//验证数据是否存在
-	originOwnerBytes, err := stub.GetState(constructUserKey(ownerId))
-	if err != nil || len(originOwnerBytes) == 0 {
-		return shim.Error("user not found")
-	}
-
-	currentOwnerBytes, err := stub.GetState(constructUserKey(currentOwnerId))
-	if err != nil || len(currentOwnerBytes) == 0 {
-		return shim.Error("user not found")
+	facilityId := facilityArg(args, 3)
+	if ownerId == "" || assetId == "" || currentOwnerId == "" {
+		return errorResponse(invalidArgs())
 	}
 
-	foodBytes, err := stub.GetState(constructFoodKey(foodId))
-	if err != nil || len(foodBytes) == 0 {
-		return shim.Error("food not found")
+	// 配置了审批策略的食品类别, 转让需要多方审批
+	class, policy, err := foodApprovalPolicy(stub, assetId)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get approval policy error: %s", err))
 	}
-
-	originOwner := new(User)
-	// 反序列化用户
-	if err := json.Unmarshal(originOwnerBytes, originOwner); err != nil {
-		return shim.Error(fmt.Sprintf("unmarshal user error: %s", err))
-	}
-	aidexist := false
-	for _, aid := range originOwner.Foods {
-		if aid == foodId {
-			aidexist = true
-			break
+	if policy != nil {
+		transfer, err := proposeTransfer(stub, foodAsset, class, policy, ownerId, assetId, currentOwnerId, facilityId)
+		if err != nil {
+			return errorResponse(err)
 		}
-	}
-	if !aidexist {
-		return shim.Error("food owner not match")
-	}
-
-	//写入状态
-	foodIds := make([]string, 0)
-	for _, aid := range originOwner.Foods {
-		if aid == foodId {
-			continue
+		transferBytes, err := json.Marshal(transfer)
+		if err != nil {
+			return errorResponse(fmt.Errorf("marshal error: %s", err))
 		}
-
-		foodIds = append(foodIds, aid)
+		return shim.Success(transferBytes)
 	}
-	originOwner.Foods = foodIds
 
-	originOwnerBytes, err = json.Marshal(originOwner)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal user error: %s", err))
-	}
-	if err := stub.PutState(constructUserKey(ownerId), originOwnerBytes); err != nil {
-		return shim.Error(fmt.Sprintf("update user error: %s", err))
-	}
-
-	// 当前拥有者插入食品id
-	currentOwner := new(User)
-	// 反序列化用户
-	if err := json.Unmarshal(currentOwnerBytes, currentOwner); err != nil {
-		return shim.Error(fmt.Sprintf("unmarshal user error: %s", err))
-	}
-	currentOwner.Foods = append(currentOwner.Foods, foodId)
-
-	currentOwnerBytes, err = json.Marshal(currentOwner)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal user error: %s", err))
-	}
-	if err := stub.PutState(constructUserKey(currentOwnerId), currentOwnerBytes); err != nil {
-		return shim.Error(fmt.Sprintf("update user error: %s", err))
-	}
-
-	// 插入变更记录
-	history := &FoodHistory{
-		FoodId:         foodId,
-		OriginOwnerId:  ownerId,
-		CurrentOwnerId: currentOwnerId,
-	}
-	historyBytes, err := json.Marshal(history)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal food history error: %s", err))
-	}
-
-	historyKey, err := stub.CreateCompositeKey("history", []string{
-		foodId,
-		ownerId,
-		currentOwnerId,
-	})
-	if err != nil {
-		return shim.Error(fmt.Sprintf("create key error: %s", err))
-	}
-
-	if err := stub.PutState(historyKey, historyBytes); err != nil {
-		return shim.Error(fmt.Sprintf("save food history error: %s", err))
+	if err := exchangeAsset(stub, foodAsset, ownerId, assetId, currentOwnerId, facilityId); err != nil {
+		return errorResponse(err)
 	}
 
 	return shim.Success(nil)
@@ -642,20 +580,26 @@ func (c *IngredientsExchangeCC) foodExchange(stub shim.ChaincodeStubInterface, a
 // 用户查询
 func (c *IngredientsExchangeCC) queryUser(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	//检查参数的个数
-	if len(args) != 1 {
-		return shim.Error("not enough args")
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return errorResponse(err)
 	}
 
 	//验证参数的正确性
 	ownerId := args[0]
 	if ownerId == "" {
-		return shim.Error("invalid args")
+		return errorResponse(invalidArgs())
 	}
 
 	//验证数据是否存在
 	userBytes, err := stub.GetState(constructUserKey(ownerId))
 	if err != nil || len(userBytes) == 0 {
-		return shim.Error("user not found")
+		return errorResponse(notFound("user", ownerId))
+	}
+
+	// 旧版本文档按当前结构返回
+	userBytes, err = upgradeDoc(docUser, userBytes)
+	if err != nil {
+		return errorResponse(fmt.Errorf("upgrade error: %s", err))
 	}
 
 	return shim.Success(userBytes)
@@ -664,42 +608,54 @@ func (c *IngredientsExchangeCC) queryUser(stub shim.ChaincodeStubInterface, args
 // 食材查询
 func (c *IngredientsExchangeCC) queryIngredient(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	//检查参数的个数
-	if len(args) != 1 {
-		return shim.Error("not enough args")
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return errorResponse(err)
 	}
 
 	//验证参数的正确性
 	ingredientId := args[0]
 	if ingredientId == "" {
-		return shim.Error("invalid args")
+		return errorResponse(invalidArgs())
 	}
 
 	//验证数据是否存在
 	ingredientBytes, err := stub.GetState(constructIngredientKey(ingredientId))
 	if err != nil || len(ingredientBytes) == 0 {
-		return shim.Error("ingredient not found")
+		return errorResponse(notFound("ingredient", ingredientId))
+	}
+
+	// 旧版本文档按当前结构返回
+	ingredientBytes, err = upgradeDoc(docIngredient, ingredientBytes)
+	if err != nil {
+		return errorResponse(fmt.Errorf("upgrade error: %s", err))
 	}
 
 	return shim.Success(ingredientBytes)
 }
 
-//食品查询
+// 食品查询
 func (c *IngredientsExchangeCC) queryFood(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	//检查参数的个数
-	if len(args) != 1 {
-		return shim.Error("not enough args")
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return errorResponse(err)
 	}
 
 	//验证参数的正确性
 	foodId := args[0]
 	if foodId == "" {
-		return shim.Error("invalid args")
+		return errorResponse(invalidArgs())
 	}
 
 	//验证数据是否存在
 	foodBytes, err := stub.GetState(constructFoodKey(foodId))
 	if err != nil || len(foodBytes) == 0 {
-		return shim.Error("food not found")
+		return errorResponse(notFound("food", foodId))
+	}
+
+	// 旧版本文档按当前结构返回
+	foodBytes, err = upgradeDoc(docFood, foodBytes)
+	if err != nil {
+		return errorResponse(fmt.Errorf("upgrade error: %s", err))
 	}
 
 	return shim.Success(foodBytes)
@@ -707,63 +663,28 @@ func (c *IngredientsExchangeCC) queryFood(stub shim.ChaincodeStubInterface, args
 
 // 食材变更历史查询
 func (c *IngredientsExchangeCC) queryIngredientHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	//检查参数的个数
-	if len(args) != 2 && len(args) != 1 {
-		return shim.Error("not enough args")
-	}
-
-	//验证参数的正确性
-	ingredientId := args[0]
-	if ingredientId == "" {
-		return shim.Error("invalid args")
-	}
-
-	queryType := "all"
-	if len(args) == 2 {
-		queryType = args[1]
-	}
-
-	if queryType != "all" && queryType != "enroll" && queryType != "exchange" {
-		return shim.Error(fmt.Sprintf("queryType unknown %s", queryType))
+	//检查参数
+	ingredientId, queryType, err := parseHistoryArgs(args)
+	if err != nil {
+		return errorResponse(err)
 	}
 
 	//验证数据是否存在
-	ingredientBytes, err := stub.GetState(constructIngredientKey(ingredientId))
-	if err != nil || len(ingredientBytes) == 0 {
-		return shim.Error("ingredient not found")
+	if !ingredientAsset.exists(stub, ingredientId) {
+		return errorResponse(notFound("ingredient", ingredientId))
 	}
 
 	// 查询相关数据
-	keys := make([]string, 0)
-	keys = append(keys, ingredientId)
-	switch queryType {
-	case "enroll":
-		keys = append(keys, originOwner)
-	case "exchange", "all":
-	default:
-		return shim.Error(fmt.Sprintf("unsupport queryType: %s", queryType))
-	}
-	result, err := stub.GetStateByPartialCompositeKey("history", keys)
+	values, err := ingredientAsset.histories(stub, ingredientId, queryType)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("query history error: %s", err))
+		return errorResponse(fmt.Errorf("query history error: %s", err))
 	}
-	defer result.Close()
 
 	histories := make([]*IngredientHistory, 0)
-	for result.HasNext() {
-		historyVal, err := result.Next()
-		if err != nil {
-			return shim.Error(fmt.Sprintf("query error: %s", err))
-		}
-
+	for _, value := range values {
 		history := new(IngredientHistory)
-		if err := json.Unmarshal(historyVal.GetValue(), history); err != nil {
-			return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
-		}
-
-		// 过滤掉不是食材转让的记录
-		if queryType == "exchange" && history.OriginOwnerId == originOwner {
-			continue
+		if err := unmarshalDoc(value, history); err != nil {
+			return errorResponse(fmt.Errorf("unmarshal error: %s", err))
 		}
 
 		histories = append(histories, history)
@@ -771,7 +692,7 @@ func (c *IngredientsExchangeCC) queryIngredientHistory(stub shim.ChaincodeStubIn
 
 	historiesBytes, err := json.Marshal(histories)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
 	}
 
 	return shim.Success(historiesBytes)
@@ -779,83 +700,100 @@ func (c *IngredientsExchangeCC) queryIngredientHistory(stub shim.ChaincodeStubIn
 
 // 食材变更历史查询
 func (c *IngredientsExchangeCC) queryFoodHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	//检查参数的个数
-	if len(args) != 2 && len(args) != 1 {
-		return shim.Error("not enough args")
-	}
-
-	//验证参数的正确性
-	foodId := args[0]
-	if foodId == "" {
-		return shim.Error("invalid args")
-	}
-
-	queryType := "all"
-	if len(args) == 2 {
-		queryType = args[1]
-	}
-
-	if queryType != "all" && queryType != "enroll" && queryType != "exchange" {
-		return shim.Error(fmt.Sprintf("queryType unknown %s", queryType))
+	//检查参数
+	foodId, queryType, err := parseHistoryArgs(args, processStepType)
+	if err != nil {
+		return errorResponse(err)
 	}
 
 	//验证数据是否存在
-	foodBytes, err := stub.GetState(constructFoodKey(foodId))
-	if err != nil || len(foodBytes) == 0 {
-		return shim.Error("food not found")
-	}
-
-	// 查询相关数据
-	keys := make([]string, 0)
-	keys = append(keys, foodId)
-	switch queryType {
-	case "enroll":
-		keys = append(keys, originOwner)
-	case "exchange", "all":
-	default:
-		return shim.Error(fmt.Sprintf("unsupport queryType: %s", queryType))
-	}
-	result, err := stub.GetStateByPartialCompositeKey("history", keys)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("query history error: %s", err))
+	if !foodAsset.exists(stub, foodId) {
+		return errorResponse(notFound("food", foodId))
 	}
-	defer result.Close()
 
+	// 查询相关数据, 只查询加工步骤时跳过流通记录
 	histories := make([]*FoodHistory, 0)
-	for result.HasNext() {
-		historyVal, err := result.Next()
+	if queryType != processStepType {
+		values, err := foodAsset.histories(stub, foodId, queryType)
 		if err != nil {
-			return shim.Error(fmt.Sprintf("query error: %s", err))
+			return errorResponse(fmt.Errorf("query history error: %s", err))
 		}
 
-		history := new(FoodHistory)
-		if err := json.Unmarshal(historyVal.GetValue(), history); err != nil {
-			return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
-		}
+		for _, value := range values {
+			history := new(FoodHistory)
+			if err := unmarshalDoc(value, history); err != nil {
+				return errorResponse(fmt.Errorf("unmarshal error: %s", err))
+			}
 
-		// 过滤掉不是食材转让的记录
-		if queryType == "exchange" && history.OriginOwnerId == originOwner {
-			continue
+			histories = append(histories, history)
 		}
+	}
 
-		histories = append(histories, history)
+	// 加工步骤
+	if queryType == "all" || queryType == processStepType {
+		steps, err := getFoodProcessSteps(stub, foodId)
+		if err != nil {
+			return errorResponse(fmt.Errorf("query process step error: %s", err))
+		}
+		for _, step := range steps {
+			histories = append(histories, &FoodHistory{
+				FoodId:         foodId,
+				OriginOwnerId:  step.OperatorId,
+				CurrentOwnerId: step.OperatorId,
+				Timestamp:      step.Timestamp,
+				Type:           processStepType,
+				Step:           step,
+			})
+		}
 	}
 
+	// 流通记录按组合键(拥有者)排列, 与加工步骤一起按时间排序
+	// 升级前写入的记录没有时间, 排在最前
+	sort.SliceStable(histories, func(i, j int) bool {
+		return histories[i].Timestamp < histories[j].Timestamp
+	})
+
 	historiesBytes, err := json.Marshal(histories)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
 	}
 
 	return shim.Success(historiesBytes)
 }
 
 func (c *IngredientsExchangeCC) Init(stub shim.ChaincodeStubInterface) pb.Response {
-	return shim.Success(nil)
+	_, args := stub.GetFunctionAndParameters()
+
+	return c.initConfig(stub, args)
 }
 
 func (c *IngredientsExchangeCC) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 	funcName, args := stub.GetFunctionAndParameters()
 
+	// 命名参数转换为位置参数
+	args, err := namedArgs(funcName, args)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	switch funcName {
+	case "updateConfig":
+		return c.updateConfig(stub, args)
+	case "queryConfig":
+		return c.queryConfig(stub, args)
+	case "migrate":
+		return c.migrate(stub, args)
+	}
+
+	// 功能开关
+	cfg, err := getConfig(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get config error: %s", err))
+	}
+	if !cfg.enabled(funcName) {
+		return errorResponse(newError(CodeFunctionDisabled, "function disabled: %s", funcName))
+	}
+
 	switch funcName {
 	case "userRegister":
 		return c.userRegister(stub, args)
@@ -871,6 +809,12 @@ func (c *IngredientsExchangeCC) Invoke(stub shim.ChaincodeStubInterface) pb.Resp
 		return c.foodExchange(stub, args)
 	case "ingredientExchangeFood":
 		return c.ingredientExchangeFood(stub, args)
+	case "ingredientEnrollBatch":
+		return c.ingredientEnrollBatch(stub, args)
+	case "foodEnrollBatch":
+		return c.foodEnrollBatch(stub, args)
+	case "ingredientExchangeBatch":
+		return c.ingredientExchangeBatch(stub, args)
 	case "queryUser":
 		return c.queryUser(stub, args)
 	case "queryIngredient":
@@ -881,14 +825,66 @@ func (c *IngredientsExchangeCC) Invoke(stub shim.ChaincodeStubInterface) pb.Resp
 		return c.queryIngredientHistory(stub, args)
 	case "queryFoodHistory":
 		return c.queryFoodHistory(stub, args)
+	case "foodExchangeFood":
+		return c.foodExchangeFood(stub, args)
+	case "queryFoodProvenance":
+		return c.queryFoodProvenance(stub, args)
+	case "foodProcess":
+		return c.foodProcess(stub, args)
+	case "queryProcessStep":
+		return c.queryProcessStep(stub, args)
+	case "verifyProduct":
+		return c.verifyProduct(stub, args)
+	case "foodRecall":
+		return c.foodRecall(stub, args)
+	case "queryFoodAllergens":
+		return c.queryFoodAllergens(stub, args)
+	case "containerEnroll":
+		return c.containerEnroll(stub, args)
+	case "containerPack":
+		return c.containerPack(stub, args)
+	case "containerUnpack":
+		return c.containerUnpack(stub, args)
+	case "containerExchange":
+		return c.containerExchange(stub, args)
+	case "queryContainer":
+		return c.queryContainer(stub, args)
+	case "queryContainerHistory":
+		return c.queryContainerHistory(stub, args)
+	case "facilityRegister":
+		return c.facilityRegister(stub, args)
+	case "queryFacility":
+		return c.queryFacility(stub, args)
+	case "queryFacilityItems":
+		return c.queryFacilityItems(stub, args)
+	case "certificateIssue":
+		return c.certificateIssue(stub, args)
+	case "certificateRevoke":
+		return c.certificateRevoke(stub, args)
+	case "queryCertificate":
+		return c.queryCertificate(stub, args)
+	case "queryCertificates":
+		return c.queryCertificates(stub, args)
+	case "transferApprove":
+		return c.transferApprove(stub, args)
+	case "queryTransfer":
+		return c.queryTransfer(stub, args)
+	case "queryTransfers":
+		return c.queryTransfers(stub, args)
+	case "queryEndorsement":
+		return c.queryEndorsement(stub, args)
+	case "checkConsistency":
+		return c.checkConsistency(stub, args)
+	case "repairOrphan":
+		return c.repairOrphan(stub, args)
+	case "repairReference":
+		return c.repairReference(stub, args)
+	case "repairHoldings":
+		return c.repairHoldings(stub, args)
+	case "queryRepairs":
+		return c.queryRepairs(stub, args)
 	default:
-		return shim.Error(fmt.Sprintf("unsupported function: %s", funcName))
+		return errorResponse(newError(CodeInvalidArgument, "unsupported function: %s", funcName).withField("function"))
 	}
 
 }
-func main() {
-	err := shim.Start(new(IngredientsExchangeCC))
-	if err != nil {
-		fmt.Printf("Error starting AssertsExchange chaincode: %s", err)
-	}
-}
@@ -0,0 +1,489 @@
+package food
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// 当前文档结构版本, 修改存储结构时加一并注册迁移函数
+	currentSchemaVersion = 3
+	schemaVersionField   = "schema_version"
+
+	docUser              = "user"
+	docIngredient        = "ingredient"
+	docFood              = "food"
+	docIngredientHistory = "ingredientHistory"
+	docFoodHistory       = "foodHistory"
+	docProcessStep       = "processStep"
+	docContainer         = "container"
+	docContainerHistory  = "containerHistory"
+	docFacility          = "facility"
+	docCertificate       = "certificate"
+	docTransfer          = "transfer"
+
+	defaultMigratePageSize = 100
+	maxMigratePageSize     = 1000
+)
+
+// 把文档从某个版本升级到下一个版本
+type migration func(doc map[string]interface{}) error
+
+// 迁移函数注册表: 文档类型 -> 起始版本 -> 迁移函数
+// 没有注册的版本只更新版本号
+var migrations = map[string]map[int]migration{
+	docUser: {
+		0: ensureArrays("ingredients", "foods"),
+		1: ensureArrays("containers"),
+	},
+	docIngredient: {
+		0: ensureArrays("allergens"),
+		2: ensureArrays("certifications"),
+	},
+	docFood: {
+		0: ensureArrays("ingredients", "foods", "allergens", "allergen_free"),
+		2: ensureArrays("certifications"),
+	},
+}
+
+// 旧版本中缺失或为null的数组字段补为空数组
+func ensureArrays(fields ...string) migration {
+	return func(doc map[string]interface{}) error {
+		for _, field := range fields {
+			if doc[field] == nil {
+				doc[field] = make([]interface{}, 0)
+			}
+		}
+		return nil
+	}
+}
+
+func docKind(v interface{}) string {
+	switch v.(type) {
+	case *User:
+		return docUser
+	case *Ingredient:
+		return docIngredient
+	case *Food:
+		return docFood
+	case *IngredientHistory:
+		return docIngredientHistory
+	case *FoodHistory:
+		return docFoodHistory
+	case *ProcessStep:
+		return docProcessStep
+	case *Container:
+		return docContainer
+	case *ContainerHistory:
+		return docContainerHistory
+	case *Facility:
+		return docFacility
+	case *Certificate:
+		return docCertificate
+	case *Transfer:
+		return docTransfer
+	default:
+		return ""
+	}
+}
+
+func docVersion(doc map[string]interface{}) (int, error) {
+	switch version := doc[schemaVersionField].(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		v, err := strconv.Atoi(version.String())
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %s", schemaVersionField, version)
+		}
+		return v, nil
+	default:
+		return 0, fmt.Errorf("invalid %s: %v", schemaVersionField, version)
+	}
+}
+
+// 把文档升级到当前版本, changed 表示文档是否发生了变化
+func migrateDoc(kind string, data []byte) ([]byte, bool, error) {
+	doc := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, false, err
+	}
+
+	version, err := docVersion(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	if version > currentSchemaVersion {
+		return nil, false, fmt.Errorf("%s schema version %d is newer than %d", kind, version, currentSchemaVersion)
+	}
+	if version == currentSchemaVersion {
+		return data, false, nil
+	}
+
+	for ; version < currentSchemaVersion; version++ {
+		if m, ok := migrations[kind][version]; ok {
+			if err := m(doc); err != nil {
+				return nil, false, fmt.Errorf("migrate %s from version %d error: %s", kind, version, err)
+			}
+		}
+	}
+	doc[schemaVersionField] = currentSchemaVersion
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return migrated, true, nil
+}
+
+// 读取时按需升级, 替代 json.Unmarshal
+func unmarshalDoc(data []byte, v interface{}) error {
+	if kind := docKind(v); kind != "" {
+		migrated, _, err := migrateDoc(kind, data)
+		if err != nil {
+			return err
+		}
+		data = migrated
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// 写入时标记为当前版本, 替代 json.Marshal
+func marshalDoc(v interface{}) ([]byte, error) {
+	switch doc := v.(type) {
+	case *User:
+		doc.SchemaVersion = currentSchemaVersion
+	case *Ingredient:
+		doc.SchemaVersion = currentSchemaVersion
+	case *Food:
+		doc.SchemaVersion = currentSchemaVersion
+	case *IngredientHistory:
+		doc.SchemaVersion = currentSchemaVersion
+	case *FoodHistory:
+		doc.SchemaVersion = currentSchemaVersion
+	case *ProcessStep:
+		doc.SchemaVersion = currentSchemaVersion
+	case *Container:
+		doc.SchemaVersion = currentSchemaVersion
+	case *ContainerHistory:
+		doc.SchemaVersion = currentSchemaVersion
+	case *Facility:
+		doc.SchemaVersion = currentSchemaVersion
+	case *Certificate:
+		doc.SchemaVersion = currentSchemaVersion
+	case *Transfer:
+		doc.SchemaVersion = currentSchemaVersion
+	}
+
+	return json.Marshal(v)
+}
+
+// 查询直接返回状态时, 先升级为当前版本
+func upgradeDoc(kind string, data []byte) ([]byte, error) {
+	migrated, _, err := migrateDoc(kind, data)
+	return migrated, err
+}
+
+// 根据状态键判断文档类型
+func keyDocKind(key string, value []byte) string {
+	switch {
+	case strings.HasPrefix(key, "user_"):
+		return docUser
+	case strings.HasPrefix(key, "ingredient_"):
+		return docIngredient
+	case strings.HasPrefix(key, "food_"):
+		return docFood
+	case strings.HasPrefix(key, "step_"):
+		return docProcessStep
+	case strings.HasPrefix(key, "container_"):
+		return docContainer
+	case strings.HasPrefix(key, "facility_"):
+		return docFacility
+	case strings.HasPrefix(key, "certificate_"):
+		return docCertificate
+	case strings.HasPrefix(key, "transfer_"):
+		return docTransfer
+	case strings.HasPrefix(key, compositeKeyPrefix(containerAsset.historyType)):
+		return docContainerHistory
+	case strings.HasPrefix(key, compositeKeyPrefix(ingredientAsset.historyType)):
+		return docIngredientHistory
+	case strings.HasPrefix(key, compositeKeyPrefix(foodAsset.historyType)):
+		return docFoodHistory
+	case strings.HasPrefix(key, compositeKeyPrefix(legacyHistoryType)):
+		if legacyHistoryKind(value) == ingredientAsset {
+			return docIngredientHistory
+		}
+		return docFoodHistory
+	default:
+		return ""
+	}
+}
+
+// 旧版本中食材和食品共用history命名空间, 按字段区分
+func legacyHistoryKind(value []byte) *assetKind {
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return nil
+	}
+	for _, kind := range assetKinds {
+		if _, ok := fields[kind.idField]; ok {
+			return kind
+		}
+	}
+
+	return nil
+}
+
+func compositeKeyPrefix(objectType string) string {
+	return "\x00" + objectType + "\x00"
+}
+
+// 迁移结果
+type MigrateResult struct {
+	Scanned  int    `json:"scanned"`
+	Migrated int    `json:"migrated"`
+	Bookmark string `json:"bookmark"`
+}
+
+// 分页批量迁移, 仅限管理员
+// Fabric 1.4 的分页查询只能用于只读交易, 组合键也不能用范围查询, 所以:
+//   - 用起始键对普通键手动分页, bookmark 为下一个要处理的键
+//   - 扫描到资产时一并迁移该资产的流通记录, 流通记录不需要单独分页;
+//     已删除资产的流通记录留在原处, 读取时仍会按需升级
+//   - 最后清理旧版本共用 history 命名空间中找不到资产的记录, 移走的记录被删除, 每页都从头扫描
+//
+// bookmark 为空表示迁移完成, 一个资产的流通记录在同一页中处理完, 因此一页可能超过 pageSize
+func (c *IngredientsExchangeCC) migrate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 0, 2); err != nil {
+		return errorResponse(err)
+	}
+
+	cfg, err := getConfig(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get config error: %s", err))
+	}
+	if _, err := checkAdmin(stub, cfg); err != nil {
+		return errorResponse(err)
+	}
+
+	pageSize := defaultMigratePageSize
+	if len(args) >= 1 && args[0] != "" {
+		pageSize, err = strconv.Atoi(args[0])
+		if err != nil || pageSize < 1 || pageSize > maxMigratePageSize {
+			return errorResponse(invalidArgs())
+		}
+	}
+	bookmark := ""
+	if len(args) == 2 {
+		bookmark = args[1]
+	}
+
+	result := &MigrateResult{}
+	switch {
+	case bookmark == "" || strings.HasPrefix(bookmark, "s:"):
+		bookmark, err = migrateStates(stub, pageSize, strings.TrimPrefix(bookmark, "s:"), result)
+		// 普通键扫描完后清理旧的流通记录
+		if err == nil && bookmark == "" {
+			bookmark = "h:"
+		}
+	case bookmark == "h:":
+		bookmark, err = migrateLegacyHistories(stub, pageSize, result)
+	default:
+		return errorResponse(newError(CodeInvalidArgument, "invalid bookmark").withField("bookmark"))
+	}
+	if err != nil {
+		return errorResponse(fmt.Errorf("migrate error: %s", err))
+	}
+	result.Bookmark = bookmark
+
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(resultBytes)
+}
+
+// 从 start 开始迁移一页普通键, 返回下一页的bookmark, 扫描完返回空
+func migrateStates(stub shim.ChaincodeStubInterface, pageSize int, start string, result *MigrateResult) (string, error) {
+	iter, err := stub.GetStateByRange(start, string(utf8.MaxRune))
+	if err != nil {
+		return "", fmt.Errorf("query state error: %s", err)
+	}
+	defer iter.Close()
+
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return "", err
+		}
+		// 普通键扫描不处理组合键
+		if strings.HasPrefix(kv.GetKey(), "\x00") {
+			continue
+		}
+		if result.Scanned >= pageSize {
+			return "s:" + kv.GetKey(), nil
+		}
+		if err := migrateState(stub, kv.GetKey(), kv.GetValue(), result); err != nil {
+			return "", fmt.Errorf("key %q: %s", kv.GetKey(), err)
+		}
+
+		// 资产的流通记录跟随资产迁移
+		for _, kind := range assetKinds {
+			if id := strings.TrimPrefix(kv.GetKey(), kind.key("")); id != kv.GetKey() {
+				if err := migrateAssetHistories(stub, kind, id, result); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// 迁移资产的流通记录, 包括旧版本共用命名空间中的记录, 并补齐拥有者的资产索引
+func migrateAssetHistories(stub shim.ChaincodeStubInterface, k *assetKind, id string, result *MigrateResult) error {
+	for _, historyType := range []string{legacyHistoryType, k.historyType} {
+		iter, err := stub.GetStateByPartialCompositeKey(historyType, []string{id})
+		if err != nil {
+			return err
+		}
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			if err != nil {
+				iter.Close()
+				return err
+			}
+			// 共用命名空间中可能有同id的其他类型资产
+			if historyType == legacyHistoryType && legacyHistoryKind(kv.GetValue()) != k {
+				continue
+			}
+			if err := migrateState(stub, kv.GetKey(), kv.GetValue(), result); err != nil {
+				iter.Close()
+				return fmt.Errorf("key %q: %s", kv.GetKey(), err)
+			}
+			if err := ensureHolderIndex(stub, k, id, kv.GetValue()); err != nil {
+				iter.Close()
+				return fmt.Errorf("key %q: %s", kv.GetKey(), err)
+			}
+		}
+		iter.Close()
+	}
+
+	return nil
+}
+
+// 升级前写入的流通记录没有拥有者的资产索引, 缺少时补上
+func ensureHolderIndex(stub shim.ChaincodeStubInterface, k *assetKind, id string, value []byte) error {
+	edge := new(historyEdge)
+	if err := json.Unmarshal(value, edge); err != nil {
+		return err
+	}
+	if edge.CurrentOwnerId == "" {
+		return nil
+	}
+
+	indexKey, err := k.holderIndexKey(stub, edge.CurrentOwnerId, id)
+	if err != nil {
+		return err
+	}
+	indexBytes, err := stub.GetState(indexKey)
+	if err != nil || len(indexBytes) != 0 {
+		return err
+	}
+
+	return stub.PutState(indexKey, indexValue)
+}
+
+// 迁移旧版本共用命名空间中剩下的记录, 即资产已被删除的流通记录
+// 移走的记录被删除, 剩余记录为空时迁移完成
+func migrateLegacyHistories(stub shim.ChaincodeStubInterface, pageSize int, result *MigrateResult) (string, error) {
+	iter, err := stub.GetStateByPartialCompositeKey(legacyHistoryType, []string{})
+	if err != nil {
+		return "", err
+	}
+	defer iter.Close()
+
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return "", err
+		}
+		if result.Scanned >= pageSize {
+			return "h:", nil
+		}
+		if err := migrateState(stub, kv.GetKey(), kv.GetValue(), result); err != nil {
+			return "", fmt.Errorf("key %q: %s", kv.GetKey(), err)
+		}
+	}
+
+	return "", nil
+}
+
+// 把旧版本共用命名空间中的流通记录移动到资产类型的命名空间
+func moveLegacyHistory(stub shim.ChaincodeStubInterface, key string, value []byte) error {
+	kind := legacyHistoryKind(value)
+	if kind == nil {
+		return fmt.Errorf("unknown history record")
+	}
+
+	_, attrs, err := stub.SplitCompositeKey(key)
+	if err != nil {
+		return err
+	}
+	if len(attrs) != 3 {
+		return fmt.Errorf("invalid history key")
+	}
+	historyKey, err := kind.historyKey(stub, attrs[0], attrs[1], attrs[2])
+	if err != nil {
+		return err
+	}
+
+	if err := stub.PutState(historyKey, value); err != nil {
+		return err
+	}
+
+	return stub.DelState(key)
+}
+
+// 迁移一个键, 旧版本共用命名空间中的流通记录移动到资产类型的命名空间
+func migrateState(stub shim.ChaincodeStubInterface, key string, value []byte, result *MigrateResult) error {
+	result.Scanned++
+
+	kind := keyDocKind(key, value)
+	if kind == "" {
+		return nil
+	}
+	migrated, changed, err := migrateDoc(kind, value)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(key, compositeKeyPrefix(legacyHistoryType)) {
+		if err := moveLegacyHistory(stub, key, migrated); err != nil {
+			return err
+		}
+		result.Migrated++
+		return nil
+	}
+	if !changed {
+		return nil
+	}
+	if err := stub.PutState(key, migrated); err != nil {
+		return err
+	}
+	result.Migrated++
+
+	return nil
+}
@@ -0,0 +1,304 @@
+package food_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+	"github.com/Blockchain-book/Fabric-Food/simulator"
+)
+
+// 各版本的文档, 版本0没有 schema_version 字段
+var docFixtures = []struct {
+	name     string
+	key      string
+	value    string
+	doc      interface{}
+	expected interface{}
+}{
+	{
+		name:     "user v0",
+		key:      "user_u0",
+		value:    `{"name":"alice","id":"u0"}`,
+		doc:      new(food.User),
+		expected: &food.User{Name: "alice", Id: "u0", Ingredients: []string{}, Foods: []string{}, Containers: []string{}},
+	},
+	{
+		name:     "user v1",
+		key:      "user_u1",
+		value:    `{"name":"bob","id":"u1","ingredients":["i0"],"foods":[],"schema_version":1}`,
+		doc:      new(food.User),
+		expected: &food.User{Name: "bob", Id: "u1", Ingredients: []string{"i0"}, Foods: []string{}, Containers: []string{}},
+	},
+	{
+		name:     "user v2",
+		key:      "user_u2",
+		value:    `{"name":"carol","id":"u2","ingredients":[],"foods":["f2"],"containers":["c1"],"msp":"Org1MSP","schema_version":2}`,
+		doc:      new(food.User),
+		expected: &food.User{Name: "carol", Id: "u2", Ingredients: []string{}, Foods: []string{"f2"}, Containers: []string{"c1"}, MSP: "Org1MSP"},
+	},
+	{
+		name:     "ingredient v0",
+		key:      "ingredient_i0",
+		value:    `{"name":"rice","id":"i0","metadata":"m"}`,
+		doc:      new(food.Ingredient),
+		expected: &food.Ingredient{Name: "rice", Id: "i0", Metadata: "m", Allergens: []string{}, Certifications: []*food.CertificationClaim{}},
+	},
+	{
+		name:     "ingredient v1",
+		key:      "ingredient_i1",
+		value:    `{"name":"wheat","id":"i1","metadata":"","allergens":["gluten"],"schema_version":1}`,
+		doc:      new(food.Ingredient),
+		expected: &food.Ingredient{Name: "wheat", Id: "i1", Allergens: []string{"gluten"}, Certifications: []*food.CertificationClaim{}},
+	},
+	{
+		name:     "ingredient v2",
+		key:      "ingredient_i2",
+		value:    `{"name":"milk","id":"i2","metadata":"","allergens":["milk"],"certifications":null,"schema_version":2}`,
+		doc:      new(food.Ingredient),
+		expected: &food.Ingredient{Name: "milk", Id: "i2", Allergens: []string{"milk"}, Certifications: []*food.CertificationClaim{}},
+	},
+	{
+		name:  "food v0",
+		key:   "food_f0",
+		value: `{"name":"bread","id":"f0","metadata":"","ingredients":["i1"]}`,
+		doc:   new(food.Food),
+		expected: &food.Food{Name: "bread", Id: "f0", Ingredients: []string{"i1"}, Foods: []string{}, Allergens: []string{},
+			AllergenFree: []string{}, Certifications: []*food.CertificationClaim{}},
+	},
+	{
+		name:  "food v1",
+		key:   "food_f1",
+		value: `{"name":"cake","id":"f1","metadata":"","ingredients":[],"foods":["f0"],"allergens":["gluten"],"allergen_free":[],"schema_version":1}`,
+		doc:   new(food.Food),
+		expected: &food.Food{Name: "cake", Id: "f1", Ingredients: []string{}, Foods: []string{"f0"}, Allergens: []string{"gluten"},
+			AllergenFree: []string{}, Certifications: []*food.CertificationClaim{}},
+	},
+	{
+		name:  "food v2",
+		key:   "food_f2",
+		value: `{"name":"pie","id":"f2","metadata":"","ingredients":[],"foods":[],"allergens":[],"allergen_free":["nuts"],"schema_version":2}`,
+		doc:   new(food.Food),
+		expected: &food.Food{Name: "pie", Id: "f2", Ingredients: []string{}, Foods: []string{}, Allergens: []string{},
+			AllergenFree: []string{"nuts"}, Certifications: []*food.CertificationClaim{}},
+	},
+}
+
+// 流通记录: 命名空间, 组合键属性, 值
+var historyFixtures = []struct {
+	name      string
+	namespace string
+	attrs     []string
+	value     string
+	// 迁移后所在的命名空间
+	migrated string
+	doc      interface{}
+	expected interface{}
+}{
+	{
+		name:      "legacy ingredient history v0",
+		namespace: "history",
+		attrs:     []string{"i0", "originPlaceholder", "u1"},
+		value:     `{"ingredient_id":"i0","origin_owner_id":"originPlaceholder","current_owner_id":"u1"}`,
+		migrated:  "ingredientHistory",
+		doc:       new(food.IngredientHistory),
+		expected:  &food.IngredientHistory{IngredientId: "i0", OriginOwnerId: "originPlaceholder", CurrentOwnerId: "u1"},
+	},
+	{
+		name:      "legacy food history v1",
+		namespace: "history",
+		attrs:     []string{"f0", "u1", "u2"},
+		value:     `{"food_id":"f0","origin_owner_id":"u1","current_owner_id":"u2","schema_version":1}`,
+		migrated:  "foodHistory",
+		doc:       new(food.FoodHistory),
+		expected:  &food.FoodHistory{FoodId: "f0", OriginOwnerId: "u1", CurrentOwnerId: "u2"},
+	},
+	{
+		name:      "legacy history of a deleted ingredient",
+		namespace: "history",
+		attrs:     []string{"gone", "originPlaceholder", "u0"},
+		value:     `{"ingredient_id":"gone","origin_owner_id":"originPlaceholder","current_owner_id":"u0"}`,
+		migrated:  "ingredientHistory",
+		doc:       new(food.IngredientHistory),
+		expected:  &food.IngredientHistory{IngredientId: "gone", OriginOwnerId: "originPlaceholder", CurrentOwnerId: "u0"},
+	},
+	{
+		name:      "ingredient history v2",
+		namespace: "ingredientHistory",
+		attrs:     []string{"i1", "u1", "u2"},
+		value:     `{"ingredient_id":"i1","origin_owner_id":"u1","current_owner_id":"u2","facility_id":"fac1","schema_version":2}`,
+		migrated:  "ingredientHistory",
+		doc:       new(food.IngredientHistory),
+		expected:  &food.IngredientHistory{IngredientId: "i1", OriginOwnerId: "u1", CurrentOwnerId: "u2", FacilityId: "fac1"},
+	},
+	{
+		name:      "food history v0",
+		namespace: "foodHistory",
+		attrs:     []string{"f1", "u1", "u2"},
+		value:     `{"food_id":"f1","origin_owner_id":"u1","current_owner_id":"u2"}`,
+		migrated:  "foodHistory",
+		doc:       new(food.FoodHistory),
+		expected:  &food.FoodHistory{FoodId: "f1", OriginOwnerId: "u1", CurrentOwnerId: "u2"},
+	},
+}
+
+// 设置期望值的版本号, 读取和迁移后都应为当前版本
+func withCurrentVersion(v interface{}) interface{} {
+	reflect.ValueOf(v).Elem().FieldByName("SchemaVersion").SetInt(food.CurrentSchemaVersion)
+	return v
+}
+
+func newDoc(v interface{}) interface{} {
+	return reflect.New(reflect.TypeOf(v).Elem()).Interface()
+}
+
+func TestUnmarshalDoc(t *testing.T) {
+	for _, f := range docFixtures {
+		t.Run(f.name, func(t *testing.T) {
+			doc := newDoc(f.doc)
+			if err := food.UnmarshalDoc([]byte(f.value), doc); err != nil {
+				t.Fatalf("unmarshal error: %s", err)
+			}
+			if expected := withCurrentVersion(f.expected); !reflect.DeepEqual(doc, expected) {
+				t.Errorf("expected %+v, got %+v", expected, doc)
+			}
+		})
+	}
+
+	for _, f := range historyFixtures {
+		t.Run(f.name, func(t *testing.T) {
+			doc := newDoc(f.doc)
+			if err := food.UnmarshalDoc([]byte(f.value), doc); err != nil {
+				t.Fatalf("unmarshal error: %s", err)
+			}
+			if expected := withCurrentVersion(f.expected); !reflect.DeepEqual(doc, expected) {
+				t.Errorf("expected %+v, got %+v", expected, doc)
+			}
+		})
+	}
+
+	if err := food.UnmarshalDoc([]byte(`{"id":"u9","schema_version":99}`), new(food.User)); err == nil {
+		t.Errorf("expected error for a newer schema version")
+	}
+}
+
+// 写入各版本的文档, 返回以管理员身份调用的模拟账本
+func newFixtureLedger(t *testing.T) *simulator.Simulator {
+	sim, err := simulator.New(`{"admin_msps":["Org1MSP"]}`, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.AddIdentity("admin", &gateway.Identity{MSPID: "Org1MSP"}); err != nil {
+		t.Fatal(err)
+	}
+
+	stub := sim.Stub
+	stub.MockTransactionStart("fixtures")
+	for _, f := range docFixtures {
+		stub.PutState(f.key, []byte(f.value))
+	}
+	for _, f := range historyFixtures {
+		key, err := stub.CreateCompositeKey(f.namespace, f.attrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stub.PutState(key, []byte(f.value))
+	}
+	stub.MockTransactionEnd("fixtures")
+
+	return sim
+}
+
+// 按页调用 migrate 直到 bookmark 为空
+func runMigrate(t *testing.T, sim *simulator.Simulator, pageSize string) (pages int, total *food.MigrateResult) {
+	total = new(food.MigrateResult)
+	bookmark := ""
+	for {
+		payload, err := sim.Invoke("admin", "migrate", []string{pageSize, bookmark})
+		if err != nil {
+			t.Fatalf("migrate page %d error: %s", pages+1, err)
+		}
+		result := new(food.MigrateResult)
+		if err := json.Unmarshal(payload, result); err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		total.Scanned += result.Scanned
+		total.Migrated += result.Migrated
+		if result.Bookmark == "" {
+			return pages, total
+		}
+		if pages > 100 {
+			t.Fatalf("migrate did not finish, last bookmark %q", result.Bookmark)
+		}
+		bookmark = result.Bookmark
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	sim := newFixtureLedger(t)
+
+	pages, total := runMigrate(t, sim, "2")
+	if pages < 2 {
+		t.Errorf("expected several pages, got %d", pages)
+	}
+	// 每个键只扫描一次: config、文档和流通记录
+	if expected := 1 + len(docFixtures) + len(historyFixtures); total.Scanned != expected {
+		t.Errorf("expected %d keys scanned, got %d", expected, total.Scanned)
+	}
+	// 只有已是当前版本的文档不需要迁移, 固定数据中都是旧版本
+	if expected := len(docFixtures) + len(historyFixtures); total.Migrated != expected {
+		t.Errorf("expected %d docs migrated, got %d", expected, total.Migrated)
+	}
+
+	stub := sim.Stub
+	for _, f := range docFixtures {
+		value := stub.State[f.key]
+		if !strings.Contains(string(value), fmt.Sprintf(`"schema_version":%d`, food.CurrentSchemaVersion)) {
+			t.Errorf("%s: not migrated: %s", f.name, value)
+		}
+		doc := newDoc(f.doc)
+		if err := json.Unmarshal(value, doc); err != nil {
+			t.Fatal(err)
+		}
+		if expected := withCurrentVersion(f.expected); !reflect.DeepEqual(doc, expected) {
+			t.Errorf("%s: expected %+v, got %+v", f.name, expected, doc)
+		}
+	}
+
+	for _, f := range historyFixtures {
+		oldKey, _ := stub.CreateCompositeKey(f.namespace, f.attrs)
+		newKey, _ := stub.CreateCompositeKey(f.migrated, f.attrs)
+		if oldKey != newKey && stub.State[oldKey] != nil {
+			t.Errorf("%s: legacy record not removed", f.name)
+		}
+		value := stub.State[newKey]
+		doc := newDoc(f.doc)
+		if err := json.Unmarshal(value, doc); err != nil {
+			t.Fatalf("%s: %s: %s", f.name, err, value)
+		}
+		if expected := withCurrentVersion(f.expected); !reflect.DeepEqual(doc, expected) {
+			t.Errorf("%s: expected %+v, got %+v", f.name, expected, doc)
+		}
+	}
+
+	// 再次迁移没有需要修改的文档
+	if _, again := runMigrate(t, sim, "100"); again.Migrated != 0 {
+		t.Errorf("expected nothing to migrate, got %d", again.Migrated)
+	}
+}
+
+func TestMigrateRejectsInvalidBookmark(t *testing.T) {
+	sim := newFixtureLedger(t)
+
+	for _, bookmark := range []string{"x:food_f0", "h:food_f0"} {
+		if _, err := sim.Invoke("admin", "migrate", []string{"10", bookmark}); err == nil {
+			t.Errorf("expected error for bookmark %q", bookmark)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package food
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	processStepType = "process"
+)
+
+// 食品加工步骤 (烹饪、包装、切分、重新贴标等)
+type ProcessStep struct {
+	Id              string            `json:"id"`
+	StepType        string            `json:"step_type"`
+	FacilityId      string            `json:"facility_id"`
+	OperatorId      string            `json:"operator_id"`
+	InputIds        []string          `json:"input_ids"`
+	OutputIds       []string          `json:"output_ids"`
+	Parameters      map[string]string `json:"parameters"`
+	PreviousStepIds []string          `json:"previous_step_ids"`
+	Timestamp       string            `json:"timestamp"`
+	SchemaVersion   int               `json:"schema_version"`
+}
+
+func constructProcessStepKey(stepId string) string {
+	return fmt.Sprintf("step_%s", stepId)
+}
+
+// 解析逗号分隔的id列表
+func parseIdList(raw string) []string {
+	ids := make([]string, 0)
+	for _, id := range strings.Split(raw, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// 交易时间, 所有背书节点上一致
+func txTimestamp(stub shim.ChaincodeStubInterface) (string, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return "", err
+	}
+
+	return time.Unix(ts.GetSeconds(), int64(ts.GetNanos())).UTC().Format(time.RFC3339), nil
+}
+
+// 查询食品的全部加工步骤, 按时间排序
+func getFoodProcessSteps(stub shim.ChaincodeStubInterface, foodId string) ([]*ProcessStep, error) {
+	result, err := stub.GetStateByPartialCompositeKey(processStepType, []string{foodId})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	steps := make([]*ProcessStep, 0)
+	for result.HasNext() {
+		indexVal, err := result.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		stepBytes, err := stub.GetState(constructProcessStepKey(string(indexVal.GetValue())))
+		if err != nil || len(stepBytes) == 0 {
+			continue
+		}
+
+		step := new(ProcessStep)
+		if err := unmarshalDoc(stepBytes, step); err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+
+	sort.SliceStable(steps, func(i, j int) bool {
+		if steps[i].Timestamp != steps[j].Timestamp {
+			return steps[i].Timestamp < steps[j].Timestamp
+		}
+		return steps[i].Id < steps[j].Id
+	})
+
+	return steps, nil
+}
+
+// 食品加工
+func (c *IngredientsExchangeCC) foodProcess(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 6, 7); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	stepId := args[0]
+	stepType := args[1]
+	facilityId := args[2]
+	operatorId := args[3]
+	inputIds := parseIdList(args[4])
+	outputIds := parseIdList(args[5])
+	if stepId == "" || stepType == "" || operatorId == "" || len(inputIds)+len(outputIds) == 0 {
+		return errorResponse(invalidArgs())
+	}
+
+	parameters := make(map[string]string)
+	if len(args) == 7 && args[6] != "" {
+		if err := json.Unmarshal([]byte(args[6]), &parameters); err != nil {
+			return errorResponse(newError(CodeInvalidArgument, "invalid parameters: %s", err).withField("parameters"))
+		}
+	}
+
+	//验证数据是否存在
+	if stepBytes, err := stub.GetState(constructProcessStepKey(stepId)); err == nil && len(stepBytes) != 0 {
+		return errorResponse(alreadyExists("process step", stepId))
+	}
+
+	operatorBytes, err := stub.GetState(constructUserKey(operatorId))
+	if err != nil || len(operatorBytes) == 0 {
+		return errorResponse(notFound("user", operatorId))
+	}
+	if err := checkFacility(stub, facilityId); err != nil {
+		return errorResponse(err)
+	}
+
+	operator := new(User)
+	if err := unmarshalDoc(operatorBytes, operator); err != nil {
+		return errorResponse(fmt.Errorf("unmarshal user error: %s", err))
+	}
+
+	// 加工者必须拥有所有涉及的食品
+	foodIds := append(append(make([]string, 0), inputIds...), outputIds...)
+	for _, foodId := range foodIds {
+		foodBytes, err := stub.GetState(constructFoodKey(foodId))
+		if err != nil || len(foodBytes) == 0 {
+			return errorResponse(notFound("food", foodId))
+		}
+
+		owned := false
+		for _, fid := range operator.Foods {
+			if fid == foodId {
+				owned = true
+				break
+			}
+		}
+		if !owned {
+			return errorResponse(ownerMismatch("food", foodId))
+		}
+	}
+
+	// 链接到输入食品的上一个加工步骤
+	previousStepIds := make([]string, 0)
+	for _, foodId := range inputIds {
+		steps, err := getFoodProcessSteps(stub, foodId)
+		if err != nil {
+			return errorResponse(fmt.Errorf("query process step error: %s", err))
+		}
+		if len(steps) != 0 {
+			previousStepIds = append(previousStepIds, steps[len(steps)-1].Id)
+		}
+	}
+
+	timestamp, err := txTimestamp(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get tx timestamp error: %s", err))
+	}
+
+	//写入状态
+	step := &ProcessStep{
+		Id:              stepId,
+		StepType:        stepType,
+		FacilityId:      facilityId,
+		OperatorId:      operatorId,
+		InputIds:        inputIds,
+		OutputIds:       outputIds,
+		Parameters:      parameters,
+		PreviousStepIds: previousStepIds,
+		Timestamp:       timestamp,
+	}
+	stepBytes, err := marshalDoc(step)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal process step error: %s", err))
+	}
+	if err := stub.PutState(constructProcessStepKey(stepId), stepBytes); err != nil {
+		return errorResponse(fmt.Errorf("save process step error: %s", err))
+	}
+
+	// 为每个涉及的食品建立索引
+	indexed := make(map[string]bool)
+	for _, foodId := range foodIds {
+		if indexed[foodId] {
+			continue
+		}
+		indexed[foodId] = true
+
+		indexKey, err := stub.CreateCompositeKey(processStepType, []string{foodId, stepId})
+		if err != nil {
+			return errorResponse(fmt.Errorf("create key error: %s", err))
+		}
+		if err := stub.PutState(indexKey, []byte(stepId)); err != nil {
+			return errorResponse(fmt.Errorf("save process step index error: %s", err))
+		}
+
+		// 加工不改变拥有者, 设施索引的原拥有者和新拥有者都是加工者
+		if facilityId == "" {
+			continue
+		}
+		if err := putFacilityVisit(stub, foodAsset, foodId, operatorId, operatorId, facilityId); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	return shim.Success(nil)
+}
+
+// 加工步骤查询
+func (c *IngredientsExchangeCC) queryProcessStep(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	stepId := args[0]
+	if stepId == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	stepBytes, err := stub.GetState(constructProcessStepKey(stepId))
+	if err != nil || len(stepBytes) == 0 {
+		return errorResponse(notFound("process step", stepId))
+	}
+
+	// 旧版本文档按当前结构返回
+	stepBytes, err = upgradeDoc(docProcessStep, stepBytes)
+	if err != nil {
+		return errorResponse(fmt.Errorf("upgrade error: %s", err))
+	}
+
+	return shim.Success(stepBytes)
+}
@@ -0,0 +1,83 @@
+package food_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+)
+
+// 加工步骤检查设施并为输入和输出食品写入设施索引
+func TestFoodProcessFacility(t *testing.T) {
+	sim := newAssetLedger(t)
+	for _, req := range []food.Request{
+		&food.FacilityRegisterRequest{Id: "fac1", Name: "kitchen", Type: "factory", OwnerId: "u1", License: "L1"},
+		&food.FoodEnrollRequest{Name: "dough", Id: "f1", OwnerId: "u1"},
+		&food.FoodEnrollRequest{Name: "bread", Id: "f2", OwnerId: "u1"},
+	} {
+		mustExecute(t, sim, req)
+	}
+
+	unknown := &food.FoodProcessRequest{Id: "s0", StepType: "bake", FacilityId: "missing", OperatorId: "u1", InputIds: []string{"f1"}, OutputIds: []string{"f2"}}
+	if _, code, err := execute(sim, unknown); err != nil || code != food.CodeNotFound {
+		t.Fatalf("expected %s for an unknown facility, got %q %v", food.CodeNotFound, code, err)
+	}
+
+	mustExecute(t, sim, &food.FoodProcessRequest{Id: "s1", StepType: "bake", FacilityId: "fac1", OperatorId: "u1", InputIds: []string{"f1"}, OutputIds: []string{"f2"}})
+
+	visits := make([]*food.FacilityVisit, 0)
+	if err := json.Unmarshal(mustExecute(t, sim, &food.QueryFacilityItemsRequest{FacilityId: "fac1", Kind: "food"}), &visits); err != nil {
+		t.Fatal(err)
+	}
+	visited := make(map[string]bool)
+	for _, visit := range visits {
+		if visit.OriginOwnerId != "u1" || visit.CurrentOwnerId != "u1" || visit.Timestamp == "" {
+			t.Errorf("unexpected visit %+v", visit)
+		}
+		visited[visit.AssetId] = true
+	}
+	if len(visits) != 2 || !visited["f1"] || !visited["f2"] {
+		t.Errorf("expected visits for f1 and f2, got %+v", visits)
+	}
+}
+
+// all 查询中的加工步骤和流通记录按时间合并, 而不是按组合键排列
+func TestFoodHistoryOrder(t *testing.T) {
+	sim := newAssetLedger(t)
+	for _, req := range []food.Request{
+		&food.FoodEnrollRequest{Name: "dough", Id: "f1", OwnerId: "u2"},
+		&food.FoodProcessRequest{Id: "s1", StepType: "knead", OperatorId: "u2", InputIds: []string{"f1"}},
+		// (f1, u1, u2) 的组合键排在 (f1, u2, u1) 之前
+		&food.FoodExchangeRequest{OwnerId: "u2", FoodId: "f1", CurrentOwnerId: "u1"},
+		&food.FoodExchangeRequest{OwnerId: "u1", FoodId: "f1", CurrentOwnerId: "u2"},
+		&food.FoodProcessRequest{Id: "s2", StepType: "bake", OperatorId: "u2", InputIds: []string{"f1"}},
+	} {
+		mustExecute(t, sim, req)
+	}
+
+	histories := make([]*food.FoodHistory, 0)
+	if err := json.Unmarshal(mustExecute(t, sim, &food.QueryFoodHistoryRequest{FoodId: "f1", QueryType: "all"}), &histories); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"originPlaceholder>u2", "s1", "u2>u1", "u1>u2", "s2"}
+	got := make([]string, 0, len(histories))
+	for i, history := range histories {
+		if i > 0 && history.Timestamp < histories[i-1].Timestamp {
+			t.Errorf("history %d at %s is before %s", i, history.Timestamp, histories[i-1].Timestamp)
+		}
+		if history.Step != nil {
+			got = append(got, history.Step.Id)
+			continue
+		}
+		got = append(got, history.OriginOwnerId+">"+history.CurrentOwnerId)
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	}
+}
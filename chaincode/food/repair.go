@@ -0,0 +1,420 @@
+package food
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	repairKey   = "repair"
+	repairEvent = "repair"
+
+	repairReassignOrphan  = "reassignOrphan"
+	repairRemoveReference = "removeReference"
+	repairRebuildHoldings = "rebuildHoldings"
+)
+
+// 修复记录
+type RepairRecord struct {
+	Id          string   `json:"id"`
+	Action      string   `json:"action"`
+	Target      string   `json:"target"`
+	Reason      string   `json:"reason"`
+	Before      []string `json:"before"`
+	After       []string `json:"after"`
+	OperatorMSP string   `json:"operator_msp"`
+	Operator    string   `json:"operator"`
+	Timestamp   string   `json:"timestamp"`
+}
+
+// 校验管理员并生成修复记录
+func newRepairRecord(stub shim.ChaincodeStubInterface, action, target, reason string) (*RepairRecord, error) {
+	cfg, err := getConfig(stub)
+	if err != nil {
+		return nil, fmt.Errorf("get config error: %s", err)
+	}
+	msp, err := checkAdmin(stub, cfg)
+	if err != nil {
+		return nil, err
+	}
+	operator, err := cid.GetID(stub)
+	if err != nil {
+		return nil, fmt.Errorf("get client identity error: %s", err)
+	}
+	timestamp, err := txTimestamp(stub)
+	if err != nil {
+		return nil, fmt.Errorf("get timestamp error: %s", err)
+	}
+
+	return &RepairRecord{
+		Id:          stub.GetTxID(),
+		Action:      action,
+		Target:      target,
+		Reason:      reason,
+		OperatorMSP: msp,
+		Operator:    operator,
+		Timestamp:   timestamp,
+	}, nil
+}
+
+// 写入修复记录并发送事件
+func putRepairRecord(stub shim.ChaincodeStubInterface, record *RepairRecord) pb.Response {
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal repair error: %s", err))
+	}
+
+	recordKey, err := stub.CreateCompositeKey(repairKey, []string{record.Id})
+	if err != nil {
+		return errorResponse(fmt.Errorf("create key error: %s", err))
+	}
+	if err := stub.PutState(recordKey, recordBytes); err != nil {
+		return errorResponse(fmt.Errorf("save repair error: %s", err))
+	}
+	if err := stub.SetEvent(repairEvent, recordBytes); err != nil {
+		return errorResponse(fmt.Errorf("set event error: %s", err))
+	}
+
+	return shim.Success(recordBytes)
+}
+
+func removeId(ids []string, id string) ([]string, bool) {
+	result := make([]string, 0, len(ids))
+	found := false
+	for _, aid := range ids {
+		if aid == id {
+			found = true
+			continue
+		}
+		result = append(result, aid)
+	}
+
+	return result, found
+}
+
+// 把无拥有者的资产重新分配给用户
+// 参数: kind(ingredient|food), assetId, userId, reason
+func (c *IngredientsExchangeCC) repairOrphan(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 4, 4); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	kind := lookupAssetKind(args[0])
+	assetId := args[1]
+	userId := args[2]
+	reason := args[3]
+	if kind == nil || assetId == "" || userId == "" || reason == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	record, err := newRepairRecord(stub, repairReassignOrphan, kind.key(assetId), reason)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	//验证数据是否存在
+	if !kind.exists(stub, assetId) {
+		return errorResponse(notFound(kind.name, assetId))
+	}
+
+	user, err := getUser(stub, userId)
+	if err != nil || user == nil {
+		return errorResponse(notFound("user", userId))
+	}
+
+	// 只修复确实存在的不一致
+	edges, err := historyEdges(stub, kind, assetId)
+	if err != nil {
+		return errorResponse(fmt.Errorf("query history error: %s", err))
+	}
+	owners, err := assetOwners(stub, kind, assetId, edges)
+	if err != nil {
+		return errorResponse(fmt.Errorf("query owners error: %s", err))
+	}
+	if len(owners) != 0 {
+		return errorResponse(newError(CodeFailedPrecondition, "inconsistency not found: asset has an owner").withEntity(assetId))
+	}
+
+	//写入状态
+	kind.addHolding(user, assetId)
+	if err := putUser(stub, user); err != nil {
+		return errorResponse(fmt.Errorf("update user error: %s", err))
+	}
+
+	// 流通记录从最后拥有者接到新拥有者
+	lastOwner, ok := lastOwnerOf(edges)
+	if !ok {
+		lastOwner = originOwner
+	}
+
+	if err := kind.putHistory(stub, assetId, lastOwner, userId, ""); err != nil {
+		return errorResponse(err)
+	}
+	if err := setOwnerEndorsement(stub, kind.key(assetId), user); err != nil {
+		return errorResponse(err)
+	}
+
+	record.Before = make([]string, 0)
+	record.After = []string{constructUserKey(userId)}
+
+	return putRepairRecord(stub, record)
+}
+
+// 删除用户或食品中指向不存在资产的引用
+// 参数: holderType(user|food), holderId, kind(ingredient|food), assetId, reason
+func (c *IngredientsExchangeCC) repairReference(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 5, 5); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	holder := args[0]
+	holderId := args[1]
+	kind := lookupAssetKind(args[2])
+	assetId := args[3]
+	reason := args[4]
+	if (holder != holderUser && holder != holderFood) || holderId == "" || kind == nil || assetId == "" || reason == "" {
+		return errorResponse(invalidArgs())
+	}
+	// 食品只引用食材和子食品
+	if holder == holderFood && kind == containerAsset {
+		return errorResponse(invalidArgs())
+	}
+
+	target := constructUserKey(holderId)
+	if holder == holderFood {
+		target = constructFoodKey(holderId)
+	}
+	record, err := newRepairRecord(stub, repairRemoveReference, target, reason)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	// 只修复确实存在的不一致
+	assetBytes, err := stub.GetState(kind.key(assetId))
+	if err != nil {
+		return errorResponse(fmt.Errorf("query %s error: %s", kind.name, err))
+	}
+	if len(assetBytes) != 0 {
+		return errorResponse(newError(CodeFailedPrecondition, "inconsistency not found: %s exists", kind.name).withEntity(assetId))
+	}
+
+	//写入状态
+	found := false
+	if holder == holderUser {
+		user, err := getUser(stub, holderId)
+		if err != nil || user == nil {
+			return errorResponse(notFound("user", holderId))
+		}
+		found = kind.removeHolding(user, assetId)
+		if found {
+			if err := putUser(stub, user); err != nil {
+				return errorResponse(fmt.Errorf("update user error: %s", err))
+			}
+		}
+	} else {
+		food, err := getFood(stub, holderId)
+		if err != nil || food == nil {
+			return errorResponse(notFound("food", holderId))
+		}
+		if kind == ingredientAsset {
+			food.Ingredients, found = removeId(food.Ingredients, assetId)
+		} else {
+			food.Foods, found = removeId(food.Foods, assetId)
+		}
+		if found {
+			if err := putFood(stub, food); err != nil {
+				return errorResponse(fmt.Errorf("update food error: %s", err))
+			}
+		}
+	}
+	if !found {
+		return errorResponse(newError(CodeFailedPrecondition, "inconsistency not found: %s does not reference %s", holder, assetId).withEntity(holderId))
+	}
+
+	record.Before = []string{kind.key(assetId)}
+	record.After = make([]string, 0)
+
+	return putRepairRecord(stub, record)
+}
+
+// 按流通记录计算用户应持有的资产
+// 只检查用户当前持有的和索引中收到过的资产, 升级前的流通记录需要先执行 migrate 建立索引
+func holdingsFromHistory(stub shim.ChaincodeStubInterface, kind *assetKind, user *User) ([]string, error) {
+	candidates := make(map[string]bool)
+	for _, id := range *kind.holdings(user) {
+		candidates[id] = true
+	}
+
+	iter, err := stub.GetStateByPartialCompositeKey(holderAssetType, []string{user.Id, kind.name})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attrs, err := stub.SplitCompositeKey(kv.GetKey())
+		if err != nil || len(attrs) != 3 {
+			return nil, fmt.Errorf("invalid holder index %q", kv.GetKey())
+		}
+		candidates[attrs[2]] = true
+	}
+
+	ids := make([]string, 0, len(candidates))
+	for id := range candidates {
+		if !kind.exists(stub, id) {
+			continue
+		}
+		lastOwner, ok, err := historyLastOwner(stub, kind, id)
+		if err != nil {
+			return nil, err
+		}
+		if ok && lastOwner == user.Id {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+func sortedCopy(ids []string) []string {
+	result := append(make([]string, 0, len(ids)), ids...)
+	sort.Strings(result)
+	return result
+}
+
+func sameIds(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 按流通记录重建用户名下的资产
+// 参数: userId, reason
+func (c *IngredientsExchangeCC) repairHoldings(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	userId := args[0]
+	reason := args[1]
+	if userId == "" || reason == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	record, err := newRepairRecord(stub, repairRebuildHoldings, constructUserKey(userId), reason)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	//验证数据是否存在
+	user, err := getUser(stub, userId)
+	if err != nil || user == nil {
+		return errorResponse(notFound("user", userId))
+	}
+
+	holdings := make(map[*assetKind][]string)
+	matched := true
+	for _, kind := range assetKinds {
+		ids, err := holdingsFromHistory(stub, kind, user)
+		if err != nil {
+			return errorResponse(fmt.Errorf("query history error: %s", err))
+		}
+		holdings[kind] = ids
+		if !sameIds(sortedCopy(*kind.holdings(user)), ids) {
+			matched = false
+		}
+	}
+
+	// 只修复确实存在的不一致
+	if matched {
+		return errorResponse(newError(CodeFailedPrecondition, "inconsistency not found: holdings match history").withEntity(userId))
+	}
+
+	record.Before = make([]string, 0)
+	record.After = make([]string, 0)
+	for _, kind := range assetKinds {
+		for _, id := range *kind.holdings(user) {
+			record.Before = append(record.Before, kind.key(id))
+		}
+		for _, id := range holdings[kind] {
+			record.After = append(record.After, kind.key(id))
+		}
+	}
+
+	//写入状态
+	for _, kind := range assetKinds {
+		*kind.holdings(user) = holdings[kind]
+	}
+	if err := putUser(stub, user); err != nil {
+		return errorResponse(fmt.Errorf("update user error: %s", err))
+	}
+
+	return putRepairRecord(stub, record)
+}
+
+// 查询修复记录, 可按目标键过滤
+func (c *IngredientsExchangeCC) queryRepairs(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 0, 1); err != nil {
+		return errorResponse(err)
+	}
+
+	target := ""
+	if len(args) == 1 {
+		target = args[0]
+	}
+
+	result, err := stub.GetStateByPartialCompositeKey(repairKey, []string{})
+	if err != nil {
+		return errorResponse(fmt.Errorf("query repair error: %s", err))
+	}
+	defer result.Close()
+
+	records := make([]*RepairRecord, 0)
+	for result.HasNext() {
+		recordVal, err := result.Next()
+		if err != nil {
+			return errorResponse(fmt.Errorf("query error: %s", err))
+		}
+
+		record := new(RepairRecord)
+		if err := json.Unmarshal(recordVal.GetValue(), record); err != nil {
+			return errorResponse(fmt.Errorf("unmarshal error: %s", err))
+		}
+		if target != "" && record.Target != target {
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Timestamp < records[j].Timestamp
+	})
+
+	recordsBytes, err := json.Marshal(records)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(recordsBytes)
+}
@@ -0,0 +1,604 @@
+package food
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// 链码调用请求, 每个链码函数对应一个请求结构
+// Args 返回链码期望的参数列表, 调用方不需要再按位置拼参数
+type Request interface {
+	Function() string
+	Args() []string
+}
+
+// 去掉末尾为空的可选参数, required 为必填参数的个数
+func trimArgs(args []string, required int) []string {
+	for len(args) > required && args[len(args)-1] == "" {
+		args = args[:len(args)-1]
+	}
+
+	return args
+}
+
+func joinIds(ids []string) string {
+	return strings.Join(ids, ",")
+}
+
+func formatInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(n)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// 批量和配置等整体以JSON传入的参数
+func marshalArg(v interface{}) string {
+	argBytes, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+
+	return string(argBytes)
+}
+
+// 用户注册
+type UserRegisterRequest struct {
+	Name string `json:"name"`
+	Id   string `json:"id"`
+}
+
+func (r *UserRegisterRequest) Function() string { return "userRegister" }
+func (r *UserRegisterRequest) Args() []string {
+	return []string{r.Name, r.Id}
+}
+
+// 删除用户
+type UserDestroyRequest struct {
+	Id string `json:"id"`
+}
+
+func (r *UserDestroyRequest) Function() string { return "userDestroy" }
+func (r *UserDestroyRequest) Args() []string {
+	return []string{r.Id}
+}
+
+// 用户查询
+type QueryUserRequest struct {
+	Id string `json:"id"`
+}
+
+func (r *QueryUserRequest) Function() string { return "queryUser" }
+func (r *QueryUserRequest) Args() []string {
+	return []string{r.Id}
+}
+
+// 食材登记
+type IngredientEnrollRequest struct {
+	Name           string   `json:"name"`
+	Id             string   `json:"id"`
+	Metadata       string   `json:"metadata,omitempty"`
+	OwnerId        string   `json:"owner_id"`
+	Allergens      []string `json:"allergens,omitempty"`
+	FacilityId     string   `json:"facility_id,omitempty"`
+	CertificateIds []string `json:"certificate_ids,omitempty"`
+}
+
+func (r *IngredientEnrollRequest) Function() string { return "ingredientEnroll" }
+func (r *IngredientEnrollRequest) Args() []string {
+	return trimArgs([]string{r.Name, r.Id, r.Metadata, r.OwnerId, joinIds(r.Allergens), r.FacilityId, joinIds(r.CertificateIds)}, 4)
+}
+
+// 食品登记
+type FoodEnrollRequest struct {
+	Name           string   `json:"name"`
+	Id             string   `json:"id"`
+	Metadata       string   `json:"metadata,omitempty"`
+	OwnerId        string   `json:"owner_id"`
+	AllergenFree   []string `json:"allergen_free,omitempty"`
+	Serial         string   `json:"serial,omitempty"`
+	FacilityId     string   `json:"facility_id,omitempty"`
+	CertificateIds []string `json:"certificate_ids,omitempty"`
+}
+
+func (r *FoodEnrollRequest) Function() string { return "foodEnroll" }
+func (r *FoodEnrollRequest) Args() []string {
+	return trimArgs([]string{r.Name, r.Id, r.Metadata, r.OwnerId, joinIds(r.AllergenFree), r.Serial, r.FacilityId, joinIds(r.CertificateIds)}, 4)
+}
+
+// 食材转让
+type IngredientExchangeRequest struct {
+	OwnerId        string `json:"owner_id"`
+	IngredientId   string `json:"ingredient_id"`
+	CurrentOwnerId string `json:"current_owner_id"` // 新拥有者, OwnerId 为原拥有者
+	FacilityId     string `json:"facility_id,omitempty"`
+}
+
+func (r *IngredientExchangeRequest) Function() string { return "ingredientExchange" }
+func (r *IngredientExchangeRequest) Args() []string {
+	return trimArgs([]string{r.OwnerId, r.IngredientId, r.CurrentOwnerId, r.FacilityId}, 3)
+}
+
+// 食品转让
+type FoodExchangeRequest struct {
+	OwnerId        string `json:"owner_id"`
+	FoodId         string `json:"food_id"`
+	CurrentOwnerId string `json:"current_owner_id"` // 新拥有者, OwnerId 为原拥有者
+	FacilityId     string `json:"facility_id,omitempty"`
+}
+
+func (r *FoodExchangeRequest) Function() string { return "foodExchange" }
+func (r *FoodExchangeRequest) Args() []string {
+	return trimArgs([]string{r.OwnerId, r.FoodId, r.CurrentOwnerId, r.FacilityId}, 3)
+}
+
+// 食材并入食品
+type IngredientExchangeFoodRequest struct {
+	OwnerId      string `json:"owner_id"`
+	IngredientId string `json:"ingredient_id"`
+	FoodId       string `json:"food_id"`
+	FacilityId   string `json:"facility_id,omitempty"`
+}
+
+func (r *IngredientExchangeFoodRequest) Function() string { return "ingredientExchangeFood" }
+func (r *IngredientExchangeFoodRequest) Args() []string {
+	return trimArgs([]string{r.OwnerId, r.IngredientId, r.FoodId, r.FacilityId}, 3)
+}
+
+// 食品并入其他食品
+type FoodExchangeFoodRequest struct {
+	OwnerId      string `json:"owner_id"`
+	FoodId       string `json:"food_id"`
+	TargetFoodId string `json:"target_food_id"`
+	FacilityId   string `json:"facility_id,omitempty"`
+}
+
+func (r *FoodExchangeFoodRequest) Function() string { return "foodExchangeFood" }
+func (r *FoodExchangeFoodRequest) Args() []string {
+	return trimArgs([]string{r.OwnerId, r.FoodId, r.TargetFoodId, r.FacilityId}, 3)
+}
+
+// 批量食材登记
+type IngredientEnrollBatchRequest struct {
+	Items []*IngredientEnrollItem `json:"items"`
+}
+
+func (r *IngredientEnrollBatchRequest) Function() string { return "ingredientEnrollBatch" }
+func (r *IngredientEnrollBatchRequest) Args() []string {
+	return []string{marshalArg(r.Items)}
+}
+
+// 批量食品登记
+type FoodEnrollBatchRequest struct {
+	Items []*FoodEnrollItem `json:"items"`
+}
+
+func (r *FoodEnrollBatchRequest) Function() string { return "foodEnrollBatch" }
+func (r *FoodEnrollBatchRequest) Args() []string {
+	return []string{marshalArg(r.Items)}
+}
+
+// 批量食材转让
+type IngredientExchangeBatchRequest struct {
+	Items []*IngredientExchangeItem `json:"items"`
+}
+
+func (r *IngredientExchangeBatchRequest) Function() string { return "ingredientExchangeBatch" }
+func (r *IngredientExchangeBatchRequest) Args() []string {
+	return []string{marshalArg(r.Items)}
+}
+
+// 食材查询
+type QueryIngredientRequest struct {
+	Id string `json:"id"`
+}
+
+func (r *QueryIngredientRequest) Function() string { return "queryIngredient" }
+func (r *QueryIngredientRequest) Args() []string {
+	return []string{r.Id}
+}
+
+// 食品查询
+type QueryFoodRequest struct {
+	Id string `json:"id"`
+}
+
+func (r *QueryFoodRequest) Function() string { return "queryFood" }
+func (r *QueryFoodRequest) Args() []string {
+	return []string{r.Id}
+}
+
+// 食材流通记录查询, QueryType 为 all|enroll|exchange, 默认 all
+type QueryIngredientHistoryRequest struct {
+	IngredientId string `json:"ingredient_id"`
+	QueryType    string `json:"query_type,omitempty"`
+}
+
+func (r *QueryIngredientHistoryRequest) Function() string { return "queryIngredientHistory" }
+func (r *QueryIngredientHistoryRequest) Args() []string {
+	return trimArgs([]string{r.IngredientId, r.QueryType}, 1)
+}
+
+// 食品流通记录查询, QueryType 为 all|enroll|exchange|process, 默认 all
+type QueryFoodHistoryRequest struct {
+	FoodId    string `json:"food_id"`
+	QueryType string `json:"query_type,omitempty"`
+}
+
+func (r *QueryFoodHistoryRequest) Function() string { return "queryFoodHistory" }
+func (r *QueryFoodHistoryRequest) Args() []string {
+	return trimArgs([]string{r.FoodId, r.QueryType}, 1)
+}
+
+// 食品溯源
+type QueryFoodProvenanceRequest struct {
+	FoodId string `json:"food_id"`
+}
+
+func (r *QueryFoodProvenanceRequest) Function() string { return "queryFoodProvenance" }
+func (r *QueryFoodProvenanceRequest) Args() []string {
+	return []string{r.FoodId}
+}
+
+// 过敏原标签
+type QueryFoodAllergensRequest struct {
+	FoodId string `json:"food_id"`
+}
+
+func (r *QueryFoodAllergensRequest) Function() string { return "queryFoodAllergens" }
+func (r *QueryFoodAllergensRequest) Args() []string {
+	return []string{r.FoodId}
+}
+
+// 产品验证
+type VerifyProductRequest struct {
+	Serial string `json:"serial"`
+}
+
+func (r *VerifyProductRequest) Function() string { return "verifyProduct" }
+func (r *VerifyProductRequest) Args() []string {
+	return []string{r.Serial}
+}
+
+// 食品召回, Status 为 recalled|none
+type FoodRecallRequest struct {
+	FoodId string `json:"food_id"`
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+func (r *FoodRecallRequest) Function() string { return "foodRecall" }
+func (r *FoodRecallRequest) Args() []string {
+	return []string{r.FoodId, r.Status, r.Reason}
+}
+
+// 食品加工
+type FoodProcessRequest struct {
+	Id         string            `json:"id"`
+	StepType   string            `json:"step_type"`
+	FacilityId string            `json:"facility_id,omitempty"`
+	OperatorId string            `json:"operator_id"`
+	InputIds   []string          `json:"input_ids,omitempty"`
+	OutputIds  []string          `json:"output_ids,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+func (r *FoodProcessRequest) Function() string { return "foodProcess" }
+func (r *FoodProcessRequest) Args() []string {
+	parameters := ""
+	if len(r.Parameters) != 0 {
+		parameters = marshalArg(r.Parameters)
+	}
+
+	return trimArgs([]string{r.Id, r.StepType, r.FacilityId, r.OperatorId, joinIds(r.InputIds), joinIds(r.OutputIds), parameters}, 6)
+}
+
+// 加工步骤查询
+type QueryProcessStepRequest struct {
+	Id string `json:"id"`
+}
+
+func (r *QueryProcessStepRequest) Function() string { return "queryProcessStep" }
+func (r *QueryProcessStepRequest) Args() []string {
+	return []string{r.Id}
+}
+
+// 容器登记
+type ContainerEnrollRequest struct {
+	Name       string `json:"name"`
+	Id         string `json:"id"`
+	Metadata   string `json:"metadata,omitempty"`
+	OwnerId    string `json:"owner_id"`
+	FacilityId string `json:"facility_id,omitempty"`
+}
+
+func (r *ContainerEnrollRequest) Function() string { return "containerEnroll" }
+func (r *ContainerEnrollRequest) Args() []string {
+	return trimArgs([]string{r.Name, r.Id, r.Metadata, r.OwnerId, r.FacilityId}, 4)
+}
+
+// 装箱, Kind 为 ingredient|food|container
+type ContainerPackRequest struct {
+	OwnerId     string   `json:"owner_id"`
+	ContainerId string   `json:"container_id"`
+	Kind        string   `json:"kind"`
+	Ids         []string `json:"ids"`
+}
+
+func (r *ContainerPackRequest) Function() string { return "containerPack" }
+func (r *ContainerPackRequest) Args() []string {
+	return []string{r.OwnerId, r.ContainerId, r.Kind, joinIds(r.Ids)}
+}
+
+// 拆箱, Kind 为 ingredient|food|container
+type ContainerUnpackRequest struct {
+	OwnerId     string   `json:"owner_id"`
+	ContainerId string   `json:"container_id"`
+	Kind        string   `json:"kind"`
+	Ids         []string `json:"ids"`
+}
+
+func (r *ContainerUnpackRequest) Function() string { return "containerUnpack" }
+func (r *ContainerUnpackRequest) Args() []string {
+	return []string{r.OwnerId, r.ContainerId, r.Kind, joinIds(r.Ids)}
+}
+
+// 容器转让
+type ContainerExchangeRequest struct {
+	OwnerId        string `json:"owner_id"`
+	ContainerId    string `json:"container_id"`
+	CurrentOwnerId string `json:"current_owner_id"` // 新拥有者, OwnerId 为原拥有者
+	FacilityId     string `json:"facility_id,omitempty"`
+}
+
+func (r *ContainerExchangeRequest) Function() string { return "containerExchange" }
+func (r *ContainerExchangeRequest) Args() []string {
+	return trimArgs([]string{r.OwnerId, r.ContainerId, r.CurrentOwnerId, r.FacilityId}, 3)
+}
+
+// 容器查询
+type QueryContainerRequest struct {
+	Id string `json:"id"`
+}
+
+func (r *QueryContainerRequest) Function() string { return "queryContainer" }
+func (r *QueryContainerRequest) Args() []string {
+	return []string{r.Id}
+}
+
+// 容器流通记录查询, QueryType 为 all|enroll|exchange, 默认 all
+type QueryContainerHistoryRequest struct {
+	ContainerId string `json:"container_id"`
+	QueryType   string `json:"query_type,omitempty"`
+}
+
+func (r *QueryContainerHistoryRequest) Function() string { return "queryContainerHistory" }
+func (r *QueryContainerHistoryRequest) Args() []string {
+	return trimArgs([]string{r.ContainerId, r.QueryType}, 1)
+}
+
+// 设施登记, Type 为 farm|factory|warehouse|store
+type FacilityRegisterRequest struct {
+	Id        string  `json:"id"`
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	OwnerId   string  `json:"owner_id"`
+	Address   string  `json:"address,omitempty"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	License   string  `json:"license"`
+}
+
+func (r *FacilityRegisterRequest) Function() string { return "facilityRegister" }
+func (r *FacilityRegisterRequest) Args() []string {
+	return []string{r.Id, r.Name, r.Type, r.OwnerId, r.Address, formatFloat(r.Latitude), formatFloat(r.Longitude), r.License}
+}
+
+// 设施查询
+type QueryFacilityRequest struct {
+	Id string `json:"id"`
+}
+
+func (r *QueryFacilityRequest) Function() string { return "queryFacility" }
+func (r *QueryFacilityRequest) Args() []string {
+	return []string{r.Id}
+}
+
+// 经过设施的资产查询, 可按资产类型过滤
+type QueryFacilityItemsRequest struct {
+	FacilityId string `json:"facility_id"`
+	Kind       string `json:"kind,omitempty"`
+}
+
+func (r *QueryFacilityItemsRequest) Function() string { return "queryFacilityItems" }
+func (r *QueryFacilityItemsRequest) Args() []string {
+	return trimArgs([]string{r.FacilityId, r.Kind}, 1)
+}
+
+// 签发证书
+type CertificateIssueRequest struct {
+	Id         string   `json:"id"`
+	Scheme     string   `json:"scheme"`
+	HolderType string   `json:"holder_type"`
+	HolderId   string   `json:"holder_id"`
+	Scope      []string `json:"scope"`
+	ValidFrom  string   `json:"valid_from"`
+	ValidTo    string   `json:"valid_to"`
+}
+
+func (r *CertificateIssueRequest) Function() string { return "certificateIssue" }
+func (r *CertificateIssueRequest) Args() []string {
+	return []string{r.Id, r.Scheme, r.HolderType, r.HolderId, joinIds(r.Scope), r.ValidFrom, r.ValidTo}
+}
+
+// 撤销证书
+type CertificateRevokeRequest struct {
+	Id     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+func (r *CertificateRevokeRequest) Function() string { return "certificateRevoke" }
+func (r *CertificateRevokeRequest) Args() []string {
+	return []string{r.Id, r.Reason}
+}
+
+// 证书查询
+type QueryCertificateRequest struct {
+	Id string `json:"id"`
+}
+
+func (r *QueryCertificateRequest) Function() string { return "queryCertificate" }
+func (r *QueryCertificateRequest) Args() []string {
+	return []string{r.Id}
+}
+
+// 持有者的证书查询
+type QueryCertificatesRequest struct {
+	HolderType string `json:"holder_type"`
+	HolderId   string `json:"holder_id"`
+}
+
+func (r *QueryCertificatesRequest) Function() string { return "queryCertificates" }
+func (r *QueryCertificatesRequest) Args() []string {
+	return []string{r.HolderType, r.HolderId}
+}
+
+// 审批转让申请, Decision 为 approve|reject
+type TransferApproveRequest struct {
+	TransferId string `json:"transfer_id"`
+	Decision   string `json:"decision"`
+	Comment    string `json:"comment,omitempty"`
+}
+
+func (r *TransferApproveRequest) Function() string { return "transferApprove" }
+func (r *TransferApproveRequest) Args() []string {
+	return trimArgs([]string{r.TransferId, r.Decision, r.Comment}, 2)
+}
+
+// 转让申请查询
+type QueryTransferRequest struct {
+	Id string `json:"id"`
+}
+
+func (r *QueryTransferRequest) Function() string { return "queryTransfer" }
+func (r *QueryTransferRequest) Args() []string {
+	return []string{r.Id}
+}
+
+// 资产的转让申请查询
+type QueryTransfersRequest struct {
+	Kind    string `json:"kind"`
+	AssetId string `json:"asset_id"`
+}
+
+func (r *QueryTransfersRequest) Function() string { return "queryTransfers" }
+func (r *QueryTransfersRequest) Args() []string {
+	return []string{r.Kind, r.AssetId}
+}
+
+// 资产键的背书查询
+type QueryEndorsementRequest struct {
+	Kind    string `json:"kind"`
+	AssetId string `json:"asset_id"`
+}
+
+func (r *QueryEndorsementRequest) Function() string { return "queryEndorsement" }
+func (r *QueryEndorsementRequest) Args() []string {
+	return []string{r.Kind, r.AssetId}
+}
+
+// 更新配置, Config.Version 必须是当前版本
+type UpdateConfigRequest struct {
+	Config *Config `json:"config"`
+}
+
+func (r *UpdateConfigRequest) Function() string { return "updateConfig" }
+func (r *UpdateConfigRequest) Args() []string {
+	return []string{marshalArg(r.Config)}
+}
+
+// 配置查询, Version 为 0 时查询当前配置
+type QueryConfigRequest struct {
+	Version int `json:"version,omitempty"`
+}
+
+func (r *QueryConfigRequest) Function() string { return "queryConfig" }
+func (r *QueryConfigRequest) Args() []string {
+	return trimArgs([]string{formatInt(r.Version)}, 0)
+}
+
+// 分页迁移
+type MigrateRequest struct {
+	PageSize int    `json:"page_size,omitempty"`
+	Bookmark string `json:"bookmark,omitempty"`
+}
+
+func (r *MigrateRequest) Function() string { return "migrate" }
+func (r *MigrateRequest) Args() []string {
+	return trimArgs([]string{formatInt(r.PageSize), r.Bookmark}, 0)
+}
+
+// 账本一致性检查
+type CheckConsistencyRequest struct {
+	PageSize int    `json:"page_size,omitempty"`
+	Bookmark string `json:"bookmark,omitempty"`
+}
+
+func (r *CheckConsistencyRequest) Function() string { return "checkConsistency" }
+func (r *CheckConsistencyRequest) Args() []string {
+	return trimArgs([]string{formatInt(r.PageSize), r.Bookmark}, 0)
+}
+
+// 无主资产分配给用户, Kind 为 ingredient|food
+type RepairOrphanRequest struct {
+	Kind    string `json:"kind"`
+	AssetId string `json:"asset_id"`
+	UserId  string `json:"user_id"`
+	Reason  string `json:"reason"`
+}
+
+func (r *RepairOrphanRequest) Function() string { return "repairOrphan" }
+func (r *RepairOrphanRequest) Args() []string {
+	return []string{r.Kind, r.AssetId, r.UserId, r.Reason}
+}
+
+// 删除失效的资产引用, HolderType 为 user|food
+type RepairReferenceRequest struct {
+	HolderType string `json:"holder_type"`
+	HolderId   string `json:"holder_id"`
+	Kind       string `json:"kind"`
+	AssetId    string `json:"asset_id"`
+	Reason     string `json:"reason"`
+}
+
+func (r *RepairReferenceRequest) Function() string { return "repairReference" }
+func (r *RepairReferenceRequest) Args() []string {
+	return []string{r.HolderType, r.HolderId, r.Kind, r.AssetId, r.Reason}
+}
+
+// 按流通记录重建用户的资产列表
+type RepairHoldingsRequest struct {
+	UserId string `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+func (r *RepairHoldingsRequest) Function() string { return "repairHoldings" }
+func (r *RepairHoldingsRequest) Args() []string {
+	return []string{r.UserId, r.Reason}
+}
+
+// 修复记录查询, 可按目标键过滤
+type QueryRepairsRequest struct {
+	Target string `json:"target,omitempty"`
+}
+
+func (r *QueryRepairsRequest) Function() string { return "queryRepairs" }
+func (r *QueryRepairsRequest) Args() []string {
+	return trimArgs([]string{r.Target}, 0)
+}
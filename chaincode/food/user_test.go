@@ -0,0 +1,47 @@
+package food_test
+
+import (
+	"testing"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+)
+
+// 按默认的 cascade 策略删除用户: 食材和容器一起删除, 保留的食品被拆箱
+func TestUserDestroyCascade(t *testing.T) {
+	sim := newAssetLedger(t)
+	for _, req := range []food.Request{
+		&food.IngredientEnrollRequest{Name: "rice", Id: "i1", OwnerId: "u1"},
+		&food.FoodEnrollRequest{Name: "bread", Id: "f1", OwnerId: "u1"},
+		&food.ContainerEnrollRequest{Name: "pallet", Id: "c1", OwnerId: "u1"},
+		&food.ContainerEnrollRequest{Name: "box", Id: "c2", OwnerId: "u1"},
+		&food.ContainerPackRequest{OwnerId: "u1", ContainerId: "c2", Kind: "ingredient", Ids: []string{"i1"}},
+		&food.ContainerPackRequest{OwnerId: "u1", ContainerId: "c2", Kind: "food", Ids: []string{"f1"}},
+		&food.ContainerPackRequest{OwnerId: "u1", ContainerId: "c1", Kind: "container", Ids: []string{"c2"}},
+		&food.UserDestroyRequest{Id: "u1"},
+	} {
+		mustExecute(t, sim, req)
+	}
+
+	for _, req := range []food.Request{
+		&food.QueryUserRequest{Id: "u1"},
+		&food.QueryIngredientRequest{Id: "i1"},
+		&food.QueryContainerRequest{Id: "c1"},
+		&food.QueryContainerRequest{Id: "c2"},
+	} {
+		if _, code, err := execute(sim, req); err != nil || code != food.CodeNotFound {
+			t.Errorf("%s: expected %s, got %q %v", req.Function(), food.CodeNotFound, code, err)
+		}
+	}
+	mustExecute(t, sim, &food.QueryFoodRequest{Id: "f1"})
+
+	// 不留下指向已删除容器的装箱索引
+	for _, item := range [][]string{{"ingredient", "i1"}, {"food", "f1"}, {"container", "c2"}} {
+		key, err := sim.Stub.CreateCompositeKey("packed", item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value, _ := sim.Stub.GetState(key); len(value) != 0 {
+			t.Errorf("expected %s %s unpacked, still in %s", item[0], item[1], value)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package food
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	recallStatusNone     = "none"
+	recallStatusRecalled = "recalled"
+	recallEvent          = "recall"
+
+	roleRegulator = "regulator"
+)
+
+// 面向消费者的产品信息, 不包含企业内部数据
+// 字段按字母顺序排列, 序列化结果即为规范JSON
+type ProductSummary struct {
+	Certifications  []string `json:"certifications"`
+	CustodyHandoffs int      `json:"custody_handoffs"`
+	Name            string   `json:"name"`
+	OriginRegion    string   `json:"origin_region"`
+	ProductionDate  string   `json:"production_date"`
+	RecallStatus    string   `json:"recall_status"`
+	Serial          string   `json:"serial"`
+}
+
+// 产品验证结果
+type ProductVerification struct {
+	Summary   *ProductSummary `json:"summary"`
+	Canonical string          `json:"canonical"`
+	Digest    string          `json:"sha256"`
+}
+
+// 食品召回记录, 每个食品只保留最新一条, 之前的记录在键的历史中
+type FoodRecall struct {
+	FoodId      string `json:"food_id"`
+	Status      string `json:"status"`
+	Reason      string `json:"reason"`
+	OperatorMSP string `json:"operator_msp"`
+	TxId        string `json:"tx_id"`
+	Timestamp   string `json:"timestamp"`
+}
+
+func constructSerialKey(serial string) string {
+	return fmt.Sprintf("serial_%s", serial)
+}
+
+func constructRecallKey(foodId string) string {
+	return fmt.Sprintf("recall_%s", foodId)
+}
+
+// 食品当前的召回状态, 没有召回记录时为 none
+func foodRecallStatus(stub shim.ChaincodeStubInterface, foodId string) (string, error) {
+	recallBytes, err := stub.GetState(constructRecallKey(foodId))
+	if err != nil {
+		return "", err
+	}
+	if len(recallBytes) == 0 {
+		return recallStatusNone, nil
+	}
+
+	recall := new(FoodRecall)
+	if err := json.Unmarshal(recallBytes, recall); err != nil {
+		return "", err
+	}
+
+	return recall.Status, nil
+}
+
+// 食品metadata为JSON对象时, 读取其中的公开字段
+func parseFoodMetadata(metadata string) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(metadata), &fields); err != nil {
+		return make(map[string]interface{})
+	}
+
+	return fields
+}
+
+func metadataString(fields map[string]interface{}, key string) string {
+	if value, ok := fields[key].(string); ok {
+		return value
+	}
+
+	return ""
+}
+
+// 流通记录的新持有者是否为用户
+// foodExchangeFood 的记录中新持有者是目标食品, 是并入而不是转手
+// 食品不会被删除, 找不到的持有者按已删除的用户处理
+func isUserHolder(stub shim.ChaincodeStubInterface, holderId string) (bool, error) {
+	userBytes, err := stub.GetState(constructUserKey(holderId))
+	if err != nil {
+		return false, err
+	}
+	if len(userBytes) != 0 {
+		return true, nil
+	}
+
+	foodBytes, err := stub.GetState(constructFoodKey(holderId))
+	if err != nil {
+		return false, err
+	}
+
+	return len(foodBytes) == 0, nil
+}
+
+// 统计食品在用户之间的转让次数
+func countFoodHandoffs(stub shim.ChaincodeStubInterface, foodId string) (int, error) {
+	values, err := foodAsset.historyValues(stub, foodId)
+	if err != nil {
+		return 0, err
+	}
+
+	handoffs := 0
+	for _, value := range values {
+		history := new(FoodHistory)
+		if err := unmarshalDoc(value, history); err != nil {
+			return 0, err
+		}
+		if history.OriginOwnerId == originOwner {
+			continue
+		}
+		toUser, err := isUserHolder(stub, history.CurrentOwnerId)
+		if err != nil {
+			return 0, err
+		}
+		if !toUser {
+			continue
+		}
+		handoffs++
+	}
+
+	return handoffs, nil
+}
+
+// 当前仍然有效的认证, metadata 中的认证声明无法验证, 不再展示
+func activeCertifications(stub shim.ChaincodeStubInterface, claims []*CertificationClaim) ([]string, error) {
+	schemes := make([]string, 0)
+	seen := make(map[string]bool)
+	timestamp, err := txTimestamp(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, claim := range claims {
+		if claim.Status != claimCertified {
+			continue
+		}
+		cert, err := getCertificate(stub, claim.CertificateId)
+		if err != nil {
+			return nil, err
+		}
+		if cert == nil || !cert.validAt(timestamp) || seen[claim.Scheme] {
+			continue
+		}
+		seen[claim.Scheme] = true
+		schemes = append(schemes, claim.Scheme)
+	}
+	sort.Strings(schemes)
+
+	return schemes, nil
+}
+
+// 产品验证
+func (c *IngredientsExchangeCC) verifyProduct(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return errorResponse(err)
+	}
+
+	//验证参数的正确性
+	serial := args[0]
+	if serial == "" {
+		return errorResponse(invalidArgs())
+	}
+
+	//验证数据是否存在
+	foodIdBytes, err := stub.GetState(constructSerialKey(serial))
+	if err != nil || len(foodIdBytes) == 0 {
+		return errorResponse(notFound("serial", serial))
+	}
+
+	food, err := getFood(stub, string(foodIdBytes))
+	if err != nil || food == nil {
+		return errorResponse(notFound("food", string(foodIdBytes)))
+	}
+
+	handoffs, err := countFoodHandoffs(stub, food.Id)
+	if err != nil {
+		return errorResponse(fmt.Errorf("query history error: %s", err))
+	}
+
+	certifications, err := activeCertifications(stub, food.Certifications)
+	if err != nil {
+		return errorResponse(fmt.Errorf("query certificate error: %s", err))
+	}
+
+	fields := parseFoodMetadata(food.Metadata)
+	summary := &ProductSummary{
+		Certifications:  certifications,
+		CustodyHandoffs: handoffs,
+		Name:            food.Name,
+		OriginRegion:    metadataString(fields, "origin_region"),
+		ProductionDate:  metadataString(fields, "production_date"),
+		Serial:          serial,
+	}
+	if summary.ProductionDate == "" {
+		summary.ProductionDate = food.EnrolledAt
+	}
+	summary.RecallStatus, err = foodRecallStatus(stub, food.Id)
+	if err != nil {
+		return errorResponse(fmt.Errorf("query recall error: %s", err))
+	}
+
+	// 规范JSON便于客户端签名和验签
+	canonical, err := json.Marshal(summary)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+	digest := sha256.Sum256(canonical)
+
+	verification := &ProductVerification{
+		Summary:   summary,
+		Canonical: string(canonical),
+		Digest:    hex.EncodeToString(digest[:]),
+	}
+	verificationBytes, err := json.Marshal(verification)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal error: %s", err))
+	}
+
+	return shim.Success(verificationBytes)
+}
+
+// 食品召回, 仅限监管机构, status 为 recalled 时召回, 为 none 时解除召回
+// 参数: foodId, status, reason
+func (c *IngredientsExchangeCC) foodRecall(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	//检查参数的个数
+	if err := checkArgCount(args, 3, 3); err != nil {
+		return errorResponse(err)
+	}
+
+	cfg, err := getConfig(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get config error: %s", err))
+	}
+	msp, err := clientMSPID(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get client identity error: %s", err))
+	}
+	if !cfg.hasRole(roleRegulator, msp) {
+		return errorResponse(newError(CodeForbidden, "forbidden: %s is not a regulator", msp))
+	}
+
+	//验证参数的正确性
+	foodId := args[0]
+	status := args[1]
+	reason := args[2]
+	if foodId == "" || reason == "" {
+		return errorResponse(invalidArgs())
+	}
+	if status != recallStatusRecalled && status != recallStatusNone {
+		return errorResponse(newError(CodeInvalidArgument, "invalid recall status: %s", status).withField("status"))
+	}
+
+	food, err := getFood(stub, foodId)
+	if err != nil || food == nil {
+		return errorResponse(notFound("food", foodId))
+	}
+
+	timestamp, err := txTimestamp(stub)
+	if err != nil {
+		return errorResponse(fmt.Errorf("get timestamp error: %s", err))
+	}
+	recall := &FoodRecall{
+		FoodId:      foodId,
+		Status:      status,
+		Reason:      reason,
+		OperatorMSP: msp,
+		TxId:        stub.GetTxID(),
+		Timestamp:   timestamp,
+	}
+	recallBytes, err := json.Marshal(recall)
+	if err != nil {
+		return errorResponse(fmt.Errorf("marshal recall error: %s", err))
+	}
+	if err := stub.PutState(constructRecallKey(foodId), recallBytes); err != nil {
+		return errorResponse(fmt.Errorf("save recall error: %s", err))
+	}
+	if err := stub.SetEvent(recallEvent, recallBytes); err != nil {
+		return errorResponse(fmt.Errorf("set event error: %s", err))
+	}
+
+	return shim.Success(recallBytes)
+}
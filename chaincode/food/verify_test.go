@@ -0,0 +1,87 @@
+package food_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+	"github.com/Blockchain-book/Fabric-Food/simulator"
+)
+
+func recallStatus(t *testing.T, sim *simulator.Simulator, serial string) string {
+	req := &food.VerifyProductRequest{Serial: serial}
+	payload, err := sim.Query("admin", req.Function(), req.Args())
+	if err != nil {
+		t.Fatalf("verifyProduct: %v", err)
+	}
+	verification := new(food.ProductVerification)
+	if err := json.Unmarshal(payload, verification); err != nil {
+		t.Fatal(err)
+	}
+
+	return verification.Summary.RecallStatus
+}
+
+// 召回状态取自监管机构的召回记录, 登记时 metadata 中的 recall_status 不生效
+func TestFoodRecall(t *testing.T) {
+	sim, err := simulator.New(`{"admin_msps":["Org1MSP"],"roles":{"regulator":["RegMSP"]}}`, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, msp := range map[string]string{"admin": "Org1MSP", "regulator": "RegMSP"} {
+		if err := sim.AddIdentity(name, &gateway.Identity{MSPID: msp}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, req := range []food.Request{
+		&food.UserRegisterRequest{Name: "alice", Id: "u1"},
+		&food.FoodEnrollRequest{Name: "bread", Id: "f1", Metadata: `{"recall_status":"recalled"}`, OwnerId: "u1", Serial: "SN1"},
+	} {
+		mustExecute(t, sim, req)
+	}
+	if status := recallStatus(t, sim, "SN1"); status != "none" {
+		t.Fatalf("expected recall status none before any recall, got %s", status)
+	}
+
+	tests := []struct {
+		name     string
+		identity string
+		req      *food.FoodRecallRequest
+		code     string
+		status   string
+	}{
+		{"not a regulator", "admin", &food.FoodRecallRequest{FoodId: "f1", Status: "recalled", Reason: "listeria"}, "FORBIDDEN", "none"},
+		{"invalid status", "regulator", &food.FoodRecallRequest{FoodId: "f1", Status: "maybe", Reason: "listeria"}, "INVALID_ARGUMENT", "none"},
+		{"missing reason", "regulator", &food.FoodRecallRequest{FoodId: "f1", Status: "recalled"}, "INVALID_ARGUMENT", "none"},
+		{"unknown food", "regulator", &food.FoodRecallRequest{FoodId: "f2", Status: "recalled", Reason: "listeria"}, "NOT_FOUND", "none"},
+		{"recall", "regulator", &food.FoodRecallRequest{FoodId: "f1", Status: "recalled", Reason: "listeria"}, "", "recalled"},
+		{"lift", "regulator", &food.FoodRecallRequest{FoodId: "f1", Status: "none", Reason: "retested"}, "", "none"},
+	}
+	for _, tt := range tests {
+		payload, err := sim.Invoke(tt.identity, tt.req.Function(), tt.req.Args())
+		code := ""
+		if ccErr, ok := err.(*gateway.ChaincodeError); ok && ccErr.Detail != nil {
+			code = ccErr.Detail.Code
+		} else if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if code != tt.code {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.code, code)
+			continue
+		}
+		if code == "" {
+			recall := new(food.FoodRecall)
+			if err := json.Unmarshal(payload, recall); err != nil {
+				t.Fatal(err)
+			}
+			if recall.Status != tt.req.Status || recall.OperatorMSP != "RegMSP" || recall.Timestamp == "" {
+				t.Errorf("%s: unexpected recall record %+v", tt.name, recall)
+			}
+		}
+		if status := recallStatus(t, sim, "SN1"); status != tt.status {
+			t.Errorf("%s: expected recall status %s, got %s", tt.name, tt.status, status)
+		}
+	}
+}
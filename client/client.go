@@ -0,0 +1,130 @@
+// Package client 是食品 REST 网关的 Go 客户端, 每个方法对应一个链码函数,
+// 参数按链码的参数顺序排列并带有类型, 返回值直接解码为链码的数据结构。
+//
+//	c := client.New("https://localhost:8080", "org1admin")
+//	c.Token = os.Getenv("FOOD_TOKEN")
+//	err := c.IngredientEnroll("rice", "ingredient1", "", "user1", nil, "", nil)
+//	history, err := c.QueryIngredientHistory("ingredient1", "exchange")
+//
+// client_gen.go 由 cmd/foodapigen 根据 gateway 的路由表生成, 修改路由后重新生成。
+package client
+
+//go:generate go run ../cmd/foodapigen -spec ../gateway/openapi.yaml -client client_gen.go
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+)
+
+type Client struct {
+	BaseURL  string
+	Identity string
+	// 网关的 bearer token, 使用 mTLS 客户端证书时在 HTTPClient 中配置证书
+	Token      string
+	HTTPClient *http.Client
+}
+
+// identity 为网关配置中的身份名, 只有 -mock 的网关可以只凭身份名调用
+func New(baseURL, identity string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Identity:   identity,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// 以其他身份调用, token 为该身份的凭证, -mock 的网关可以为空
+func (c *Client) As(identity, token string) *Client {
+	other := *c
+	other.Identity = identity
+	other.Token = token
+
+	return &other
+}
+
+// 网关返回的错误, Code 为链码错误码, 见 food.Code* 常量
+type Error struct {
+	StatusCode int
+	Message    string
+	Code       string
+	Field      string
+	EntityId   string
+	Fields     []*food.FieldError
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (c *Client) do(method, path string, query url.Values, body interface{}, result interface{}) error {
+	u := c.BaseURL + path
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request error: %s", err)
+		}
+		reader = bytes.NewReader(bodyBytes)
+	}
+
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(gateway.IdentityHeader, c.Identity)
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response error: %s", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		errResp := new(gateway.ErrorResponse)
+		if err := json.Unmarshal(respBytes, errResp); err != nil || errResp.Error == "" {
+			errResp.Error = strings.TrimSpace(string(respBytes))
+		}
+		return &Error{
+			StatusCode: resp.StatusCode,
+			Message:    errResp.Error,
+			Code:       errResp.Code,
+			Field:      errResp.Field,
+			EntityId:   errResp.EntityId,
+			Fields:     errResp.Fields,
+		}
+	}
+
+	// 链码没有返回值时响应体为空, result 保持零值
+	if result == nil || len(bytes.TrimSpace(respBytes)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBytes, result); err != nil {
+		return fmt.Errorf("unmarshal response error: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,495 @@
+// Code generated by foodapigen. DO NOT EDIT.
+
+package client
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+)
+
+// UserRegister 注册用户
+func (c *Client) UserRegister(name string, id string) error {
+	body := map[string]interface{}{}
+	body["name"] = name
+	body["id"] = id
+	return c.do("POST", "/users", nil, body, nil)
+}
+
+// QueryUser 查询用户
+func (c *Client) QueryUser(id string) (*food.User, error) {
+	var result *food.User
+	err := c.do("GET", "/users/"+url.PathEscape(id), nil, nil, &result)
+	return result, err
+}
+
+// UserDestroy 删除用户, 名下资产按配置的删除策略处理
+func (c *Client) UserDestroy(id string) error {
+	return c.do("DELETE", "/users/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// IngredientEnroll 登记食材
+func (c *Client) IngredientEnroll(name string, id string, metadata string, ownerId string, allergens []string, facilityId string, certificateIds []string) error {
+	body := map[string]interface{}{}
+	body["name"] = name
+	body["id"] = id
+	if metadata != "" {
+		body["metadata"] = metadata
+	}
+	body["owner_id"] = ownerId
+	if len(allergens) != 0 {
+		body["allergens"] = allergens
+	}
+	if facilityId != "" {
+		body["facility_id"] = facilityId
+	}
+	if len(certificateIds) != 0 {
+		body["certificate_ids"] = certificateIds
+	}
+	return c.do("POST", "/ingredients", nil, body, nil)
+}
+
+// QueryIngredient 查询食材
+func (c *Client) QueryIngredient(id string) (*food.Ingredient, error) {
+	var result *food.Ingredient
+	err := c.do("GET", "/ingredients/"+url.PathEscape(id), nil, nil, &result)
+	return result, err
+}
+
+// QueryIngredientHistory 查询食材流通记录, query_type 为 all|enroll|exchange
+func (c *Client) QueryIngredientHistory(ingredientId string, queryType string) ([]*food.IngredientHistory, error) {
+	query := url.Values{}
+	if queryType != "" {
+		query.Set("query_type", queryType)
+	}
+	var result []*food.IngredientHistory
+	err := c.do("GET", "/ingredients/"+url.PathEscape(ingredientId)+"/history", query, nil, &result)
+	return result, err
+}
+
+// IngredientExchange 转让食材
+func (c *Client) IngredientExchange(ownerId string, ingredientId string, currentOwnerId string, facilityId string) error {
+	body := map[string]interface{}{}
+	body["owner_id"] = ownerId
+	body["current_owner_id"] = currentOwnerId
+	if facilityId != "" {
+		body["facility_id"] = facilityId
+	}
+	return c.do("POST", "/ingredients/"+url.PathEscape(ingredientId)+"/exchange", nil, body, nil)
+}
+
+// IngredientExchangeFood 食材并入食品
+func (c *Client) IngredientExchangeFood(ownerId string, ingredientId string, foodId string, facilityId string) error {
+	body := map[string]interface{}{}
+	body["owner_id"] = ownerId
+	body["food_id"] = foodId
+	if facilityId != "" {
+		body["facility_id"] = facilityId
+	}
+	return c.do("POST", "/ingredients/"+url.PathEscape(ingredientId)+"/compose", nil, body, nil)
+}
+
+// FoodEnroll 登记食品
+func (c *Client) FoodEnroll(name string, id string, metadata string, ownerId string, allergenFree []string, serial string, facilityId string, certificateIds []string) error {
+	body := map[string]interface{}{}
+	body["name"] = name
+	body["id"] = id
+	if metadata != "" {
+		body["metadata"] = metadata
+	}
+	body["owner_id"] = ownerId
+	if len(allergenFree) != 0 {
+		body["allergen_free"] = allergenFree
+	}
+	if serial != "" {
+		body["serial"] = serial
+	}
+	if facilityId != "" {
+		body["facility_id"] = facilityId
+	}
+	if len(certificateIds) != 0 {
+		body["certificate_ids"] = certificateIds
+	}
+	return c.do("POST", "/foods", nil, body, nil)
+}
+
+// QueryFood 查询食品
+func (c *Client) QueryFood(id string) (*food.Food, error) {
+	var result *food.Food
+	err := c.do("GET", "/foods/"+url.PathEscape(id), nil, nil, &result)
+	return result, err
+}
+
+// QueryFoodHistory 查询食品流通记录, query_type 为 all|enroll|exchange|process
+func (c *Client) QueryFoodHistory(foodId string, queryType string) ([]*food.FoodHistory, error) {
+	query := url.Values{}
+	if queryType != "" {
+		query.Set("query_type", queryType)
+	}
+	var result []*food.FoodHistory
+	err := c.do("GET", "/foods/"+url.PathEscape(foodId)+"/history", query, nil, &result)
+	return result, err
+}
+
+// QueryFoodProvenance 查询食品的完整溯源树
+func (c *Client) QueryFoodProvenance(foodId string) (*food.FoodProvenance, error) {
+	var result *food.FoodProvenance
+	err := c.do("GET", "/foods/"+url.PathEscape(foodId)+"/provenance", nil, nil, &result)
+	return result, err
+}
+
+// QueryFoodAllergens 查询食品的过敏原标签
+func (c *Client) QueryFoodAllergens(foodId string) (*food.AllergenLabel, error) {
+	var result *food.AllergenLabel
+	err := c.do("GET", "/foods/"+url.PathEscape(foodId)+"/allergens", nil, nil, &result)
+	return result, err
+}
+
+// FoodExchange 转让食品, 需要多方审批时返回待审批的转让申请
+func (c *Client) FoodExchange(ownerId string, foodId string, currentOwnerId string, facilityId string) (*food.Transfer, error) {
+	body := map[string]interface{}{}
+	body["owner_id"] = ownerId
+	body["current_owner_id"] = currentOwnerId
+	if facilityId != "" {
+		body["facility_id"] = facilityId
+	}
+	var result *food.Transfer
+	err := c.do("POST", "/foods/"+url.PathEscape(foodId)+"/exchange", nil, body, &result)
+	return result, err
+}
+
+// FoodExchangeFood 食品并入其他食品
+func (c *Client) FoodExchangeFood(ownerId string, foodId string, targetFoodId string, facilityId string) error {
+	body := map[string]interface{}{}
+	body["owner_id"] = ownerId
+	body["target_food_id"] = targetFoodId
+	if facilityId != "" {
+		body["facility_id"] = facilityId
+	}
+	return c.do("POST", "/foods/"+url.PathEscape(foodId)+"/compose", nil, body, nil)
+}
+
+// FoodRecall 召回食品或解除召回, 仅限监管机构
+func (c *Client) FoodRecall(foodId string, status string, reason string) (*food.FoodRecall, error) {
+	body := map[string]interface{}{}
+	body["status"] = status
+	body["reason"] = reason
+	var result *food.FoodRecall
+	err := c.do("POST", "/foods/"+url.PathEscape(foodId)+"/recall", nil, body, &result)
+	return result, err
+}
+
+// VerifyProduct 按序列号验证产品
+func (c *Client) VerifyProduct(serial string) (*food.ProductVerification, error) {
+	var result *food.ProductVerification
+	err := c.do("GET", "/products/"+url.PathEscape(serial)+"/verify", nil, nil, &result)
+	return result, err
+}
+
+// IngredientEnrollBatch 批量登记食材, 任一条失败时全部拒绝
+func (c *Client) IngredientEnrollBatch(items []*food.IngredientEnrollItem) ([]*food.BatchResult, error) {
+	var result []*food.BatchResult
+	err := c.do("POST", "/batches/ingredients", nil, items, &result)
+	return result, err
+}
+
+// FoodEnrollBatch 批量登记食品, 任一条失败时全部拒绝
+func (c *Client) FoodEnrollBatch(items []*food.FoodEnrollItem) ([]*food.BatchResult, error) {
+	var result []*food.BatchResult
+	err := c.do("POST", "/batches/foods", nil, items, &result)
+	return result, err
+}
+
+// IngredientExchangeBatch 批量转让食材, 任一条失败时全部拒绝
+func (c *Client) IngredientExchangeBatch(items []*food.IngredientExchangeItem) ([]*food.BatchResult, error) {
+	var result []*food.BatchResult
+	err := c.do("POST", "/batches/ingredient-exchanges", nil, items, &result)
+	return result, err
+}
+
+// FoodProcess 记录加工步骤
+func (c *Client) FoodProcess(id string, stepType string, facilityId string, operatorId string, inputIds []string, outputIds []string, parameters map[string]string) error {
+	body := map[string]interface{}{}
+	body["id"] = id
+	body["step_type"] = stepType
+	if facilityId != "" {
+		body["facility_id"] = facilityId
+	}
+	body["operator_id"] = operatorId
+	if len(inputIds) != 0 {
+		body["input_ids"] = inputIds
+	}
+	if len(outputIds) != 0 {
+		body["output_ids"] = outputIds
+	}
+	if len(parameters) != 0 {
+		body["parameters"] = parameters
+	}
+	return c.do("POST", "/process-steps", nil, body, nil)
+}
+
+// QueryProcessStep 查询加工步骤
+func (c *Client) QueryProcessStep(id string) (*food.ProcessStep, error) {
+	var result *food.ProcessStep
+	err := c.do("GET", "/process-steps/"+url.PathEscape(id), nil, nil, &result)
+	return result, err
+}
+
+// ContainerEnroll 登记容器
+func (c *Client) ContainerEnroll(name string, id string, metadata string, ownerId string, facilityId string) error {
+	body := map[string]interface{}{}
+	body["name"] = name
+	body["id"] = id
+	if metadata != "" {
+		body["metadata"] = metadata
+	}
+	body["owner_id"] = ownerId
+	if facilityId != "" {
+		body["facility_id"] = facilityId
+	}
+	return c.do("POST", "/containers", nil, body, nil)
+}
+
+// QueryContainer 查询容器及其内容
+func (c *Client) QueryContainer(id string) (*food.ContainerContents, error) {
+	var result *food.ContainerContents
+	err := c.do("GET", "/containers/"+url.PathEscape(id), nil, nil, &result)
+	return result, err
+}
+
+// QueryContainerHistory 查询容器流通记录, query_type 为 all|enroll|exchange
+func (c *Client) QueryContainerHistory(containerId string, queryType string) ([]*food.ContainerHistory, error) {
+	query := url.Values{}
+	if queryType != "" {
+		query.Set("query_type", queryType)
+	}
+	var result []*food.ContainerHistory
+	err := c.do("GET", "/containers/"+url.PathEscape(containerId)+"/history", query, nil, &result)
+	return result, err
+}
+
+// ContainerPack 装箱, kind 为 ingredient|food|container
+func (c *Client) ContainerPack(ownerId string, containerId string, kind string, ids []string) error {
+	body := map[string]interface{}{}
+	body["owner_id"] = ownerId
+	body["kind"] = kind
+	body["ids"] = ids
+	return c.do("POST", "/containers/"+url.PathEscape(containerId)+"/pack", nil, body, nil)
+}
+
+// ContainerUnpack 拆箱, kind 为 ingredient|food|container
+func (c *Client) ContainerUnpack(ownerId string, containerId string, kind string, ids []string) error {
+	body := map[string]interface{}{}
+	body["owner_id"] = ownerId
+	body["kind"] = kind
+	body["ids"] = ids
+	return c.do("POST", "/containers/"+url.PathEscape(containerId)+"/unpack", nil, body, nil)
+}
+
+// ContainerExchange 转让容器及其全部内容
+func (c *Client) ContainerExchange(ownerId string, containerId string, currentOwnerId string, facilityId string) error {
+	body := map[string]interface{}{}
+	body["owner_id"] = ownerId
+	body["current_owner_id"] = currentOwnerId
+	if facilityId != "" {
+		body["facility_id"] = facilityId
+	}
+	return c.do("POST", "/containers/"+url.PathEscape(containerId)+"/exchange", nil, body, nil)
+}
+
+// FacilityRegister 登记设施, type 为 farm|factory|warehouse|store
+func (c *Client) FacilityRegister(id string, name string, typ string, ownerId string, address string, latitude float64, longitude float64, license string) error {
+	body := map[string]interface{}{}
+	body["id"] = id
+	body["name"] = name
+	body["type"] = typ
+	body["owner_id"] = ownerId
+	if address != "" {
+		body["address"] = address
+	}
+	body["latitude"] = latitude
+	body["longitude"] = longitude
+	body["license"] = license
+	return c.do("POST", "/facilities", nil, body, nil)
+}
+
+// QueryFacility 查询设施
+func (c *Client) QueryFacility(id string) (*food.Facility, error) {
+	var result *food.Facility
+	err := c.do("GET", "/facilities/"+url.PathEscape(id), nil, nil, &result)
+	return result, err
+}
+
+// QueryFacilityItems 查询经过设施的资产
+func (c *Client) QueryFacilityItems(facilityId string, kind string) ([]*food.FacilityVisit, error) {
+	query := url.Values{}
+	if kind != "" {
+		query.Set("kind", kind)
+	}
+	var result []*food.FacilityVisit
+	err := c.do("GET", "/facilities/"+url.PathEscape(facilityId)+"/items", query, nil, &result)
+	return result, err
+}
+
+// CertificateIssue 签发认证证书, 仅限认证机构
+func (c *Client) CertificateIssue(id string, scheme string, holderType string, holderId string, scope []string, validFrom string, validTo string) error {
+	body := map[string]interface{}{}
+	body["id"] = id
+	body["scheme"] = scheme
+	body["holder_type"] = holderType
+	body["holder_id"] = holderId
+	body["scope"] = scope
+	body["valid_from"] = validFrom
+	body["valid_to"] = validTo
+	return c.do("POST", "/certificates", nil, body, nil)
+}
+
+// QueryCertificates 查询持有者的证书
+func (c *Client) QueryCertificates(holderType string, holderId string) ([]*food.Certificate, error) {
+	query := url.Values{}
+	query.Set("holder_type", holderType)
+	query.Set("holder_id", holderId)
+	var result []*food.Certificate
+	err := c.do("GET", "/certificates", query, nil, &result)
+	return result, err
+}
+
+// QueryCertificate 查询证书
+func (c *Client) QueryCertificate(id string) (*food.Certificate, error) {
+	var result *food.Certificate
+	err := c.do("GET", "/certificates/"+url.PathEscape(id), nil, nil, &result)
+	return result, err
+}
+
+// CertificateRevoke 撤销证书, 返回失去认证的资产键
+func (c *Client) CertificateRevoke(id string, reason string) ([]string, error) {
+	body := map[string]interface{}{}
+	body["reason"] = reason
+	var result []string
+	err := c.do("POST", "/certificates/"+url.PathEscape(id)+"/revoke", nil, body, &result)
+	return result, err
+}
+
+// QueryTransfer 查询转让申请
+func (c *Client) QueryTransfer(id string) (*food.Transfer, error) {
+	var result *food.Transfer
+	err := c.do("GET", "/transfers/"+url.PathEscape(id), nil, nil, &result)
+	return result, err
+}
+
+// TransferApprove 审批转让申请, decision 为 approve|reject
+func (c *Client) TransferApprove(transferId string, decision string, comment string) (*food.Transfer, error) {
+	body := map[string]interface{}{}
+	body["decision"] = decision
+	if comment != "" {
+		body["comment"] = comment
+	}
+	var result *food.Transfer
+	err := c.do("POST", "/transfers/"+url.PathEscape(transferId)+"/decision", nil, body, &result)
+	return result, err
+}
+
+// QueryTransfers 查询资产的全部转让申请
+func (c *Client) QueryTransfers(kind string, assetId string) ([]*food.Transfer, error) {
+	var result []*food.Transfer
+	err := c.do("GET", "/assets/"+url.PathEscape(kind)+"/"+url.PathEscape(assetId)+"/transfers", nil, nil, &result)
+	return result, err
+}
+
+// QueryEndorsement 查询资产键的背书组织
+func (c *Client) QueryEndorsement(kind string, assetId string) (*food.KeyEndorsement, error) {
+	var result *food.KeyEndorsement
+	err := c.do("GET", "/assets/"+url.PathEscape(kind)+"/"+url.PathEscape(assetId)+"/endorsement", nil, nil, &result)
+	return result, err
+}
+
+// QueryConfig 查询链码配置, 可以指定历史版本
+func (c *Client) QueryConfig(version int) (*food.Config, error) {
+	query := url.Values{}
+	if version != 0 {
+		query.Set("version", strconv.Itoa(version))
+	}
+	var result *food.Config
+	err := c.do("GET", "/config", query, nil, &result)
+	return result, err
+}
+
+// UpdateConfig 更新链码配置, 仅限管理员, version 必须是当前版本
+func (c *Client) UpdateConfig(config *food.Config) error {
+	return c.do("PUT", "/config", nil, config, nil)
+}
+
+// Migrate 分页迁移旧版本数据, 仅限管理员
+func (c *Client) Migrate(pageSize int, bookmark string) (*food.MigrateResult, error) {
+	body := map[string]interface{}{}
+	if pageSize != 0 {
+		body["page_size"] = pageSize
+	}
+	if bookmark != "" {
+		body["bookmark"] = bookmark
+	}
+	var result *food.MigrateResult
+	err := c.do("POST", "/migrations", nil, body, &result)
+	return result, err
+}
+
+// CheckConsistency 分页检查账本一致性
+func (c *Client) CheckConsistency(pageSize int, bookmark string) (*food.ConsistencyReport, error) {
+	query := url.Values{}
+	if pageSize != 0 {
+		query.Set("page_size", strconv.Itoa(pageSize))
+	}
+	if bookmark != "" {
+		query.Set("bookmark", bookmark)
+	}
+	var result *food.ConsistencyReport
+	err := c.do("GET", "/consistency", query, nil, &result)
+	return result, err
+}
+
+// QueryRepairs 查询修复记录
+func (c *Client) QueryRepairs(target string) ([]*food.RepairRecord, error) {
+	query := url.Values{}
+	if target != "" {
+		query.Set("target", target)
+	}
+	var result []*food.RepairRecord
+	err := c.do("GET", "/repairs", query, nil, &result)
+	return result, err
+}
+
+// RepairOrphan 把无主资产分配给用户, 仅限管理员
+func (c *Client) RepairOrphan(kind string, assetId string, userId string, reason string) (*food.RepairRecord, error) {
+	body := map[string]interface{}{}
+	body["kind"] = kind
+	body["asset_id"] = assetId
+	body["user_id"] = userId
+	body["reason"] = reason
+	var result *food.RepairRecord
+	err := c.do("POST", "/repairs/orphan", nil, body, &result)
+	return result, err
+}
+
+// RepairReference 删除持有者中失效的资产引用, 仅限管理员
+func (c *Client) RepairReference(holderType string, holderId string, kind string, assetId string, reason string) (*food.RepairRecord, error) {
+	body := map[string]interface{}{}
+	body["holder_type"] = holderType
+	body["holder_id"] = holderId
+	body["kind"] = kind
+	body["asset_id"] = assetId
+	body["reason"] = reason
+	var result *food.RepairRecord
+	err := c.do("POST", "/repairs/reference", nil, body, &result)
+	return result, err
+}
+
+// RepairHoldings 按流通记录重建用户的资产列表, 仅限管理员
+func (c *Client) RepairHoldings(userId string, reason string) (*food.RepairRecord, error) {
+	body := map[string]interface{}{}
+	body["user_id"] = userId
+	body["reason"] = reason
+	var result *food.RepairRecord
+	err := c.do("POST", "/repairs/holdings", nil, body, &result)
+	return result, err
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"reflect"
+	"strings"
+)
+
+// 客户端方法名, 链码函数名首字母大写
+func methodName(function string) string {
+	return strings.ToUpper(function[:1]) + function[1:]
+}
+
+// 整个请求体参数的名字
+func bodyName(t reflect.Type) string {
+	if t.Kind() == reflect.Slice {
+		return "items"
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return strings.ToLower(t.Name()[:1]) + t.Name()[1:]
+}
+
+// 路径表达式, 路径参数做转义
+func pathExpr(op *operation) string {
+	parts := make([]string, 0)
+	literal := ""
+	for _, segment := range strings.Split(strings.Trim(op.Route.Path, "/"), "/") {
+		if strings.HasPrefix(segment, "{") {
+			name := segment[1 : len(segment)-1]
+			for _, p := range op.Params {
+				if p.Source == "path" && p.Name == name {
+					parts = append(parts, fmt.Sprintf("%q", literal+"/"), fmt.Sprintf("url.PathEscape(%s)", p.goName()))
+				}
+			}
+			literal = ""
+			continue
+		}
+		literal += "/" + segment
+	}
+	if literal != "" {
+		parts = append(parts, fmt.Sprintf("%q", literal))
+	}
+
+	return strings.Join(parts, " + ")
+}
+
+// 查询参数转换为字符串
+func queryValue(p *param) string {
+	switch p.Type {
+	case "integer":
+		return fmt.Sprintf("strconv.Itoa(%s)", p.goName())
+	case "number":
+		return fmt.Sprintf("strconv.FormatFloat(%s, 'f', -1, 64)", p.goName())
+	default:
+		return p.goName()
+	}
+}
+
+func writeMethod(buf *bytes.Buffer, op *operation) {
+	route := op.Route
+	name := methodName(route.Function)
+
+	args := make([]string, 0, len(op.Params))
+	for _, p := range op.Params {
+		if p.Type == "body" {
+			args = append(args, fmt.Sprintf("%s %s", bodyName(op.Body), op.Body.String()))
+			continue
+		}
+		args = append(args, fmt.Sprintf("%s %s", p.goName(), p.goType()))
+	}
+
+	fmt.Fprintf(buf, "// %s %s\n", name, route.Summary)
+	if op.Response != nil {
+		fmt.Fprintf(buf, "func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), op.Response.String())
+	} else {
+		fmt.Fprintf(buf, "func (c *Client) %s(%s) error {\n", name, strings.Join(args, ", "))
+	}
+
+	query, body := "nil", "nil"
+	for _, p := range op.Params {
+		switch {
+		case p.Source == "query":
+			if query == "nil" {
+				query = "query"
+				buf.WriteString("query := url.Values{}\n")
+			}
+			if p.Optional {
+				fmt.Fprintf(buf, "if %s {\nquery.Set(%q, %s)\n}\n", p.zeroCheck(), p.Name, queryValue(p))
+			} else {
+				fmt.Fprintf(buf, "query.Set(%q, %s)\n", p.Name, queryValue(p))
+			}
+		case p.Type == "body":
+			body = bodyName(op.Body)
+		case p.Source == "body":
+			if body == "nil" {
+				body = "body"
+				buf.WriteString("body := map[string]interface{}{}\n")
+			}
+			if p.Optional {
+				fmt.Fprintf(buf, "if %s {\nbody[%q] = %s\n}\n", p.zeroCheck(), p.Name, p.goName())
+			} else {
+				fmt.Fprintf(buf, "body[%q] = %s\n", p.Name, p.goName())
+			}
+		}
+	}
+
+	call := fmt.Sprintf("c.do(%q, %s, %s, %s, %%s)", route.Method, pathExpr(op), query, body)
+	if op.Response != nil {
+		fmt.Fprintf(buf, "var result %s\n", op.Response.String())
+		fmt.Fprintf(buf, "err := "+call+"\n", "&result")
+		buf.WriteString("return result, err\n}\n\n")
+	} else {
+		fmt.Fprintf(buf, "return "+call+"\n}\n\n", "nil")
+	}
+}
+
+func buildClient(operations []*operation) ([]byte, error) {
+	var methods bytes.Buffer
+	for _, op := range operations {
+		writeMethod(&methods, op)
+	}
+	code := methods.String()
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by foodapigen. DO NOT EDIT.\n\npackage client\n\nimport (\n")
+	if strings.Contains(code, "strconv.") {
+		buf.WriteString("\"strconv\"\n")
+	}
+	if strings.Contains(code, "url.") {
+		buf.WriteString("\"net/url\"\n")
+	}
+	if strings.Contains(code, "food.") {
+		buf.WriteString("\n\"github.com/Blockchain-book/Fabric-Food/chaincode/food\"\n")
+	}
+	buf.WriteString(")\n\n")
+	buf.WriteString(code)
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format client error: %s", err)
+	}
+
+	return formatted, nil
+}
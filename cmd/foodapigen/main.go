@@ -0,0 +1,56 @@
+// foodapigen 根据 gateway 的路由表生成 OpenAPI 3 文档和 Go 客户端。
+//
+//	foodapigen -spec gateway/openapi.yaml -client client/client_gen.go
+//
+// 参数取自链码的请求结构: 字段名为json标签, 顺序由请求结构的 Args 决定,
+// 返回结构直接取自链码的类型, 修改链码或路由后重新生成, 文档、客户端和网关不会互相偏离。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+)
+
+func run() error {
+	specFile := flag.String("spec", "gateway/openapi.yaml", "OpenAPI output file, empty to skip")
+	clientFile := flag.String("client", "client/client_gen.go", "Go client output file, empty to skip")
+	flag.Parse()
+
+	operations, err := parseRoutes(gateway.Routes)
+	if err != nil {
+		return err
+	}
+
+	if *specFile != "" {
+		spec, err := buildSpec(operations)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*specFile, spec, 0644); err != nil {
+			return err
+		}
+	}
+
+	if *clientFile != "" {
+		code, err := buildClient(operations)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*clientFile, code, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
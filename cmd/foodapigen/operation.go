@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+)
+
+// 单个参数
+type param struct {
+	*gateway.Param
+}
+
+// Go 客户端中的参数名
+func (p *param) goName() string {
+	if p.Name == "type" {
+		return "typ"
+	}
+
+	parts := strings.Split(p.Name, "_")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+
+	return strings.Join(parts, "")
+}
+
+func (p *param) goType() string {
+	switch p.Type {
+	case "array":
+		return "[]string"
+	case "number":
+		return "float64"
+	case "integer":
+		return "int"
+	case "object":
+		return "map[string]string"
+	default:
+		return "string"
+	}
+}
+
+// 参数为零值时的判断, 可选参数为零值时不发送
+func (p *param) zeroCheck() string {
+	switch p.Type {
+	case "array", "object":
+		return fmt.Sprintf("len(%s) != 0", p.goName())
+	case "number", "integer":
+		return fmt.Sprintf("%s != 0", p.goName())
+	default:
+		return fmt.Sprintf("%s != \"\"", p.goName())
+	}
+}
+
+// 单个接口
+type operation struct {
+	Route    *gateway.Route
+	Params   []*param
+	Body     reflect.Type
+	Response reflect.Type
+}
+
+func (o *operation) tag() string {
+	return strings.Split(strings.Trim(o.Route.Path, "/"), "/")[0]
+}
+
+func (o *operation) successStatus() int {
+	if o.Route.Created {
+		return 201
+	}
+	if o.Response == nil {
+		return 204
+	}
+
+	return 200
+}
+
+func parseRoutes(routes []*gateway.Route) ([]*operation, error) {
+	operations := make([]*operation, 0, len(routes))
+	functions := make(map[string]bool)
+	for _, route := range routes {
+		if functions[route.Function] {
+			return nil, fmt.Errorf("duplicate function %s", route.Function)
+		}
+		functions[route.Function] = true
+
+		op := &operation{Route: route}
+		if route.Response != nil {
+			op.Response = reflect.TypeOf(route.Response)
+		}
+
+		params, err := route.Params()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range params {
+			if p.Type == "body" {
+				op.Body = p.Field
+			}
+			op.Params = append(op.Params, &param{p})
+		}
+
+		operations = append(operations, op)
+	}
+
+	return operations, nil
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// 保持键顺序的 YAML 对象
+type m []kv
+
+type kv struct {
+	Key   string
+	Value interface{}
+}
+
+func (o m) MarshalYAML() (interface{}, error) {
+	slice := make(yaml.MapSlice, 0, len(o))
+	for _, item := range o {
+		slice = append(slice, yaml.MapItem{Key: item.Key, Value: item.Value})
+	}
+
+	return slice, nil
+}
+
+// 错误响应, 状态码与 gateway 的错误映射一致
+var errorResponses = []struct {
+	Status      int
+	Name        string
+	Description string
+	Write       bool // 只有提交交易的接口会返回
+}{
+	{400, "BadRequest", "参数缺失或格式错误 (INVALID_ARGUMENT)", false},
+	{401, "Unauthorized", "缺少凭证、凭证无效或身份未知", false},
+	{403, "Forbidden", "调用身份无权限、X-Fabric-Identity 与凭证不符或功能已关闭 (FORBIDDEN, FUNCTION_DISABLED)", false},
+	{404, "NotFound", "数据不存在 (NOT_FOUND)", false},
+	{409, "Conflict", "数据已存在或与当前状态冲突 (ALREADY_EXISTS, CONFLICT)", true},
+	{422, "UnprocessableEntity", "不满足业务规则 (OWNER_MISMATCH, FAILED_PRECONDITION, BATCH_REJECTED)", true},
+	{500, "InternalError", "链码内部错误 (INTERNAL)", false},
+	{502, "BadGateway", "后端调用失败", false},
+}
+
+// 链码错误码
+var errorCodes = []string{
+	food.CodeInvalidArgument,
+	food.CodeNotFound,
+	food.CodeAlreadyExists,
+	food.CodeOwnerMismatch,
+	food.CodeForbidden,
+	food.CodeFunctionDisabled,
+	food.CodeConflict,
+	food.CodeFailedPrecondition,
+	food.CodeBatchRejected,
+	food.CodeInternal,
+}
+
+// 从链码类型生成的数据结构
+type schemas struct {
+	names []string
+	defs  map[string]interface{}
+}
+
+func (s *schemas) ref(t reflect.Type) interface{} {
+	switch t {
+	case reflect.TypeOf(json.RawMessage{}):
+		return m{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return s.ref(t.Elem())
+	case reflect.String:
+		return m{{"type", "string"}}
+	case reflect.Bool:
+		return m{{"type", "boolean"}}
+	case reflect.Int, reflect.Int32:
+		return m{{"type", "integer"}}
+	case reflect.Int64:
+		return m{{"type", "integer"}, {"format", "int64"}}
+	case reflect.Float32, reflect.Float64:
+		return m{{"type", "number"}}
+	case reflect.Slice, reflect.Array:
+		return m{{"type", "array"}, {"items", s.ref(t.Elem())}}
+	case reflect.Map:
+		return m{{"type", "object"}, {"additionalProperties", s.ref(t.Elem())}}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := s.defs[name]; !ok {
+			s.defs[name] = nil
+			s.names = append(s.names, name)
+			s.defs[name] = s.object(t)
+		}
+		return m{{"$ref", "#/components/schemas/" + name}}
+	default:
+		// interface{} 等任意 JSON
+		return m{}
+	}
+}
+
+func (s *schemas) object(t reflect.Type) interface{} {
+	properties := m{}
+	required := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties = append(properties, kv{name, s.ref(field.Type)})
+		omitempty := false
+		for _, option := range tag[1:] {
+			omitempty = omitempty || option == "omitempty"
+		}
+		if !omitempty {
+			required = append(required, name)
+		}
+	}
+
+	object := m{{"type", "object"}}
+	if len(required) != 0 {
+		object = append(object, kv{"required", required})
+	}
+
+	return append(object, kv{"properties", properties})
+}
+
+func argSchema(p *param) interface{} {
+	switch p.Type {
+	case "array":
+		return m{{"type", "array"}, {"items", m{{"type", "string"}}}}
+	case "object":
+		return m{{"type", "object"}, {"additionalProperties", m{{"type", "string"}}}}
+	default:
+		return m{{"type", p.Type}}
+	}
+}
+
+func (s *schemas) operation(op *operation) m {
+	route := op.Route
+	operation := m{
+		{"operationId", route.Function},
+		{"summary", route.Summary},
+		{"tags", []string{op.tag()}},
+	}
+
+	parameters := make([]interface{}, 0)
+	properties := m{}
+	required := make([]string, 0)
+	for _, p := range op.Params {
+		switch {
+		case p.Source == "path" || p.Source == "query":
+			parameters = append(parameters, m{
+				{"name", p.Name},
+				{"in", p.Source},
+				{"required", !p.Optional},
+				{"schema", argSchema(p)},
+			})
+		case p.Type != "body":
+			properties = append(properties, kv{p.Name, argSchema(p)})
+			if !p.Optional {
+				required = append(required, p.Name)
+			}
+		}
+	}
+	if len(parameters) != 0 {
+		operation = append(operation, kv{"parameters", parameters})
+	}
+
+	var body interface{}
+	switch {
+	case op.Body != nil:
+		body = s.ref(op.Body)
+	case len(properties) != 0:
+		object := m{{"type", "object"}, {"additionalProperties", false}}
+		if len(required) != 0 {
+			object = append(object, kv{"required", required})
+		}
+		body = append(object, kv{"properties", properties})
+	}
+	if body != nil {
+		operation = append(operation, kv{"requestBody", m{
+			{"required", op.Body != nil || len(required) != 0},
+			{"content", m{{"application/json", m{{"schema", body}}}}},
+		}})
+	}
+
+	success := m{{"description", "成功"}}
+	if op.Response != nil {
+		success = append(success, kv{"content", m{{"application/json", m{{"schema", s.ref(op.Response)}}}}})
+	}
+	responses := m{{strconv.Itoa(op.successStatus()), success}}
+	if op.Response != nil && op.successStatus() == 200 && route.Method != "GET" {
+		responses = append(responses, kv{"204", m{{"description", "成功, 链码没有返回值"}}})
+	}
+	for _, e := range errorResponses {
+		if e.Write && route.Method == "GET" {
+			continue
+		}
+		responses = append(responses, kv{strconv.Itoa(e.Status), m{{"$ref", "#/components/responses/" + e.Name}}})
+	}
+
+	return append(operation, kv{"responses", responses})
+}
+
+func buildSpec(operations []*operation) ([]byte, error) {
+	s := &schemas{defs: make(map[string]interface{})}
+
+	paths := m{}
+	index := make(map[string]int)
+	for _, op := range operations {
+		method := strings.ToLower(op.Route.Method)
+		i, ok := index[op.Route.Path]
+		if !ok {
+			i = len(paths)
+			index[op.Route.Path] = i
+			paths = append(paths, kv{op.Route.Path, m{}})
+		}
+		paths[i].Value = append(paths[i].Value.(m), kv{method, s.operation(op)})
+	}
+
+	components := m{}
+	schemaDefs := m{{"Error", m{
+		{"type", "object"},
+		{"required", []string{"error"}},
+		{"properties", m{
+			{"error", m{{"type", "string"}}},
+			{"code", m{{"type", "string"}, {"enum", errorCodes}, {"description", "链码错误码, 网关自身的错误没有错误码"}}},
+			{"field", m{{"type", "string"}}},
+			{"entity_id", m{{"type", "string"}}},
+			{"fields", m{{"type", "array"}, {"items", s.ref(reflect.TypeOf(food.FieldError{}))}}},
+		}},
+	}}}
+	for _, name := range s.names {
+		schemaDefs = append(schemaDefs, kv{name, s.defs[name]})
+	}
+	components = append(components, kv{"schemas", schemaDefs})
+
+	responses := m{}
+	for _, e := range errorResponses {
+		responses = append(responses, kv{e.Name, m{
+			{"description", e.Description},
+			{"content", m{{"application/json", m{{"schema", m{{"$ref", "#/components/schemas/Error"}}}}}}},
+		}})
+	}
+	components = append(components, kv{"responses", responses})
+	components = append(components, kv{"securitySchemes", m{
+		{"bearer", m{
+			{"type", "http"},
+			{"scheme", "bearer"},
+			{"description", "identities.json 中 token_sha256 对应的 token, 决定调用链码的身份; 也可以用 client_cn 对应的 mTLS 客户端证书认证"},
+		}},
+		{"identity", m{
+			{"type", "apiKey"},
+			{"in", "header"},
+			{"name", gateway.IdentityHeader},
+			{"description", "网关配置中的身份名, 与凭证一起出现时必须一致; 只有 -mock 的网关可以单独用它选择身份"},
+		}},
+	}})
+
+	spec := m{
+		{"openapi", "3.0.3"},
+		{"info", m{
+			{"title", "Fabric-Food API"},
+			{"description", "食品溯源链码的 REST 接口, 由 cmd/foodapigen 根据 gateway/routes.go 生成, 请勿手工修改。operationId 即链码函数名, 请求参数按链码参数顺序列出。"},
+			{"version", "1.0.0"},
+		}},
+		{"security", []interface{}{m{{"bearer", []string{}}}, m{{"identity", []string{}}}}},
+		{"paths", paths},
+		{"components", components},
+	}
+
+	out, err := yaml.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal spec error: %s", err)
+	}
+
+	return append([]byte("# Code generated by foodapigen. DO NOT EDIT.\n"), out...), nil
+}
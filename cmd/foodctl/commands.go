@@ -0,0 +1,79 @@
+package main
+
+// 子命令, 每个链码函数对应一个
+type command struct {
+	Group    string
+	Name     string
+	Function string
+	Summary  string
+}
+
+var commands = []*command{
+	{"user", "register", "userRegister", "用户注册"},
+	{"user", "destroy", "userDestroy", "删除用户"},
+	{"user", "show", "queryUser", "用户查询"},
+
+	{"ingredient", "enroll", "ingredientEnroll", "食材登记"},
+	{"ingredient", "enroll-batch", "ingredientEnrollBatch", "批量食材登记"},
+	{"ingredient", "transfer", "ingredientExchange", "食材转让"},
+	{"ingredient", "transfer-batch", "ingredientExchangeBatch", "批量食材转让"},
+	{"ingredient", "compose", "ingredientExchangeFood", "食材并入食品"},
+	{"ingredient", "show", "queryIngredient", "食材查询"},
+	{"ingredient", "history", "queryIngredientHistory", "食材流通记录"},
+
+	{"food", "enroll", "foodEnroll", "食品登记"},
+	{"food", "enroll-batch", "foodEnrollBatch", "批量食品登记"},
+	{"food", "transfer", "foodExchange", "食品转让, 需要审批时返回转让申请"},
+	{"food", "compose", "foodExchangeFood", "食品并入其他食品"},
+	{"food", "show", "queryFood", "食品查询"},
+	{"food", "history", "queryFoodHistory", "食品流通记录"},
+	{"food", "provenance", "queryFoodProvenance", "食品溯源"},
+	{"food", "allergens", "queryFoodAllergens", "过敏原标签"},
+	{"food", "verify", "verifyProduct", "按序列号验证产品"},
+	{"food", "recall", "foodRecall", "召回食品或解除召回"},
+
+	{"process", "run", "foodProcess", "记录加工步骤"},
+	{"process", "show", "queryProcessStep", "加工步骤查询"},
+
+	{"container", "enroll", "containerEnroll", "容器登记"},
+	{"container", "pack", "containerPack", "装箱"},
+	{"container", "unpack", "containerUnpack", "拆箱"},
+	{"container", "transfer", "containerExchange", "容器连同内容转让"},
+	{"container", "show", "queryContainer", "容器查询"},
+	{"container", "history", "queryContainerHistory", "容器流通记录"},
+
+	{"facility", "register", "facilityRegister", "设施登记"},
+	{"facility", "show", "queryFacility", "设施查询"},
+	{"facility", "items", "queryFacilityItems", "经过设施的资产"},
+
+	{"certificate", "issue", "certificateIssue", "签发证书"},
+	{"certificate", "revoke", "certificateRevoke", "撤销证书"},
+	{"certificate", "show", "queryCertificate", "证书查询"},
+	{"certificate", "list", "queryCertificates", "持有者的证书"},
+
+	{"transfer", "approve", "transferApprove", "审批转让申请"},
+	{"transfer", "show", "queryTransfer", "转让申请查询"},
+	{"transfer", "list", "queryTransfers", "资产的转让申请"},
+
+	{"endorsement", "show", "queryEndorsement", "资产键的背书组织"},
+
+	{"config", "show", "queryConfig", "配置查询"},
+	{"config", "update", "updateConfig", "更新配置"},
+
+	{"admin", "migrate", "migrate", "分页迁移旧版本数据"},
+	{"admin", "check", "checkConsistency", "账本一致性检查"},
+	{"admin", "repair-orphan", "repairOrphan", "无主资产分配给用户"},
+	{"admin", "repair-reference", "repairReference", "删除失效的资产引用"},
+	{"admin", "repair-holdings", "repairHoldings", "按流通记录重建资产列表"},
+	{"admin", "repairs", "queryRepairs", "修复记录查询"},
+}
+
+func lookupCommand(group, name string) *command {
+	for _, c := range commands {
+		if c.Group == group && c.Name == name {
+			return c
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+)
+
+// 请求结构的一个字段, 对应子命令的一个参数
+type requestField struct {
+	Name     string // 参数名, json标签中的 _ 换成 -
+	Key      string // json标签
+	Kind     reflect.Kind
+	Elem     reflect.Kind
+	Required bool
+}
+
+func requestFields(req food.Request) []*requestField {
+	t := reflect.TypeOf(req).Elem()
+	fields := make([]*requestField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		field := &requestField{
+			Name: strings.Replace(tag[0], "_", "-", -1),
+			Key:  tag[0],
+			Kind: f.Type.Kind(),
+		}
+		if field.Kind == reflect.Slice {
+			field.Elem = f.Type.Elem().Kind()
+		}
+		// 与 DecodeRequest 相同: 不带 omitempty 的字符串、数组和对象为必填
+		if len(tag) == 1 || tag[1] != "omitempty" {
+			switch field.Kind {
+			case reflect.String, reflect.Slice, reflect.Map, reflect.Ptr:
+				field.Required = true
+			}
+		}
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// 参数说明
+func (f *requestField) usage() string {
+	var kind string
+	switch {
+	case f.Kind == reflect.String:
+		kind = "string"
+	case f.Kind == reflect.Int || f.Kind == reflect.Int64:
+		kind = "integer"
+	case f.Kind == reflect.Float64:
+		kind = "number"
+	case f.Kind == reflect.Bool:
+		kind = "true|false"
+	case f.Kind == reflect.Slice && f.Elem == reflect.String:
+		kind = "id1,id2,..."
+	default:
+		kind = "json|@file"
+	}
+	if f.Required {
+		return kind + ", 必填"
+	}
+
+	return kind
+}
+
+// 参数值转换为命名参数中的JSON值
+// 数值解析失败时按字符串传入, 由 DecodeRequest 报告类型错误
+func (f *requestField) value(arg string) (json.RawMessage, error) {
+	switch {
+	case f.Kind == reflect.String:
+		return json.Marshal(arg)
+	case f.Kind == reflect.Int || f.Kind == reflect.Int64:
+		if _, err := strconv.ParseInt(arg, 10, 64); err == nil {
+			return json.RawMessage(arg), nil
+		}
+		return json.Marshal(arg)
+	case f.Kind == reflect.Float64:
+		if _, err := strconv.ParseFloat(arg, 64); err == nil {
+			return json.RawMessage(arg), nil
+		}
+		return json.Marshal(arg)
+	case f.Kind == reflect.Bool:
+		if b, err := strconv.ParseBool(arg); err == nil {
+			return json.Marshal(b)
+		}
+		return json.Marshal(arg)
+	case f.Kind == reflect.Slice && f.Elem == reflect.String:
+		ids := make([]string, 0)
+		for _, id := range strings.Split(arg, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		return json.Marshal(ids)
+	default:
+		return readJSON(arg)
+	}
+}
+
+// JSON参数, 以 @ 开头时从文件读取, @- 表示标准输入
+func readJSON(arg string) (json.RawMessage, error) {
+	data := []byte(arg)
+	if strings.HasPrefix(arg, "@") {
+		var err error
+		if arg == "@-" {
+			data, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			data, err = ioutil.ReadFile(arg[1:])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data = bytes.TrimSpace(data)
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("invalid json")
+	}
+
+	return json.RawMessage(data), nil
+}
+
+// 解析子命令参数, 生成请求
+// -json 传入整个请求对象, 单独的参数覆盖其中的同名字段
+func parseRequest(cmd *command, args []string) (food.Request, error) {
+	req := food.NewRequest(cmd.Function)
+	fields := requestFields(req)
+
+	fs := flag.NewFlagSet(cmd.Group+" "+cmd.Name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Usage = func() { commandUsage(cmd, fields) }
+	input := fs.String("json", "", "")
+	values := make(map[string]*string, len(fields))
+	for _, field := range fields {
+		values[field.Name] = fs.String(field.Name, "", "")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 0 {
+		return nil, fmt.Errorf("unexpected argument %s", fs.Arg(0))
+	}
+
+	object := make(map[string]json.RawMessage)
+	if *input != "" {
+		data, err := readJSON(*input)
+		if err != nil {
+			return nil, fmt.Errorf("-json: %s", err)
+		}
+		if err := json.Unmarshal(data, &object); err != nil {
+			return nil, fmt.Errorf("-json: expected object")
+		}
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, field := range fields {
+		if !set[field.Name] {
+			continue
+		}
+		value, err := field.value(*values[field.Name])
+		if err != nil {
+			return nil, fmt.Errorf("-%s: %s", field.Name, err)
+		}
+		object[field.Key] = value
+	}
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	if err := food.DecodeRequest(data, req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func commandUsage(cmd *command, fields []*requestField) {
+	fmt.Fprintf(os.Stderr, "usage: foodctl [global flags] %s %s [flags]\n\n%s (%s)\n\n", cmd.Group, cmd.Name, cmd.Summary, cmd.Function)
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, field := range fields {
+		fmt.Fprintf(w, "  -%s\t%s\n", field.Name, field.usage())
+	}
+	fmt.Fprintf(w, "  -json\t整个请求对象, json|@file\n")
+	w.Flush()
+}
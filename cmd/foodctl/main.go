@@ -0,0 +1,213 @@
+// foodctl 是运维用的命令行工具, 每个链码函数对应一个子命令, 参数名为请求结构的json标签。
+//
+//	foodctl user register -name alice -id user1
+//	foodctl ingredient enroll -name rice -id ingredient1 -owner-id user1 -allergens gluten
+//	foodctl -o json ingredient history -ingredient-id ingredient1
+//	foodctl ingredient enroll-batch -items @items.json
+//
+// 身份取自 -msp-id 和 -msp-path, 指定 -connection-profile 时通过 fabric-sdk-go 调用链码,
+// 否则调用本机的 peer 命令, 只用于开发环境。
+// 加上 -simulate state.json 时在进程内的模拟账本(simulator 包)上执行链码, 不连接区块链网络,
+// 世界状态、键的历史和模拟时钟在每次成功执行后保存到 state.json, 下次运行时恢复:
+//
+//	foodctl -simulate state.json user register -name alice -id user1
+//	foodctl -simulate state.json -o yaml user show -id user1
+//
+// 状态文件不存在时以 -config 文件的内容初始化链码, 未指定时当前 MSP 为管理员。
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+	"github.com/Blockchain-book/Fabric-Food/sdk"
+	"github.com/Blockchain-book/Fabric-Food/simulator"
+)
+
+// 命令行使用的身份名
+const identityName = "foodctl"
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: foodctl [global flags] <group> <command> [flags]\n\nglobal flags:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\ncommands:\n")
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %s %s\t%s\n", c.Group, c.Name, c.Summary)
+	}
+	w.Flush()
+	fmt.Fprintf(os.Stderr, "\nrun 'foodctl <group> <command> -h' for command flags\n")
+}
+
+func envOr(name, value string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+
+	return value
+}
+
+// 解析 key=value,key=value 形式的证书属性
+func parseAttributes(spec string) (map[string]string, error) {
+	attrs := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid attribute %s, expected key=value", pair)
+		}
+		attrs[kv[0]] = kv[1]
+	}
+
+	return attrs, nil
+}
+
+// 打开模拟账本, 状态文件不存在时以 -config 初始化链码, 未指定时当前MSP为管理员
+func openSimulator(stateFile, configFile string, identity *gateway.Identity) (*simulator.Simulator, error) {
+	config := fmt.Sprintf(`{"admin_msps":[%q]}`, identity.MSPID)
+	if configFile != "" {
+		configBytes, err := ioutil.ReadFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		config = string(configBytes)
+	}
+
+	sim, err := simulator.Open(stateFile, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := sim.AddIdentity(identityName, identity); err != nil {
+		return nil, err
+	}
+
+	return sim, nil
+}
+
+// 命令行错误, 命名参数的字段换成对应的参数名
+func errorMessage(err error) string {
+	switch e := err.(type) {
+	case *food.ArgsError:
+		reasons := make([]string, 0, len(e.Fields))
+		for _, f := range e.Fields {
+			if f.Field == "" {
+				reasons = append(reasons, f.Reason)
+				continue
+			}
+			reasons = append(reasons, fmt.Sprintf("-%s: %s", strings.Replace(f.Field, "_", "-", -1), f.Reason))
+		}
+		return "invalid args: " + strings.Join(reasons, "; ")
+	case *gateway.ChaincodeError:
+		if e.Detail == nil {
+			return e.Error()
+		}
+		message := fmt.Sprintf("%s: %s", e.Detail.Code, e.Message)
+		if e.Detail.EntityId != "" {
+			message += fmt.Sprintf(" (%s)", e.Detail.EntityId)
+		}
+		for _, f := range e.Detail.Fields {
+			message += fmt.Sprintf("\n  %s: %s", f.Field, f.Reason)
+		}
+		return message
+	default:
+		return err.Error()
+	}
+}
+
+func run() error {
+	flag.Usage = usage
+	simulate := flag.String("simulate", "", "run the chaincode in-process on the mock state `file` instead of a peer")
+	configFile := flag.String("config", "", "chaincode config passed to Init when the -simulate state file is new")
+	output := flag.String("o", outputTable, "output format: table|json|yaml")
+	profile := flag.String("connection-profile", "", "fabric-sdk-go connection profile, without it the peer binary is used (development only)")
+	peerBin := flag.String("peer", "peer", "peer binary, only without -connection-profile")
+	channel := flag.String("channel", "assetschannel", "channel name")
+	chaincode := flag.String("chaincode", "assets", "chaincode name")
+	orderer := flag.String("orderer", "", "orderer address, only without -connection-profile")
+	mspID := flag.String("msp-id", envOr("CORE_PEER_LOCALMSPID", "Org1MSP"), "MSP id of the caller")
+	mspPath := flag.String("msp-path", os.Getenv("CORE_PEER_MSPCONFIGPATH"), "MSP directory of the caller, only without -simulate")
+	attrs := flag.String("attrs", "", "certificate attributes key=value,... of the caller, only with -simulate")
+	flag.Parse()
+
+	if !validOutput(*output) {
+		return fmt.Errorf("unknown output format %s", *output)
+	}
+	if flag.NArg() < 2 {
+		usage()
+		return fmt.Errorf("missing command")
+	}
+	cmd := lookupCommand(flag.Arg(0), flag.Arg(1))
+	if cmd == nil {
+		return fmt.Errorf("unknown command %s %s, run 'foodctl -h' for the command list", flag.Arg(0), flag.Arg(1))
+	}
+
+	req, err := parseRequest(cmd, flag.Args()[2:])
+	if err == flag.ErrHelp {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	identity := &gateway.Identity{MSPID: *mspID, MSPConfigPath: *mspPath}
+	var backend gateway.Backend
+	var sim *simulator.Simulator
+	if *simulate != "" {
+		identity.MSPConfigPath = ""
+		identity.Attributes, err = parseAttributes(*attrs)
+		if err != nil {
+			return err
+		}
+		sim, err = openSimulator(*simulate, *configFile, identity)
+		if err != nil {
+			return err
+		}
+		backend = sim
+	} else if *profile != "" {
+		sdkBackend, err := gateway.NewSDKBackend(*profile, *channel, *chaincode, map[string]*gateway.Identity{identityName: identity})
+		if err != nil {
+			return err
+		}
+		defer sdkBackend.Close()
+		backend = sdkBackend
+	} else {
+		backend = &gateway.PeerBackend{
+			PeerBin:    *peerBin,
+			Channel:    *channel,
+			Chaincode:  *chaincode,
+			Orderer:    *orderer,
+			Identities: map[string]*gateway.Identity{identityName: identity},
+		}
+	}
+
+	var payload json.RawMessage
+	client := sdk.New(&sdk.BackendInvoker{Backend: backend, Identity: identityName})
+	if err := client.Execute(req, &payload); err != nil {
+		return err
+	}
+
+	if sim != nil {
+		if err := sim.Save(*simulate); err != nil {
+			return fmt.Errorf("save %s error: %s", *simulate, err)
+		}
+	}
+
+	return writeOutput(os.Stdout, *output, payload)
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, errorMessage(err))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+func validOutput(format string) bool {
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return true
+	default:
+		return false
+	}
+}
+
+// 输出链码返回值, 对象字段保持链码返回的顺序
+// 返回值不是JSON时原样输出
+func writeOutput(w io.Writer, format string, payload []byte) error {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 {
+		return nil
+	}
+
+	value, err := decodeOrdered(payload)
+	if err != nil {
+		_, err := fmt.Fprintf(w, "%s\n", payload)
+		return err
+	}
+
+	switch format {
+	case outputJSON:
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, payload, "", "  "); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		_, err = buf.WriteTo(w)
+		return err
+	case outputYAML:
+		out, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	default:
+		return writeTable(w, value)
+	}
+}
+
+func decodeOrdered(payload []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	value, err := decodeValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("trailing data")
+	}
+
+	return value, nil
+}
+
+// 对象解码为 yaml.MapSlice, 保持字段顺序
+func decodeValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+
+	switch delim {
+	case '{':
+		object := make(yaml.MapSlice, 0)
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, yaml.MapItem{Key: key, Value: value})
+		}
+		_, err = decoder.Token()
+		return object, err
+	default:
+		array := make([]interface{}, 0)
+		for decoder.More() {
+			value, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = decoder.Token()
+		return array, err
+	}
+}
+
+// 对象按 字段/值 两列输出, 对象数组每个对象一行, 嵌套的值以紧凑JSON输出
+func writeTable(w io.Writer, value interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		for _, item := range v {
+			fmt.Fprintf(tw, "%s\t%s\n", strings.ToUpper(item.Key.(string)), cell(item.Value))
+		}
+	case []interface{}:
+		columns, ok := tableColumns(v)
+		if !ok {
+			for _, element := range v {
+				fmt.Fprintf(tw, "%s\n", cell(element))
+			}
+			break
+		}
+
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = strings.ToUpper(column)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, element := range v {
+			row := make([]string, len(columns))
+			for i, column := range columns {
+				row[i] = cell(lookup(element.(yaml.MapSlice), column))
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+	default:
+		fmt.Fprintln(tw, cell(v))
+	}
+
+	return tw.Flush()
+}
+
+// 对象数组的列, 按字段第一次出现的顺序
+func tableColumns(array []interface{}) ([]string, bool) {
+	if len(array) == 0 {
+		return nil, false
+	}
+
+	columns := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, element := range array {
+		object, ok := element.(yaml.MapSlice)
+		if !ok {
+			return nil, false
+		}
+		for _, item := range object {
+			key := item.Key.(string)
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+
+	return columns, true
+}
+
+func lookup(object yaml.MapSlice, key string) interface{} {
+	for _, item := range object {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+
+	return nil
+}
+
+func cell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprintf("%t", v)
+	default:
+		return compactJSON(v)
+	}
+}
+
+// 嵌套的值按原有字段顺序编码为一行JSON
+func compactJSON(value interface{}) string {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		fields := make([]string, 0, len(v))
+		for _, item := range v {
+			key, _ := json.Marshal(item.Key)
+			fields = append(fields, string(key)+":"+compactJSON(item.Value))
+		}
+		return "{" + strings.Join(fields, ",") + "}"
+	case []interface{}:
+		elements := make([]string, 0, len(v))
+		for _, element := range v {
+			elements = append(elements, compactJSON(element))
+		}
+		return "[" + strings.Join(elements, ",") + "]"
+	case json.Number:
+		return v.String()
+	default:
+		out, _ := json.Marshal(v)
+		return string(out)
+	}
+}
@@ -0,0 +1,241 @@
+// foodepcis 把食品供应链数据导出为 GS1 EPCIS 2.0 JSON-LD 文档。
+//
+//	peer chaincode query -C assetschannel -n assets -c '{"Args":["queryFoodProvenance","food1"]}' > food1.json
+//	foodepcis export -provenance food1.json -base https://id.example.com > food1.epcis.json
+//
+// 也可以用 -records 指定记录下来的供应链事件(JSON数组)。
+//
+// import 子命令把历史系统导出的EPCIS XML/JSON映射为链码调用, 账本中已有的用户和资产
+// 不再注册或登记。加上 -dry-run 时仍查询网络生成计划, 但在进程内的模拟账本上执行链码,
+// 模拟账本使用网络上的配置和计划涉及的用户和资产, 输出执行后的世界状态, 不提交任何交易。
+//
+//	foodepcis import -in legacy.xml -dry-run
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Blockchain-book/Fabric-Food/epcis"
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+)
+
+func readRecords(provenanceFile, recordsFile string) ([]*epcis.Record, error) {
+	switch {
+	case provenanceFile != "":
+		data, err := ioutil.ReadFile(provenanceFile)
+		if err != nil {
+			return nil, err
+		}
+		provenance, err := epcis.ParseProvenance(data)
+		if err != nil {
+			return nil, err
+		}
+		return epcis.FromProvenance(provenance), nil
+	case recordsFile != "":
+		data, err := ioutil.ReadFile(recordsFile)
+		if err != nil {
+			return nil, err
+		}
+		records := make([]*epcis.Record, 0)
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, fmt.Errorf("unmarshal records error: %s", err)
+		}
+		return records, nil
+	default:
+		return nil, fmt.Errorf("-provenance or -records is required")
+	}
+}
+
+func export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	provenanceFile := fs.String("provenance", "", "queryFoodProvenance result file")
+	recordsFile := fs.String("records", "", "recorded supply chain events file (JSON array)")
+	baseURI := fs.String("base", "", "base URI for ids in the EPCIS document")
+	outFile := fs.String("out", "-", "output file, - for stdout")
+	skipUntimed := fs.Bool("skip-untimed", false, "skip records without a time instead of failing")
+	fs.Parse(args)
+
+	if *baseURI == "" {
+		return fmt.Errorf("-base is required")
+	}
+
+	records, err := readRecords(*provenanceFile, *recordsFile)
+	if err != nil {
+		return err
+	}
+
+	// 没有时间的记录无法生成确定的事件id, 默认导出失败
+	if *skipUntimed {
+		timed := make([]*epcis.Record, 0, len(records))
+		for _, record := range records {
+			if !epcis.HasTime(record) {
+				fmt.Fprintf(os.Stderr, "skip %s %s: no time\n", record.Kind, record.Asset.Id)
+				continue
+			}
+			timed = append(timed, record)
+		}
+		records = timed
+	}
+
+	doc, err := epcis.NewExporter(*baseURI).Export(records)
+	if err != nil {
+		return err
+	}
+	if err := epcis.Validate(doc); err != nil {
+		return fmt.Errorf("invalid EPCIS document: %s", err)
+	}
+
+	docBytes, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if *outFile == "-" {
+		_, err = os.Stdout.Write(append(docBytes, '\n'))
+		return err
+	}
+
+	return ioutil.WriteFile(*outFile, docBytes, 0644)
+}
+
+// 导入报告
+type importReport struct {
+	Plan    *epcis.Plan                `json:"plan"`
+	Results []*epcis.Result            `json:"results"`
+	State   map[string]json.RawMessage `json:"state,omitempty"`
+}
+
+type prefixes []string
+
+func (p *prefixes) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *prefixes) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+func importEvents(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	inFile := fs.String("in", "", "EPCIS XML or JSON document")
+	dryRun := fs.Bool("dry-run", false, "plan against the network but apply to an in-process simulator and print the resulting state")
+	stopOnError := fs.Bool("stop-on-error", true, "stop at the first failed invocation")
+	profile := fs.String("connection-profile", "", "fabric-sdk-go connection profile, without it the peer binary is used (development only)")
+	peerBin := fs.String("peer", "peer", "peer binary, only without -connection-profile")
+	channel := fs.String("channel", "assetschannel", "channel name")
+	chaincode := fs.String("chaincode", "assets", "chaincode name")
+	orderer := fs.String("orderer", "", "orderer address, only without -connection-profile")
+	mspID := fs.String("msp-id", os.Getenv("CORE_PEER_LOCALMSPID"), "MSP id of the caller")
+	mspPath := fs.String("msp-path", os.Getenv("CORE_PEER_MSPCONFIGPATH"), "MSP directory of the caller")
+	var ingredientPrefixes, foodPrefixes prefixes
+	fs.Var(&ingredientPrefixes, "ingredient-prefix", "EPC prefix mapped to ingredients, repeatable")
+	fs.Var(&foodPrefixes, "food-prefix", "EPC prefix mapped to foods, repeatable")
+	fs.Parse(args)
+
+	if *inFile == "" {
+		return fmt.Errorf("-in is required")
+	}
+
+	data, err := ioutil.ReadFile(*inFile)
+	if err != nil {
+		return err
+	}
+	doc, err := epcis.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	// 计划总是按网络上已有的用户和资产生成
+	identities := map[string]*gateway.Identity{"importer": {MSPID: *mspID, MSPConfigPath: *mspPath}}
+	ledger := &epcis.BackendInvoker{Identity: "importer"}
+	if *profile != "" {
+		sdkBackend, err := gateway.NewSDKBackend(*profile, *channel, *chaincode, identities)
+		if err != nil {
+			return err
+		}
+		defer sdkBackend.Close()
+		ledger.Backend = sdkBackend
+	} else {
+		ledger.Backend = &gateway.PeerBackend{
+			PeerBin:    *peerBin,
+			Channel:    *channel,
+			Chaincode:  *chaincode,
+			Orderer:    *orderer,
+			Identities: identities,
+		}
+	}
+	recorder := epcis.NewLedgerRecorder(ledger)
+
+	importer := epcis.NewImporter()
+	importer.IngredientPrefixes = ingredientPrefixes
+	importer.FoodPrefixes = foodPrefixes
+	importer.Ledger = recorder
+	plan, err := importer.Plan(doc)
+	if err != nil {
+		return err
+	}
+
+	report := &importReport{Plan: plan}
+	if *dryRun {
+		// 演练时模拟账本使用网络上的配置和计划查询到的文档, 写入只留在模拟账本中
+		config, err := ledger.Query("queryConfig", []string{})
+		if err != nil {
+			return fmt.Errorf("query config error: %s", err)
+		}
+		mock, err := epcis.NewMockInvoker(*mspID, string(config))
+		if err != nil {
+			return err
+		}
+		mock.Seed(recorder.States)
+		report.Results = epcis.Apply(mock, plan, *stopOnError)
+		report.State = mock.State()
+	} else {
+		report.Results = epcis.Apply(ledger, plan, *stopOnError)
+	}
+
+	reportBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(reportBytes))
+
+	for _, result := range report.Results {
+		if result.Error != "" {
+			return fmt.Errorf("import failed")
+		}
+	}
+
+	return nil
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: foodepcis export|import [flags]")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = export(os.Args[2:])
+	case "import":
+		err = importEvents(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,174 @@
+// foodgateway 把链码函数以 REST API 的形式对外提供, 取代 app/app.js。
+//
+//	foodgateway -identities identities.json -connection-profile connection.yaml -tls-cert server.crt -tls-key server.key -client-ca clients.crt
+//	curl --cacert server.crt -H "Authorization: Bearer $TOKEN" https://localhost:8080/foods/food1/provenance
+//
+// 网关通过 fabric-sdk-go 与 peer 和 orderer 保持长连接, connection.yaml 为 SDK 的连接配置。
+// identities.json 为身份名到 MSP 和认证凭证的映射, 请求以 bearer token 或 mTLS 客户端证书对应的身份调用链码,
+// 身份的签名证书和私钥取自 msp_path:
+//
+//	{"org1admin": {"msp_id": "Org1MSP", "msp_path": "/etc/hyperledger/users/Admin@org1/msp", "token_sha256": "<printf %s $TOKEN | sha256sum>"},
+//	 "scanner": {"msp_id": "Org1MSP", "msp_path": "/etc/hyperledger/users/User1@org1/msp", "client_cn": "scanner.org1"}}
+//
+// 加上 -mock 时在进程内的模拟账本上执行链码, 不连接区块链网络, 每个MSP
+// 生成一个测试CA, 身份证书带 attrs 属性, 用于测试和演示, 此时可以只用 X-Fabric-Identity 头选择身份。
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+)
+
+func readIdentities(identitiesFile string) (map[string]*gateway.Identity, error) {
+	data, err := ioutil.ReadFile(identitiesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	identities := make(map[string]*gateway.Identity)
+	if err := json.Unmarshal(data, &identities); err != nil {
+		return nil, fmt.Errorf("unmarshal identities error: %s", err)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no identities in %s", identitiesFile)
+	}
+
+	return identities, nil
+}
+
+func newMockBackend(identities map[string]*gateway.Identity, configFile string) (gateway.Backend, error) {
+	config := ""
+	if configFile != "" {
+		data, err := ioutil.ReadFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		config = string(data)
+	}
+
+	backend, err := gateway.NewMockBackend(config)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(identities))
+	for name := range identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := backend.AddIdentity(name, identities[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	return backend, nil
+}
+
+func run() error {
+	listen := flag.String("listen", ":8080", "listen address")
+	identitiesFile := flag.String("identities", "identities.json", "identity name to MSP mapping file")
+	mock := flag.Bool("mock", false, "run the chaincode in-process on a mock stub")
+	configFile := flag.String("config", "", "chaincode config passed to Init, only with -mock")
+	profile := flag.String("connection-profile", "", "fabric-sdk-go connection profile, required without -mock")
+	channel := flag.String("channel", "assetschannel", "channel name")
+	chaincode := flag.String("chaincode", "assets", "chaincode name")
+	tlsCert := flag.String("tls-cert", "", "server TLS certificate")
+	tlsKey := flag.String("tls-key", "", "server TLS key")
+	clientCA := flag.String("client-ca", "", "CA certificates that sign mTLS client certificates")
+	flag.Parse()
+
+	identities, err := readIdentities(*identitiesFile)
+	if err != nil {
+		return err
+	}
+
+	var backend gateway.Backend
+	var auth gateway.Authenticator
+	if *mock {
+		backend, err = newMockBackend(identities, *configFile)
+		if err != nil {
+			return err
+		}
+		auth = gateway.HeaderAuth{}
+	} else {
+		credentials, err := gateway.NewCredentialAuth(identities)
+		if err != nil {
+			return err
+		}
+		if credentials.UsesClientCerts() && *clientCA == "" {
+			return fmt.Errorf("client_cn credentials require -client-ca")
+		}
+		auth = credentials
+		if *profile == "" {
+			return fmt.Errorf("-connection-profile is required without -mock")
+		}
+		sdkBackend, err := gateway.NewSDKBackend(*profile, *channel, *chaincode, identities)
+		if err != nil {
+			return err
+		}
+		defer sdkBackend.Close()
+		backend = sdkBackend
+	}
+
+	server := gateway.NewServer(backend, auth)
+	server.Logger = log.New(os.Stderr, "", log.LstdFlags)
+
+	if *tlsCert == "" {
+		if *clientCA != "" {
+			return fmt.Errorf("-client-ca requires -tls-cert and -tls-key")
+		}
+		if !*mock {
+			server.Logger.Printf("warning: serving without TLS, bearer tokens are sent in clear text")
+		}
+		server.Logger.Printf("listening on %s", *listen)
+		return http.ListenAndServe(*listen, server)
+	}
+
+	tlsConfig, err := newTLSConfig(*clientCA)
+	if err != nil {
+		return err
+	}
+	httpServer := &http.Server{Addr: *listen, Handler: server, TLSConfig: tlsConfig}
+	server.Logger.Printf("listening on %s (TLS)", *listen)
+
+	return httpServer.ListenAndServeTLS(*tlsCert, *tlsKey)
+}
+
+// 指定客户端CA时校验客户端证书, 没有证书的请求仍可以用 bearer token 认证
+func newTLSConfig(clientCA string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if clientCA == "" {
+		return config, nil
+	}
+
+	pem, err := ioutil.ReadFile(clientCA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", clientCA)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+
+	return config, nil
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,231 @@
+// foodlabel 为已登记的食品批量生成 GS1 Digital Link 二维码标签。
+//
+//	foodlabel generate -ids ids.txt -resolver https://id.example.com -gtin 09506000134352 -out labels
+//
+// 食品信息通过 queryFood 获取: 默认调用本机的 peer 命令, 也可以用 -foods
+// 指定事先导出的 queryFood 结果文件, 在完全离线的环境下生成标签。
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Blockchain-book/Fabric-Food/label"
+)
+
+// 食品来源
+type foodSource interface {
+	queryFood(foodId string) (*label.Food, error)
+}
+
+// 通过 peer chaincode query 调用 queryFood
+type peerSource struct {
+	peerBin   string
+	channel   string
+	chaincode string
+}
+
+func (s *peerSource) queryFood(foodId string) (*label.Food, error) {
+	args, err := json.Marshal(map[string][]string{"Args": {"queryFood", foodId}})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := exec.Command(s.peerBin, "chaincode", "query",
+		"-C", s.channel, "-n", s.chaincode, "-c", string(args)).Output()
+	if err != nil {
+		return nil, fmt.Errorf("queryFood %s error: %s", foodId, err)
+	}
+
+	return label.ParseFood(out)
+}
+
+// 事先导出的 queryFood 结果, JSON数组
+type fileSource struct {
+	foods map[string]*label.Food
+}
+
+func newFileSource(path string) (*fileSource, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*label.Food, 0)
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("unmarshal foods error: %s", err)
+	}
+
+	source := &fileSource{foods: make(map[string]*label.Food)}
+	for _, food := range list {
+		source.foods[food.Id] = food
+	}
+
+	return source, nil
+}
+
+func (s *fileSource) queryFood(foodId string) (*label.Food, error) {
+	food, ok := s.foods[foodId]
+	if !ok {
+		return nil, fmt.Errorf("food not found: %s", foodId)
+	}
+
+	return food, nil
+}
+
+// 读取食品id列表, 每行一个, 忽略空行和#注释
+func readIds(r io.Reader) ([]string, error) {
+	ids := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		id := strings.TrimSpace(scanner.Text())
+		if id == "" || strings.HasPrefix(id, "#") {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, scanner.Err()
+}
+
+// 标签文件名, 食品id含有路径分隔符时拒绝, 防止写到输出目录之外
+func labelFileName(id, format string) (string, error) {
+	if id == "." || id == ".." || strings.ContainsAny(id, `/\`) || strings.ContainsRune(id, 0) {
+		return "", fmt.Errorf("food id cannot be used as a file name")
+	}
+
+	return fmt.Sprintf("%s.%s", id, format), nil
+}
+
+func generate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	idsFile := fs.String("ids", "-", "food id list file, one id per line, - for stdin")
+	resolver := fs.String("resolver", "", "GS1 Digital Link resolver base url")
+	gtin := fs.String("gtin", "", "default GTIN for foods without gtin in metadata")
+	format := fs.String("format", label.FormatPNG, "image format: png or svg")
+	size := fs.Int("size", 256, "png image size in pixels")
+	outDir := fs.String("out", "labels", "output directory")
+	foodsFile := fs.String("foods", "", "exported queryFood results (JSON array), skips peer queries")
+	peerBin := fs.String("peer", "peer", "peer binary")
+	channel := fs.String("channel", "assetschannel", "channel name")
+	chaincode := fs.String("chaincode", "assets", "chaincode name")
+	fs.Parse(args)
+
+	if *resolver == "" {
+		return fmt.Errorf("-resolver is required")
+	}
+
+	var source foodSource = &peerSource{peerBin: *peerBin, channel: *channel, chaincode: *chaincode}
+	if *foodsFile != "" {
+		s, err := newFileSource(*foodsFile)
+		if err != nil {
+			return err
+		}
+		source = s
+	}
+
+	in := os.Stdin
+	if *idsFile != "-" {
+		f, err := os.Open(*idsFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	ids, err := readIds(in)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		return err
+	}
+	manifest, err := os.Create(filepath.Join(*outDir, "labels.csv"))
+	if err != nil {
+		return err
+	}
+	defer manifest.Close()
+	w := csv.NewWriter(manifest)
+	w.Write([]string{"food_id", "name", "digital_link", "file"})
+
+	failed := 0
+	for _, id := range ids {
+		food, err := source.queryFood(id)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+			continue
+		}
+
+		link, err := label.FoodDigitalLink(*resolver, food, *gtin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "food %s: %s\n", id, err)
+			failed++
+			continue
+		}
+
+		image, err := label.Image(link, *format, *size)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "food %s: %s\n", id, err)
+			failed++
+			continue
+		}
+
+		name, err := labelFileName(id, *format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "food %s: %s\n", id, err)
+			failed++
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(*outDir, name), image, 0644); err != nil {
+			return err
+		}
+		w.Write([]string{food.Id, food.Name, link, name})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	fmt.Printf("generated %d labels, %d failed\n", len(ids)-failed, failed)
+	if failed != 0 {
+		return fmt.Errorf("%d labels failed", failed)
+	}
+
+	return nil
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: foodlabel generate [flags]")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "generate":
+		err = generate(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestLabelFileName(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected string
+	}{
+		{"food1", "food1.png"},
+		{"food.1", "food.1.png"},
+		{"..food", "..food.png"},
+		{".", ""},
+		{"..", ""},
+		{"../food1", ""},
+		{"a/b", ""},
+		{`a\b`, ""},
+		{"food\x001", ""},
+	}
+
+	for _, tt := range tests {
+		name, err := labelFileName(tt.id, "png")
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("%q: expected error, got %q", tt.id, name)
+			}
+			continue
+		}
+		if err != nil || name != tt.expected {
+			t.Errorf("%q: expected %q, got %q %v", tt.id, tt.expected, name, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// foodsim 在进程内的模拟账本上执行 YAML 场景, 不需要 Docker 和 peer 节点。
+//
+//	foodsim scenario.yaml
+//	foodsim -state state.json -o json scenario.yaml
+//
+// 每一步输出 PASS 或 FAIL, 有步骤不符合期望时退出码为1。
+// 指定 -state 时在状态文件上继续执行并保存结果, 文件不存在时按场景的配置新建。
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Blockchain-book/Fabric-Food/simulator"
+)
+
+func writeReport(report *simulator.Report) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, step := range report.Steps {
+		status := "PASS"
+		if !step.Passed {
+			status = "FAIL"
+		}
+
+		action := step.Function
+		if action == "" {
+			action = "advance"
+		}
+		result := step.Code
+		switch {
+		case result != "":
+		case step.Error != "":
+			result = "ERROR"
+		default:
+			result = "OK"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", status, step.Step, step.Timestamp.Format(time.RFC3339), step.TxId, action, step.Name, result, step.Failure)
+	}
+	w.Flush()
+}
+
+func run() (bool, error) {
+	stateFile := flag.String("state", "", "mock ledger state file, created from the scenario config when missing")
+	output := flag.String("o", "text", "report format: text|json")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		return false, fmt.Errorf("usage: foodsim [-state state.json] [-o text|json] scenario.yaml")
+	}
+
+	scenario, err := simulator.LoadScenario(flag.Arg(0))
+	if err != nil {
+		return false, err
+	}
+
+	var sim *simulator.Simulator
+	if *stateFile != "" {
+		sim, err = simulator.Load(*stateFile)
+		if os.IsNotExist(err) {
+			sim, err = scenario.NewSimulator()
+		}
+	} else {
+		sim, err = scenario.NewSimulator()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	report, err := sim.Run(scenario)
+	if err != nil {
+		return false, err
+	}
+
+	if *stateFile != "" {
+		if err := sim.Save(*stateFile); err != nil {
+			return false, fmt.Errorf("save %s error: %s", *stateFile, err)
+		}
+	}
+
+	if *output == "json" {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return false, err
+		}
+		fmt.Println(string(out))
+	} else {
+		writeReport(report)
+	}
+
+	return report.Passed, nil
+}
+
+func main() {
+	passed, err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if !passed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,82 @@
+// Package epcis 在食品链码数据和 GS1 EPCIS 2.0 JSON-LD 文档之间转换。
+//
+// 登记对应 ObjectEvent ADD, 转让对应 bizStep 为 shipping/receiving 的
+// ObjectEvent, ingredientExchangeFood 等组合操作对应 TransformationEvent。
+package epcis
+
+const (
+	Context       = "https://ref.gs1.org/standards/epcis/2.0.0/epcis-context.jsonld"
+	SchemaVersion = "2.0"
+	DocumentType  = "EPCISDocument"
+
+	ObjectEventType         = "ObjectEvent"
+	TransformationEventType = "TransformationEvent"
+
+	ActionAdd     = "ADD"
+	ActionObserve = "OBSERVE"
+	ActionDelete  = "DELETE"
+
+	BizStepCommissioning = "commissioning"
+	BizStepShipping      = "shipping"
+	BizStepReceiving     = "receiving"
+	BizStepAssembling    = "assembling"
+	BizStepOther         = "other"
+
+	DispositionActive     = "active"
+	DispositionInTransit  = "in_transit"
+	DispositionInProgress = "in_progress"
+
+	SourceTypeOwningParty = "owning_party"
+)
+
+// EPCIS 文档
+type Document struct {
+	Context       []string `json:"@context"`
+	Type          string   `json:"type"`
+	SchemaVersion string   `json:"schemaVersion"`
+	CreationDate  string   `json:"creationDate"`
+	Body          Body     `json:"epcisBody"`
+}
+
+type Body struct {
+	EventList []*Event `json:"eventList"`
+}
+
+// EPCIS 事件, 不同事件类型使用的字段不同, 未使用的字段不输出
+type Event struct {
+	Type                string            `json:"type"`
+	EventID             string            `json:"eventID,omitempty"`
+	EventTime           string            `json:"eventTime"`
+	EventTimeZoneOffset string            `json:"eventTimeZoneOffset"`
+	EPCList             []string          `json:"epcList,omitempty"`
+	InputEPCList        []string          `json:"inputEPCList,omitempty"`
+	OutputEPCList       []string          `json:"outputEPCList,omitempty"`
+	TransformationID    string            `json:"transformationID,omitempty"`
+	Action              string            `json:"action,omitempty"`
+	BizStep             string            `json:"bizStep,omitempty"`
+	Disposition         string            `json:"disposition,omitempty"`
+	ReadPoint           *Location         `json:"readPoint,omitempty"`
+	BizLocation         *Location         `json:"bizLocation,omitempty"`
+	BizTransactionList  []*BizTransaction `json:"bizTransactionList,omitempty"`
+	SourceList          []*Source         `json:"sourceList,omitempty"`
+	DestinationList     []*Destination    `json:"destinationList,omitempty"`
+}
+
+type Location struct {
+	Id string `json:"id"`
+}
+
+type BizTransaction struct {
+	Type           string `json:"type,omitempty"`
+	BizTransaction string `json:"bizTransaction"`
+}
+
+type Source struct {
+	Type   string `json:"type"`
+	Source string `json:"source"`
+}
+
+type Destination struct {
+	Type        string `json:"type"`
+	Destination string `json:"destination"`
+}
@@ -0,0 +1,204 @@
+package epcis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	timeZoneOffset = "+00:00"
+)
+
+// 记录没有交易时间, 无法生成确定的事件
+var ErrNoEventTime = errors.New("record has no time")
+
+// CBV 中定义的业务步骤, 加工步骤类型不在其中时使用扩展URI
+var cbvBizSteps = map[string]bool{
+	"accepting": true, "arriving": true, "assembling": true, "commissioning": true,
+	"decommissioning": true, "destroying": true, "disassembling": true, "holding": true,
+	"inspecting": true, "loading": true, "packing": true, "picking": true,
+	"receiving": true, "repackaging": true, "retail_selling": true, "sampling": true,
+	"shipping": true, "storing": true, "transporting": true, "unloading": true,
+	"unpacking": true, "other": true,
+}
+
+// 导出器, 链码中的id通过 BaseURI 转换为EPCIS使用的URI
+type Exporter struct {
+	BaseURI string
+	// 文档的创建时间, 默认为当前时间; 事件时间只取自记录
+	Now func() time.Time
+}
+
+func NewExporter(baseURI string) *Exporter {
+	return &Exporter{
+		BaseURI: strings.TrimRight(baseURI, "/"),
+		Now:     time.Now,
+	}
+}
+
+func (e *Exporter) uri(kind, id string) string {
+	return fmt.Sprintf("%s/%s/%s", e.BaseURI, kind, url.PathEscape(id))
+}
+
+// 资产EPC
+func (e *Exporter) EPC(ref *AssetRef) string {
+	return e.uri(ref.Type, ref.Id)
+}
+
+// 用户(拥有方)
+func (e *Exporter) Party(userId string) string {
+	return e.uri("user", userId)
+}
+
+// 设施
+func (e *Exporter) Location(facilityId string) string {
+	return e.uri("facility", facilityId)
+}
+
+func (e *Exporter) BizStep(stepType string) string {
+	if cbvBizSteps[stepType] {
+		return stepType
+	}
+
+	return e.uri("bizstep", stepType)
+}
+
+// 记录是否带有可用的交易时间
+func HasTime(record *Record) bool {
+	_, err := eventTime(record)
+	return err == nil
+}
+
+// 事件时间取自记录的交易时间, 同一记录每次导出的事件id相同
+func eventTime(record *Record) (string, error) {
+	if record.Time == "" {
+		return "", ErrNoEventTime
+	}
+	t, err := time.Parse(time.RFC3339, record.Time)
+	if err != nil {
+		return "", fmt.Errorf("invalid record time: %s", record.Time)
+	}
+
+	return t.UTC().Format(time.RFC3339), nil
+}
+
+func (e *Exporter) epcList(refs []*AssetRef) []string {
+	epcs := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		epcs = append(epcs, e.EPC(ref))
+	}
+
+	return epcs
+}
+
+// 把一条供应链记录转换为EPCIS事件, 记录没有时间时返回 ErrNoEventTime
+func (e *Exporter) Events(record *Record) ([]*Event, error) {
+	t, err := eventTime(record)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %s", record.Kind, record.Asset.Id, err)
+	}
+
+	base := Event{
+		EventTime:           t,
+		EventTimeZoneOffset: timeZoneOffset,
+	}
+	if record.Location != "" {
+		base.BizLocation = &Location{Id: e.Location(record.Location)}
+	}
+	if record.TxId != "" {
+		base.BizTransactionList = []*BizTransaction{{BizTransaction: e.uri("tx", record.TxId)}}
+	}
+
+	events := make([]*Event, 0)
+	switch record.Kind {
+	case RecordEnroll:
+		event := base
+		event.Type = ObjectEventType
+		event.Action = ActionAdd
+		event.BizStep = BizStepCommissioning
+		event.Disposition = DispositionActive
+		event.EPCList = []string{e.EPC(&record.Asset)}
+		event.DestinationList = []*Destination{{Type: SourceTypeOwningParty, Destination: e.Party(record.To)}}
+		events = append(events, &event)
+	case RecordExchange:
+		source := []*Source{{Type: SourceTypeOwningParty, Source: e.Party(record.From)}}
+		destination := []*Destination{{Type: SourceTypeOwningParty, Destination: e.Party(record.To)}}
+
+		shipping := base
+		shipping.Type = ObjectEventType
+		shipping.Action = ActionObserve
+		shipping.BizStep = BizStepShipping
+		shipping.Disposition = DispositionInTransit
+		shipping.EPCList = []string{e.EPC(&record.Asset)}
+		shipping.SourceList = source
+		shipping.DestinationList = destination
+
+		receiving := shipping
+		receiving.BizStep = BizStepReceiving
+		receiving.Disposition = DispositionActive
+		events = append(events, &shipping, &receiving)
+	case RecordCombine, RecordProcess:
+		event := base
+		event.Type = TransformationEventType
+		event.InputEPCList = e.epcList(record.Inputs)
+		event.OutputEPCList = e.epcList(record.Outputs)
+		event.BizStep = BizStepAssembling
+		event.Disposition = DispositionInProgress
+		if record.Kind == RecordProcess {
+			event.BizStep = e.BizStep(record.BizStep)
+			event.TransformationID = e.uri("transformation", record.TxId)
+		}
+		events = append(events, &event)
+	default:
+		return nil, fmt.Errorf("unsupported record kind: %s", record.Kind)
+	}
+
+	for _, event := range events {
+		id, err := eventID(event)
+		if err != nil {
+			return nil, err
+		}
+		event.EventID = id
+	}
+
+	return events, nil
+}
+
+// 根据事件内容生成确定的事件id, 重复导出时id保持不变
+func eventID(event *Event) (string, error) {
+	event.EventID = ""
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(eventBytes)
+
+	return fmt.Sprintf("ni:///sha-256;%s?ver=CBV2.0", hex.EncodeToString(digest[:])), nil
+}
+
+// 导出EPCIS文档
+func (e *Exporter) Export(records []*Record) (*Document, error) {
+	doc := &Document{
+		Context:       []string{Context},
+		Type:          DocumentType,
+		SchemaVersion: SchemaVersion,
+		CreationDate:  e.Now().UTC().Format(time.RFC3339),
+		Body:          Body{EventList: make([]*Event, 0)},
+	}
+
+	for _, record := range records {
+		events, err := e.Events(record)
+		if err != nil {
+			return nil, err
+		}
+		doc.Body.EventList = append(doc.Body.EventList, events...)
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,217 @@
+package epcis
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const (
+	// GS1 发布的 EPCIS 2.0 JSON schema, 存在时一并校验
+	officialSchemaFile = "testdata/epcis-json-schema.json"
+	// 本仓库手写的子集, 只覆盖导出器输出的字段
+	subsetSchemaFile = "testdata/epcis-json-schema-subset.json"
+)
+
+func testRecords() []*Record {
+	return []*Record{
+		{Kind: RecordEnroll, Asset: AssetRef{Type: AssetIngredient, Id: "ingredient1"}, To: "user1", Time: "2024-01-01T08:00:00Z"},
+		{Kind: RecordExchange, Asset: AssetRef{Type: AssetIngredient, Id: "ingredient1"}, From: "user1", To: "user2", Location: "facility1", Time: "2024-01-01T09:00:00Z"},
+		{
+			Kind:    RecordCombine,
+			Asset:   AssetRef{Type: AssetFood, Id: "food1"},
+			From:    "user2",
+			Inputs:  []*AssetRef{{Type: AssetIngredient, Id: "ingredient1"}},
+			Outputs: []*AssetRef{{Type: AssetFood, Id: "food1"}},
+			Time:    "2024-01-01T10:00:00+08:00",
+		},
+		{
+			Kind:     RecordProcess,
+			Asset:    AssetRef{Type: AssetFood, Id: "food2"},
+			From:     "user2",
+			Inputs:   []*AssetRef{{Type: AssetFood, Id: "food1"}},
+			Outputs:  []*AssetRef{{Type: AssetFood, Id: "food2"}},
+			BizStep:  "baking",
+			Location: "facility2",
+			Time:     "2024-01-01T11:00:00Z",
+			TxId:     "step1",
+		},
+	}
+}
+
+func exportAt(t *testing.T, now time.Time, records []*Record) *Document {
+	e := NewExporter("https://id.example.com")
+	e.Now = func() time.Time { return now }
+
+	doc, err := e.Export(records)
+	if err != nil {
+		t.Fatalf("export error: %s", err)
+	}
+
+	return doc
+}
+
+func schemaFiles(t *testing.T) []string {
+	files := []string{subsetSchemaFile}
+	if _, err := os.Stat(officialSchemaFile); err == nil {
+		files = append(files, officialSchemaFile)
+	} else {
+		t.Logf("%s not found, validating against %s only", officialSchemaFile, subsetSchemaFile)
+	}
+
+	return files
+}
+
+func TestExportValidatesAgainstSchema(t *testing.T) {
+	doc := exportAt(t, time.Now(), testRecords())
+	if err := Validate(doc); err != nil {
+		t.Fatalf("validate error: %s", err)
+	}
+	docBytes, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range schemaFiles(t) {
+		compiler := jsonschema.NewCompiler()
+		compiler.Draft = jsonschema.Draft7
+		compiler.AssertFormat = true
+		schema, err := compiler.Compile(file)
+		if err != nil {
+			t.Fatalf("compile %s error: %s", file, err)
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(docBytes, &v); err != nil {
+			t.Fatal(err)
+		}
+		if err := schema.Validate(v); err != nil {
+			t.Fatalf("document does not match %s: %#v", file, err)
+		}
+
+		// 没有事件时间的事件应当被schema拒绝
+		events := v.(map[string]interface{})["epcisBody"].(map[string]interface{})["eventList"].([]interface{})
+		delete(events[0].(map[string]interface{}), "eventTime")
+		if err := schema.Validate(v); err == nil {
+			t.Fatalf("expected %s error for event without eventTime", file)
+		}
+	}
+}
+
+func TestValidateTimeZoneOffset(t *testing.T) {
+	tests := []struct {
+		offset string
+		valid  bool
+	}{
+		{"+00:00", true},
+		{"-05:30", true},
+		{"+13:59", true},
+		{"+14:00", true},
+		{"-14:00", true},
+		{"+14:01", false},
+		{"-15:00", false},
+		{"+23:59", false},
+		{"+08:60", false},
+		{"08:00", false},
+	}
+
+	for _, tt := range tests {
+		doc := exportAt(t, time.Now(), testRecords()[:1])
+		doc.Body.EventList[0].EventTimeZoneOffset = tt.offset
+		err := Validate(doc)
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.offset, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: expected invalid eventTimeZoneOffset", tt.offset)
+		}
+	}
+}
+
+func TestExportEventIDsStable(t *testing.T) {
+	first := exportAt(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), testRecords())
+	second := exportAt(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), testRecords())
+
+	if len(first.Body.EventList) != len(second.Body.EventList) {
+		t.Fatalf("expected %d events, got %d", len(first.Body.EventList), len(second.Body.EventList))
+	}
+	for i, event := range first.Body.EventList {
+		again := second.Body.EventList[i]
+		if event.EventID != again.EventID {
+			t.Errorf("event %d: eventID changed between exports: %s, %s", i, event.EventID, again.EventID)
+		}
+		if event.EventTime != again.EventTime {
+			t.Errorf("event %d: eventTime changed between exports: %s, %s", i, event.EventTime, again.EventTime)
+		}
+	}
+
+	if got := first.Body.EventList[3].EventTime; got != "2024-01-01T02:00:00Z" {
+		t.Errorf("expected eventTime in UTC, got %s", got)
+	}
+}
+
+func TestExportRecordWithoutTime(t *testing.T) {
+	tests := []struct {
+		name string
+		time string
+	}{
+		{"missing", ""},
+		{"invalid", "yesterday"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := &Record{Kind: RecordEnroll, Asset: AssetRef{Type: AssetFood, Id: "food1"}, To: "user1", Time: tt.time}
+			if HasTime(record) {
+				t.Fatalf("expected record without usable time")
+			}
+			if _, err := NewExporter("https://id.example.com").Export([]*Record{record}); err == nil {
+				t.Fatalf("expected export error")
+			}
+		})
+	}
+}
+
+func TestFromProvenanceTimes(t *testing.T) {
+	provenance := []byte(`{
+		"food": {"id": "food1", "name": "bread", "enrolled_at": "2024-01-01T07:00:00Z"},
+		"history": [
+			{"food_id": "food1", "origin_owner_id": "originPlaceholder", "current_owner_id": "user1"},
+			{"food_id": "food1", "origin_owner_id": "user1", "current_owner_id": "user2", "facility_id": "facility1", "timestamp": "2024-01-02T07:00:00Z"}
+		],
+		"ingredients": [
+			{"ingredient": {"id": "ingredient1"}, "history": [
+				{"ingredient_id": "ingredient1", "origin_owner_id": "originPlaceholder", "current_owner_id": "user1", "timestamp": "2024-01-01T06:00:00Z"},
+				{"ingredient_id": "ingredient1", "origin_owner_id": "user1", "current_owner_id": "food1", "timestamp": "2024-01-01T06:30:00Z"}
+			]}
+		]
+	}`)
+	p, err := ParseProvenance(provenance)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		kind     string
+		time     string
+		location string
+	}{
+		{RecordEnroll, "2024-01-01T06:00:00Z", ""},
+		{RecordCombine, "2024-01-01T06:30:00Z", ""},
+		{RecordEnroll, "2024-01-01T07:00:00Z", ""},
+		{RecordExchange, "2024-01-02T07:00:00Z", "facility1"},
+	}
+	records := FromProvenance(p)
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), len(records))
+	}
+	for i, e := range expected {
+		r := records[i]
+		if r.Kind != e.kind || r.Time != e.time || r.Location != e.location {
+			t.Errorf("record %d: expected %s at %s in %q, got %s at %s in %q", i, e.kind, e.time, e.location, r.Kind, r.Time, r.Location)
+		}
+	}
+}
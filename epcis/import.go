@@ -0,0 +1,503 @@
+package epcis
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Blockchain-book/Fabric-Food/backend"
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+)
+
+// 一次链码调用
+type Invocation struct {
+	Function string   `json:"function"`
+	Args     []string `json:"args"`
+	EventID  string   `json:"event_id,omitempty"`
+}
+
+// 无法映射或被跳过的事件
+type Issue struct {
+	Index   int    `json:"index"`
+	EventID string `json:"event_id,omitempty"`
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+}
+
+// 导入计划
+type Plan struct {
+	Invocations []*Invocation `json:"invocations"`
+	Issues      []*Issue      `json:"issues"`
+	Duplicates  int           `json:"duplicates"`
+}
+
+// EPCIS导入器, 把事件映射为 userRegister、ingredientEnroll、foodEnroll
+// 以及各种转让调用
+type Importer struct {
+	// EPC到资产的映射规则, 按前缀匹配, 去掉前缀后作为资产id
+	IngredientPrefixes []string
+	FoodPrefixes       []string
+	// 生成计划前查询文档中出现的用户和资产, 已在账本中的不再注册或登记, 为空时视为空账本
+	Ledger Querier
+
+	users   map[string]bool
+	owners  map[string]string
+	history map[string][]*ledgerEdge
+	events  map[string]bool
+	plan    *Plan
+}
+
+// 账本中资产的一条转让记录
+type ledgerEdge struct {
+	From string `json:"origin_owner_id"`
+	To   string `json:"current_owner_id"`
+}
+
+func NewImporter() *Importer {
+	return &Importer{
+		IngredientPrefixes: make([]string, 0),
+		FoodPrefixes:       make([]string, 0),
+	}
+}
+
+// 识别EPC对应的资产, 默认识别导出器生成的 .../ingredient/{id} 和 .../food/{id}
+func (im *Importer) Classify(epc string) (*AssetRef, bool) {
+	for _, prefix := range im.IngredientPrefixes {
+		if strings.HasPrefix(epc, prefix) && len(epc) > len(prefix) {
+			return &AssetRef{Type: AssetIngredient, Id: strings.TrimPrefix(epc, prefix)}, true
+		}
+	}
+	for _, prefix := range im.FoodPrefixes {
+		if strings.HasPrefix(epc, prefix) && len(epc) > len(prefix) {
+			return &AssetRef{Type: AssetFood, Id: strings.TrimPrefix(epc, prefix)}, true
+		}
+	}
+
+	for _, kind := range []string{AssetIngredient, AssetFood} {
+		marker := "/" + kind + "/"
+		if i := strings.LastIndex(epc, marker); i >= 0 && i+len(marker) < len(epc) {
+			id, err := url.PathUnescape(epc[i+len(marker):])
+			if err != nil {
+				return nil, false
+			}
+			return &AssetRef{Type: kind, Id: id}, true
+		}
+	}
+
+	return nil, false
+}
+
+// URI或URN的最后一段
+func lastSegment(uri string) string {
+	uri = strings.TrimRight(uri, "/")
+	if i := strings.LastIndexAny(uri, "/:"); i >= 0 {
+		return uri[i+1:]
+	}
+
+	return uri
+}
+
+func partyId(uri string) string {
+	return lastSegment(uri)
+}
+
+func ownerKey(ref *AssetRef) string {
+	return ref.Type + "/" + ref.Id
+}
+
+// 生成导入计划, 除查询账本外不执行任何调用
+func (im *Importer) Plan(doc *Document) (*Plan, error) {
+	im.users = make(map[string]bool)
+	im.owners = make(map[string]string)
+	im.history = make(map[string][]*ledgerEdge)
+	im.events = make(map[string]bool)
+	im.plan = &Plan{
+		Invocations: make([]*Invocation, 0),
+		Issues:      make([]*Issue, 0),
+	}
+	if err := im.loadLedger(doc); err != nil {
+		return nil, err
+	}
+
+	for i, event := range doc.Body.EventList {
+		if event.EventID != "" {
+			if im.events[event.EventID] {
+				im.plan.Duplicates++
+				continue
+			}
+			im.events[event.EventID] = true
+		}
+
+		if reason := im.mapEvent(event); reason != "" {
+			im.plan.Issues = append(im.plan.Issues, &Issue{
+				Index:   i,
+				EventID: event.EventID,
+				Type:    event.Type,
+				Reason:  reason,
+			})
+		}
+	}
+
+	return im.plan, nil
+}
+
+// 文档中出现的拥有方和资产, 按出现顺序
+func (im *Importer) references(doc *Document) ([]string, []*AssetRef) {
+	parties := make([]string, 0)
+	assets := make([]*AssetRef, 0)
+	seen := make(map[string]bool)
+	for _, event := range doc.Body.EventList {
+		source, destination := owningParty(event)
+		for _, party := range []string{source, destination} {
+			if party != "" && !seen["user/"+party] {
+				seen["user/"+party] = true
+				parties = append(parties, party)
+			}
+		}
+
+		for _, epcs := range [][]string{event.EPCList, event.InputEPCList, event.OutputEPCList} {
+			for _, epc := range epcs {
+				ref, ok := im.Classify(epc)
+				if ok && !seen[ownerKey(ref)] {
+					seen[ownerKey(ref)] = true
+					assets = append(assets, ref)
+				}
+			}
+		}
+	}
+
+	return parties, assets
+}
+
+// 查询文档中出现的用户和资产在账本中的状态:
+// 已注册的用户记为已存在, 名下的资产以该用户为拥有者; 已登记的食品中合并的资产以该食品为拥有者;
+// 已登记但不属于上述拥有者的资产, 拥有者记为空。已登记资产的转让记录用于跳过已经执行过的转让
+func (im *Importer) loadLedger(doc *Document) error {
+	if im.Ledger == nil {
+		return nil
+	}
+
+	parties, assets := im.references(doc)
+	for _, party := range parties {
+		user := new(food.User)
+		found, err := im.query(user, "queryUser", party)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+
+		im.users[party] = true
+		for _, id := range user.Ingredients {
+			im.owners[ownerKey(&AssetRef{Type: AssetIngredient, Id: id})] = party
+		}
+		for _, id := range user.Foods {
+			im.owners[ownerKey(&AssetRef{Type: AssetFood, Id: id})] = party
+		}
+	}
+
+	enrolled := make([]*AssetRef, 0)
+	for _, ref := range assets {
+		function := "queryIngredient"
+		asset := interface{}(new(food.Ingredient))
+		if ref.Type == AssetFood {
+			function = "queryFood"
+			asset = new(food.Food)
+		}
+		found, err := im.query(asset, function, ref.Id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+
+		enrolled = append(enrolled, ref)
+		edges := make([]*ledgerEdge, 0)
+		if _, err := im.query(&edges, function+"History", ref.Id, "exchange"); err != nil {
+			return err
+		}
+		im.history[ownerKey(ref)] = edges
+
+		if f, ok := asset.(*food.Food); ok {
+			for _, id := range f.Ingredients {
+				im.owners[ownerKey(&AssetRef{Type: AssetIngredient, Id: id})] = ownerKey(ref)
+			}
+			for _, id := range f.Foods {
+				im.owners[ownerKey(&AssetRef{Type: AssetFood, Id: id})] = ownerKey(ref)
+			}
+		}
+	}
+	for _, ref := range enrolled {
+		if _, exist := im.owners[ownerKey(ref)]; !exist {
+			im.owners[ownerKey(ref)] = ""
+		}
+	}
+
+	return nil
+}
+
+// 查询账本并解析结果, 不存在时返回 false
+func (im *Importer) query(v interface{}, function string, args ...string) (bool, error) {
+	payload, err := im.Ledger.Query(function, args)
+	if ccErr, ok := err.(*backend.ChaincodeError); ok && ccErr.Detail != nil && ccErr.Detail.Code == food.CodeNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s %s error: %s", function, args[0], err)
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return false, fmt.Errorf("unmarshal %s %s error: %s", function, args[0], err)
+	}
+
+	return true, nil
+}
+
+// 账本中是否已有资产从 from 转给 to 的记录, from 为空时不限原拥有者
+func (im *Importer) recorded(ref *AssetRef, from, to string) bool {
+	for _, edge := range im.history[ownerKey(ref)] {
+		if edge.To == to && (from == "" || edge.From == from) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (im *Importer) invoke(event *Event, function string, args ...string) {
+	im.plan.Invocations = append(im.plan.Invocations, &Invocation{
+		Function: function,
+		Args:     args,
+		EventID:  event.EventID,
+	})
+}
+
+func (im *Importer) ensureUser(event *Event, userId string) {
+	if im.users[userId] {
+		return
+	}
+	im.users[userId] = true
+	im.invoke(event, "userRegister", userId, userId)
+}
+
+func owningParty(event *Event) (source, destination string) {
+	for _, s := range event.SourceList {
+		if s.Type == SourceTypeOwningParty {
+			source = partyId(s.Source)
+			break
+		}
+	}
+	for _, d := range event.DestinationList {
+		if d.Type == SourceTypeOwningParty {
+			destination = partyId(d.Destination)
+			break
+		}
+	}
+
+	return source, destination
+}
+
+// 登记时写入metadata的EPCIS来源信息
+func eventMetadata(event *Event, epc string) string {
+	metadata := map[string]string{
+		"epc":        epc,
+		"event_time": event.EventTime,
+	}
+	if event.EventID != "" {
+		metadata["event_id"] = event.EventID
+	}
+	if event.BizLocation != nil {
+		metadata["biz_location"] = event.BizLocation.Id
+	}
+	metadataBytes, _ := json.Marshal(metadata)
+
+	return string(metadataBytes)
+}
+
+// 映射单个事件, 返回无法映射的原因
+func (im *Importer) mapEvent(event *Event) string {
+	switch event.Type {
+	case ObjectEventType:
+		switch {
+		case event.Action == ActionAdd:
+			return im.mapEnroll(event)
+		case event.Action == ActionObserve:
+			return im.mapTransfer(event, event.EPCList)
+		default:
+			return fmt.Sprintf("unsupported ObjectEvent action: %s", event.Action)
+		}
+	case TransformationEventType:
+		return im.mapTransformation(event)
+	default:
+		return fmt.Sprintf("unsupported event type: %s", event.Type)
+	}
+}
+
+func (im *Importer) enroll(event *Event, ref *AssetRef, epc, ownerId string) {
+	im.ensureUser(event, ownerId)
+	function := "ingredientEnroll"
+	if ref.Type == AssetFood {
+		function = "foodEnroll"
+	}
+	im.invoke(event, function, ref.Id, ref.Id, eventMetadata(event, epc), ownerId)
+	im.owners[ownerKey(ref)] = ownerId
+}
+
+func (im *Importer) mapEnroll(event *Event) string {
+	source, destination := owningParty(event)
+	ownerId := destination
+	if ownerId == "" {
+		ownerId = source
+	}
+	if ownerId == "" {
+		return "no owning_party in sourceList or destinationList"
+	}
+	if len(event.EPCList) == 0 {
+		return "empty epcList"
+	}
+
+	unmapped := make([]string, 0)
+	for _, epc := range event.EPCList {
+		ref, ok := im.Classify(epc)
+		if !ok {
+			unmapped = append(unmapped, epc)
+			continue
+		}
+		if _, exist := im.owners[ownerKey(ref)]; exist {
+			im.plan.Duplicates++
+			continue
+		}
+		im.enroll(event, ref, epc, ownerId)
+	}
+
+	if len(unmapped) != 0 {
+		return fmt.Sprintf("unknown epc: %s", strings.Join(unmapped, ","))
+	}
+
+	return ""
+}
+
+func (im *Importer) mapTransfer(event *Event, epcs []string) string {
+	from, to := owningParty(event)
+	if from == "" || to == "" {
+		return "transfer requires owning_party source and destination"
+	}
+	if len(epcs) == 0 {
+		return "empty epcList"
+	}
+
+	reasons := make([]string, 0)
+	for _, epc := range epcs {
+		ref, ok := im.Classify(epc)
+		if !ok {
+			reasons = append(reasons, fmt.Sprintf("unknown epc %s", epc))
+			continue
+		}
+
+		owner, exist := im.owners[ownerKey(ref)]
+		switch {
+		case im.recorded(ref, from, to):
+			im.plan.Duplicates++
+			continue
+		case !exist:
+			reasons = append(reasons, fmt.Sprintf("%s %s not enrolled", ref.Type, ref.Id))
+			continue
+		case owner == "":
+			reasons = append(reasons, fmt.Sprintf("%s %s held by a party outside the document", ref.Type, ref.Id))
+			continue
+		case owner == to:
+			// shipping 之后的 receiving
+			im.plan.Duplicates++
+			continue
+		case owner != from:
+			reasons = append(reasons, fmt.Sprintf("%s %s owned by %s, not %s", ref.Type, ref.Id, owner, from))
+			continue
+		}
+
+		im.ensureUser(event, to)
+		function := "ingredientExchange"
+		if ref.Type == AssetFood {
+			function = "foodExchange"
+		}
+		im.invoke(event, function, from, ref.Id, to)
+		im.owners[ownerKey(ref)] = to
+	}
+
+	return strings.Join(reasons, "; ")
+}
+
+func (im *Importer) mapTransformation(event *Event) string {
+	if len(event.OutputEPCList) == 0 {
+		return "TransformationEvent without outputEPCList"
+	}
+
+	outputEPC := event.OutputEPCList[0]
+	output, ok := im.Classify(outputEPC)
+	if !ok || output.Type != AssetFood {
+		return fmt.Sprintf("output %s is not a food", outputEPC)
+	}
+
+	reasons := make([]string, 0)
+	if len(event.OutputEPCList) > 1 {
+		reasons = append(reasons, "multiple outputs, inputs combined into the first output only")
+	}
+
+	// 输出食品未登记时, 以输入的拥有者登记
+	if _, exist := im.owners[ownerKey(output)]; !exist {
+		source, destination := owningParty(event)
+		ownerId := destination
+		if ownerId == "" {
+			ownerId = source
+		}
+		for _, epc := range event.InputEPCList {
+			if ownerId != "" {
+				break
+			}
+			if ref, ok := im.Classify(epc); ok {
+				ownerId = im.owners[ownerKey(ref)]
+			}
+		}
+		if ownerId == "" {
+			return "cannot determine owner of output food"
+		}
+		im.enroll(event, output, outputEPC, ownerId)
+	}
+
+	for _, epc := range event.InputEPCList {
+		ref, ok := im.Classify(epc)
+		if !ok {
+			reasons = append(reasons, fmt.Sprintf("unknown epc %s", epc))
+			continue
+		}
+
+		owner, exist := im.owners[ownerKey(ref)]
+		switch {
+		case im.recorded(ref, "", output.Id):
+			im.plan.Duplicates++
+			continue
+		case !exist:
+			reasons = append(reasons, fmt.Sprintf("%s %s not enrolled", ref.Type, ref.Id))
+			continue
+		case owner == "":
+			reasons = append(reasons, fmt.Sprintf("%s %s held by a party outside the document", ref.Type, ref.Id))
+			continue
+		case owner == ownerKey(output):
+			im.plan.Duplicates++
+			continue
+		case !im.users[owner]:
+			reasons = append(reasons, fmt.Sprintf("%s %s already consumed", ref.Type, ref.Id))
+			continue
+		}
+
+		function := "ingredientExchangeFood"
+		if ref.Type == AssetFood {
+			function = "foodExchangeFood"
+		}
+		im.invoke(event, function, owner, ref.Id, output.Id)
+		im.owners[ownerKey(ref)] = ownerKey(output)
+	}
+
+	return strings.Join(reasons, "; ")
+}
@@ -0,0 +1,97 @@
+package epcis
+
+import (
+	"testing"
+	"time"
+)
+
+func planAndApply(t *testing.T, ledger Querier, invoker Invoker, doc *Document) *Plan {
+	importer := NewImporter()
+	importer.Ledger = ledger
+	plan, err := importer.Plan(doc)
+	if err != nil {
+		t.Fatalf("plan error: %s", err)
+	}
+	for _, result := range Apply(invoker, plan, true) {
+		if result.Error != "" {
+			t.Fatalf("%s %v: %s", result.Invocation.Function, result.Invocation.Args, result.Error)
+		}
+	}
+
+	return plan
+}
+
+func functions(plan *Plan) []string {
+	names := make([]string, 0, len(plan.Invocations))
+	for _, invocation := range plan.Invocations {
+		names = append(names, invocation.Function)
+	}
+
+	return names
+}
+
+// 已导入 testRecords 的账本
+func importedLedger(t *testing.T) *MockInvoker {
+	ledger, err := NewMockInvoker("Org1MSP", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := planAndApply(t, ledger, ledger, exportAt(t, time.Now(), testRecords()))
+	if len(plan.Invocations) == 0 || len(plan.Issues) != 0 {
+		t.Fatalf("expected invocations without issues, got %d invocations and %d issues", len(plan.Invocations), len(plan.Issues))
+	}
+
+	return ledger
+}
+
+func TestReimport(t *testing.T) {
+	ledger := importedLedger(t)
+
+	// 账本中已有的用户和资产不再注册或登记
+	plan := planAndApply(t, ledger, ledger, exportAt(t, time.Now(), testRecords()))
+	if len(plan.Invocations) != 0 || len(plan.Issues) != 0 {
+		t.Fatalf("expected an empty plan, got %d invocations and %d issues", len(plan.Invocations), len(plan.Issues))
+	}
+}
+
+// 演练按账本生成计划, 在写入了账本文档的模拟账本上执行, 不修改账本
+func TestDryRun(t *testing.T) {
+	ledger := importedLedger(t)
+
+	config, err := ledger.Query("queryConfig", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock, err := NewMockInvoker("Org1MSP", string(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 新的转让只注册新用户
+	records := append(testRecords(), &Record{Kind: RecordExchange, Asset: AssetRef{Type: AssetFood, Id: "food2"}, From: "user2", To: "user3", Time: "2024-01-01T12:00:00Z"})
+	recorder := NewLedgerRecorder(ledger)
+	importer := NewImporter()
+	importer.Ledger = recorder
+	plan, err := importer.Plan(exportAt(t, time.Now(), records))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := functions(plan)
+	if len(names) != 2 || names[0] != "userRegister" || names[1] != "foodExchange" {
+		t.Fatalf("expected userRegister and foodExchange, got %v", names)
+	}
+
+	mock.Seed(recorder.States)
+	for _, result := range Apply(mock, plan, true) {
+		if result.Error != "" {
+			t.Fatalf("%s %v: %s", result.Invocation.Function, result.Invocation.Args, result.Error)
+		}
+	}
+	if _, ok := mock.State()["user_user3"]; !ok {
+		t.Errorf("expected user3 in the dry-run state")
+	}
+	if _, err := ledger.Query("queryUser", []string{"user3"}); err == nil {
+		t.Errorf("dry run changed the ledger")
+	}
+}
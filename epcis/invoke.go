@@ -0,0 +1,161 @@
+package epcis
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Blockchain-book/Fabric-Food/backend"
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/Blockchain-book/Fabric-Food/simulator"
+)
+
+// 链码调用方式
+type Invoker interface {
+	Invoke(function string, args []string) ([]byte, error)
+}
+
+// 只读查询, 生成导入计划前用于检查账本中已有的数据
+type Querier interface {
+	Query(function string, args []string) ([]byte, error)
+}
+
+// 以固定身份通过后端执行链码
+type BackendInvoker struct {
+	Backend  backend.Backend
+	Identity string
+}
+
+func (b *BackendInvoker) Invoke(function string, args []string) ([]byte, error) {
+	return b.Backend.Invoke(b.Identity, function, args)
+}
+
+func (b *BackendInvoker) Query(function string, args []string) ([]byte, error) {
+	return b.Backend.Query(b.Identity, function, args)
+}
+
+// 调用结果
+type Result struct {
+	Invocation *Invocation `json:"invocation"`
+	Code       string      `json:"code,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// 按顺序执行导入计划
+func Apply(invoker Invoker, plan *Plan, stopOnError bool) []*Result {
+	results := make([]*Result, 0, len(plan.Invocations))
+	for _, invocation := range plan.Invocations {
+		result := &Result{Invocation: invocation}
+		if _, err := invoker.Invoke(invocation.Function, invocation.Args); err != nil {
+			if ccErr, ok := err.(*backend.ChaincodeError); ok && ccErr.Detail != nil {
+				result.Code = ccErr.Detail.Code
+			}
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+
+		if result.Error != "" && stopOnError {
+			break
+		}
+	}
+
+	return results
+}
+
+// 演练时的调用身份
+const mockIdentity = "importer"
+
+// 在进程内的模拟账本上执行链码, 用于导入前的演练
+// 与 peer 一样, 失败的交易不留下写入, 交易内读不到自己的写入
+type MockInvoker struct {
+	BackendInvoker
+	Sim *simulator.Simulator
+}
+
+// mspID 为演练时调用者的MSP, 登记的用户属于该MSP; config 为链码配置, 通常取自账本的 queryConfig
+func NewMockInvoker(mspID, config string) (*MockInvoker, error) {
+	sim, err := simulator.New(config, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if err := sim.AddIdentity(mockIdentity, &backend.Identity{MSPID: mspID}); err != nil {
+		return nil, err
+	}
+
+	return &MockInvoker{BackendInvoker: BackendInvoker{Backend: sim, Identity: mockIdentity}, Sim: sim}, nil
+}
+
+// 把账本中查询到的文档写入模拟账本, 演练时计划中的调用与真实提交看到相同的用户和资产
+func (m *MockInvoker) Seed(states map[string][]byte) {
+	keys := make([]string, 0, len(states))
+	for key := range states {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	stub := m.Sim.Stub
+	stub.MockTransactionStart("seed")
+	for _, key := range keys {
+		stub.PutState(key, states[key])
+	}
+	stub.MockTransactionEnd("seed")
+}
+
+// 记录查询到的用户和资产文档, 用于初始化演练的模拟账本
+type LedgerRecorder struct {
+	Querier Querier
+	// 状态键到文档
+	States map[string][]byte
+}
+
+func NewLedgerRecorder(querier Querier) *LedgerRecorder {
+	return &LedgerRecorder{Querier: querier, States: make(map[string][]byte)}
+}
+
+// 查询函数对应的文档类型
+var recordedQueries = map[string]string{
+	"queryUser":       "user",
+	"queryIngredient": "ingredient",
+	"queryFood":       "food",
+}
+
+func (r *LedgerRecorder) Query(function string, args []string) ([]byte, error) {
+	payload, err := r.Querier.Query(function, args)
+	if err != nil || len(args) == 0 {
+		return payload, err
+	}
+	if kind, ok := recordedQueries[function]; ok {
+		r.States[food.StateKey(kind, args[0])] = payload
+	}
+
+	return payload, nil
+}
+
+// 演练后的世界状态, 组合键转换为可读形式
+func (m *MockInvoker) State() map[string]json.RawMessage {
+	stub := m.Sim.Stub
+	keys := make([]string, 0, len(stub.State))
+	for key := range stub.State {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	state := make(map[string]json.RawMessage)
+	for _, key := range keys {
+		name := key
+		if strings.HasPrefix(key, "\x00") {
+			if objectType, attributes, err := stub.SplitCompositeKey(key); err == nil {
+				name = strings.Join(append([]string{objectType}, attributes...), "/")
+			}
+		}
+
+		value := stub.State[key]
+		if !json.Valid(value) {
+			value, _ = json.Marshal(string(value))
+		}
+		state[name] = value
+	}
+
+	return state
+}
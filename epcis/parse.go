@@ -0,0 +1,218 @@
+package epcis
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// CBV词汇的URN和URI前缀, 解析时统一去掉
+var vocabularyPrefixes = []string{
+	"urn:epcglobal:cbv:bizstep:",
+	"urn:epcglobal:cbv:disp:",
+	"urn:epcglobal:cbv:sdt:",
+	"urn:epcglobal:cbv:btt:",
+	"https://ref.gs1.org/cbv/BizStep-",
+	"https://ref.gs1.org/cbv/Disp-",
+	"https://ref.gs1.org/cbv/SDT-",
+	"https://ref.gs1.org/cbv/BTT-",
+}
+
+func normalizeVocabulary(value string) string {
+	value = strings.TrimSpace(value)
+	for _, prefix := range vocabularyPrefixes {
+		if strings.HasPrefix(value, prefix) {
+			return strings.TrimPrefix(value, prefix)
+		}
+	}
+
+	return value
+}
+
+// 解析EPCIS文档, 支持JSON/JSON-LD和XML(1.x及2.0)
+func Parse(data []byte) (*Document, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty EPCIS document")
+	}
+
+	var doc *Document
+	var err error
+	if data[0] == '<' {
+		doc, err = parseXML(data)
+	} else {
+		doc, err = parseJSON(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range doc.Body.EventList {
+		normalizeEvent(event)
+	}
+
+	return doc, nil
+}
+
+func parseJSON(data []byte) (*Document, error) {
+	doc := new(Document)
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("unmarshal EPCIS json error: %s", err)
+	}
+
+	return doc, nil
+}
+
+func normalizeEvent(event *Event) {
+	event.Action = strings.ToUpper(strings.TrimSpace(event.Action))
+	event.BizStep = normalizeVocabulary(event.BizStep)
+	event.Disposition = normalizeVocabulary(event.Disposition)
+	for _, source := range event.SourceList {
+		source.Type = normalizeVocabulary(source.Type)
+	}
+	for _, destination := range event.DestinationList {
+		destination.Type = normalizeVocabulary(destination.Type)
+	}
+	for _, tx := range event.BizTransactionList {
+		tx.Type = normalizeVocabulary(tx.Type)
+	}
+}
+
+// XML通用节点, EPCIS 1.x 把部分字段放在 extension 里, 按本地名查找即可兼容
+type xmlNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Content  string     `xml:",chardata"`
+	Children []*xmlNode `xml:",any"`
+}
+
+func (n *xmlNode) attr(name string) string {
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
+
+func (n *xmlNode) text() string {
+	return strings.TrimSpace(n.Content)
+}
+
+// 深度优先查找所有本地名为 name 的后代节点
+func (n *xmlNode) findAll(name string) []*xmlNode {
+	nodes := make([]*xmlNode, 0)
+	for _, child := range n.Children {
+		if child.XMLName.Local == name {
+			nodes = append(nodes, child)
+		}
+		nodes = append(nodes, child.findAll(name)...)
+	}
+
+	return nodes
+}
+
+func (n *xmlNode) find(name string) *xmlNode {
+	if nodes := n.findAll(name); len(nodes) != 0 {
+		return nodes[0]
+	}
+
+	return nil
+}
+
+func (n *xmlNode) findText(name string) string {
+	if node := n.find(name); node != nil {
+		return node.text()
+	}
+
+	return ""
+}
+
+// 列表节点下所有子节点的文本, 如 epcList/epc
+func (n *xmlNode) listTexts(listName string) []string {
+	texts := make([]string, 0)
+	list := n.find(listName)
+	if list == nil {
+		return texts
+	}
+	for _, child := range list.Children {
+		if text := child.text(); text != "" {
+			texts = append(texts, text)
+		}
+	}
+
+	return texts
+}
+
+var xmlEventTypes = map[string]bool{
+	ObjectEventType:         true,
+	"TransactionEvent":      true,
+	TransformationEventType: true,
+	"AggregationEvent":      true,
+	"AssociationEvent":      true,
+}
+
+func parseXML(data []byte) (*Document, error) {
+	root := new(xmlNode)
+	if err := xml.Unmarshal(data, root); err != nil {
+		return nil, fmt.Errorf("unmarshal EPCIS xml error: %s", err)
+	}
+
+	doc := &Document{
+		Context:       []string{Context},
+		Type:          DocumentType,
+		SchemaVersion: root.attr("schemaVersion"),
+		CreationDate:  root.attr("creationDate"),
+		Body:          Body{EventList: make([]*Event, 0)},
+	}
+
+	var walk func(n *xmlNode)
+	walk = func(n *xmlNode) {
+		for _, child := range n.Children {
+			if xmlEventTypes[child.XMLName.Local] {
+				doc.Body.EventList = append(doc.Body.EventList, xmlEvent(child))
+				continue
+			}
+			walk(child)
+		}
+	}
+	walk(root)
+
+	return doc, nil
+}
+
+func xmlEvent(n *xmlNode) *Event {
+	event := &Event{
+		Type:                n.XMLName.Local,
+		EventID:             n.findText("eventID"),
+		EventTime:           n.findText("eventTime"),
+		EventTimeZoneOffset: n.findText("eventTimeZoneOffset"),
+		EPCList:             n.listTexts("epcList"),
+		InputEPCList:        n.listTexts("inputEPCList"),
+		OutputEPCList:       n.listTexts("outputEPCList"),
+		TransformationID:    n.findText("transformationID"),
+		Action:              n.findText("action"),
+		BizStep:             n.findText("bizStep"),
+		Disposition:         n.findText("disposition"),
+	}
+	if id := n.find("readPoint"); id != nil {
+		event.ReadPoint = &Location{Id: id.findText("id")}
+	}
+	if id := n.find("bizLocation"); id != nil {
+		event.BizLocation = &Location{Id: id.findText("id")}
+	}
+	for _, source := range n.findAll("source") {
+		event.SourceList = append(event.SourceList, &Source{Type: source.attr("type"), Source: source.text()})
+	}
+	for _, destination := range n.findAll("destination") {
+		event.DestinationList = append(event.DestinationList, &Destination{Type: destination.attr("type"), Destination: destination.text()})
+	}
+	for _, tx := range n.findAll("bizTransaction") {
+		event.BizTransactionList = append(event.BizTransactionList, &BizTransaction{Type: tx.attr("type"), BizTransaction: tx.text()})
+	}
+
+	return event
+}
@@ -0,0 +1,199 @@
+package epcis
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	RecordEnroll   = "enroll"
+	RecordExchange = "exchange"
+	RecordCombine  = "combine"
+	RecordProcess  = "process"
+
+	AssetIngredient = "ingredient"
+	AssetFood       = "food"
+
+	// 链码中登记记录的原始拥有者
+	originOwner = "originPlaceholder"
+)
+
+// 资产引用
+type AssetRef struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+// 供应链记录, 是链码查询结果和EPCIS事件之间的中间形式,
+// 也可以直接从记录下来的链码事件构造
+type Record struct {
+	Kind     string      `json:"kind"`
+	Asset    AssetRef    `json:"asset"`
+	From     string      `json:"from,omitempty"`
+	To       string      `json:"to,omitempty"`
+	Inputs   []*AssetRef `json:"inputs,omitempty"`
+	Outputs  []*AssetRef `json:"outputs,omitempty"`
+	BizStep  string      `json:"biz_step,omitempty"`
+	Location string      `json:"location,omitempty"`
+	Time     string      `json:"time,omitempty"`
+	TxId     string      `json:"tx_id,omitempty"`
+}
+
+// queryFoodProvenance 的返回结果
+type Provenance struct {
+	Food        *Food                   `json:"food"`
+	History     []*History              `json:"history"`
+	Ingredients []*IngredientProvenance `json:"ingredients"`
+	Foods       []*Provenance           `json:"foods"`
+}
+
+type IngredientProvenance struct {
+	Ingredient *Ingredient `json:"ingredient"`
+	History    []*History  `json:"history"`
+}
+
+type Food struct {
+	Name        string   `json:"name"`
+	Id          string   `json:"id"`
+	Metadata    string   `json:"metadata"`
+	Ingredients []string `json:"ingredients"`
+	Foods       []string `json:"foods"`
+	EnrolledAt  string   `json:"enrolled_at,omitempty"`
+}
+
+type Ingredient struct {
+	Name     string `json:"name"`
+	Id       string `json:"id"`
+	Metadata string `json:"metadata"`
+}
+
+// 食材和食品的流通记录
+type History struct {
+	IngredientId   string       `json:"ingredient_id,omitempty"`
+	FoodId         string       `json:"food_id,omitempty"`
+	OriginOwnerId  string       `json:"origin_owner_id"`
+	CurrentOwnerId string       `json:"current_owner_id"`
+	FacilityId     string       `json:"facility_id,omitempty"`
+	Timestamp      string       `json:"timestamp,omitempty"`
+	Type           string       `json:"type,omitempty"`
+	Step           *ProcessStep `json:"step,omitempty"`
+}
+
+type ProcessStep struct {
+	Id         string   `json:"id"`
+	StepType   string   `json:"step_type"`
+	FacilityId string   `json:"facility_id"`
+	OperatorId string   `json:"operator_id"`
+	InputIds   []string `json:"input_ids"`
+	OutputIds  []string `json:"output_ids"`
+	Timestamp  string   `json:"timestamp"`
+}
+
+// 解析 queryFoodProvenance 的返回结果
+func ParseProvenance(data []byte) (*Provenance, error) {
+	provenance := new(Provenance)
+	if err := json.Unmarshal(data, provenance); err != nil {
+		return nil, fmt.Errorf("unmarshal provenance error: %s", err)
+	}
+	if provenance.Food == nil {
+		return nil, fmt.Errorf("provenance has no food")
+	}
+
+	return provenance, nil
+}
+
+// 把溯源树展开为供应链记录
+func FromProvenance(provenance *Provenance) []*Record {
+	records := make([]*Record, 0)
+	seen := make(map[string]bool)
+	add := func(record *Record) {
+		key, _ := json.Marshal(record)
+		if seen[string(key)] {
+			return
+		}
+		seen[string(key)] = true
+		records = append(records, record)
+	}
+
+	var walk func(p *Provenance, parentId string)
+	walk = func(p *Provenance, parentId string) {
+		food := p.Food
+		for _, ingredient := range p.Ingredients {
+			ref := AssetRef{Type: AssetIngredient, Id: ingredient.Ingredient.Id}
+			for _, history := range ingredient.History {
+				add(historyRecord(ref, history, food.Id, ""))
+			}
+		}
+
+		ref := AssetRef{Type: AssetFood, Id: food.Id}
+		for _, history := range p.History {
+			if history.Type == RecordProcess && history.Step != nil {
+				add(processRecord(history.Step))
+				continue
+			}
+			add(historyRecord(ref, history, parentId, food.EnrolledAt))
+		}
+
+		for _, sub := range p.Foods {
+			walk(sub, food.Id)
+		}
+	}
+	walk(provenance, "")
+
+	return records
+}
+
+// 流通记录转换为供应链记录, 当前拥有者为 foodId 时是组合操作
+// 时间取自流通记录, 旧的登记记录没有时间时使用食品的登记时间
+func historyRecord(ref AssetRef, history *History, foodId, enrolledAt string) *Record {
+	switch {
+	case history.OriginOwnerId == originOwner:
+		t := history.Timestamp
+		if t == "" {
+			t = enrolledAt
+		}
+		return &Record{Kind: RecordEnroll, Asset: ref, To: history.CurrentOwnerId, Location: history.FacilityId, Time: t}
+	case foodId != "" && history.CurrentOwnerId == foodId:
+		return &Record{
+			Kind:     RecordCombine,
+			Asset:    AssetRef{Type: AssetFood, Id: foodId},
+			From:     history.OriginOwnerId,
+			Inputs:   []*AssetRef{{Type: ref.Type, Id: ref.Id}},
+			Outputs:  []*AssetRef{{Type: AssetFood, Id: foodId}},
+			Location: history.FacilityId,
+			Time:     history.Timestamp,
+		}
+	default:
+		return &Record{
+			Kind:     RecordExchange,
+			Asset:    ref,
+			From:     history.OriginOwnerId,
+			To:       history.CurrentOwnerId,
+			Location: history.FacilityId,
+			Time:     history.Timestamp,
+		}
+	}
+}
+
+func processRecord(step *ProcessStep) *Record {
+	record := &Record{
+		Kind:     RecordProcess,
+		Asset:    AssetRef{Type: AssetFood},
+		From:     step.OperatorId,
+		BizStep:  step.StepType,
+		Location: step.FacilityId,
+		Time:     step.Timestamp,
+		TxId:     step.Id,
+	}
+	for _, id := range step.InputIds {
+		record.Inputs = append(record.Inputs, &AssetRef{Type: AssetFood, Id: id})
+	}
+	for _, id := range step.OutputIds {
+		record.Outputs = append(record.Outputs, &AssetRef{Type: AssetFood, Id: id})
+	}
+	if len(step.OutputIds) != 0 {
+		record.Asset.Id = step.OutputIds[0]
+	}
+
+	return record
+}
@@ -0,0 +1,94 @@
+package epcis
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var timeZoneOffsetPattern = regexp.MustCompile(`^[+-]((0[0-9]|1[0-3]):[0-5][0-9]|14:00)$`)
+
+// 按EPCIS 2.0 JSON schema的必填项和取值约束校验文档
+func Validate(doc *Document) error {
+	hasContext := false
+	for _, ctx := range doc.Context {
+		if ctx == Context {
+			hasContext = true
+		}
+	}
+	if !hasContext {
+		return fmt.Errorf("missing @context %s", Context)
+	}
+	if doc.Type != DocumentType {
+		return fmt.Errorf("invalid document type: %s", doc.Type)
+	}
+	if doc.SchemaVersion != SchemaVersion {
+		return fmt.Errorf("invalid schemaVersion: %s", doc.SchemaVersion)
+	}
+	if _, err := time.Parse(time.RFC3339, doc.CreationDate); err != nil {
+		return fmt.Errorf("invalid creationDate: %s", doc.CreationDate)
+	}
+
+	for i, event := range doc.Body.EventList {
+		if err := validateEvent(event); err != nil {
+			return fmt.Errorf("event %d: %s", i, err)
+		}
+	}
+
+	return nil
+}
+
+func validateEvent(event *Event) error {
+	if _, err := time.Parse(time.RFC3339, event.EventTime); err != nil {
+		return fmt.Errorf("invalid eventTime: %s", event.EventTime)
+	}
+	if !timeZoneOffsetPattern.MatchString(event.EventTimeZoneOffset) {
+		return fmt.Errorf("invalid eventTimeZoneOffset: %s", event.EventTimeZoneOffset)
+	}
+
+	switch event.Type {
+	case ObjectEventType:
+		if err := validateAction(event.Action); err != nil {
+			return err
+		}
+		if len(event.EPCList) == 0 {
+			return fmt.Errorf("ObjectEvent requires epcList")
+		}
+	case TransformationEventType:
+		if event.Action != "" {
+			return fmt.Errorf("TransformationEvent must not have action")
+		}
+		if len(event.InputEPCList) == 0 && len(event.OutputEPCList) == 0 {
+			return fmt.Errorf("TransformationEvent requires inputEPCList or outputEPCList")
+		}
+	default:
+		return fmt.Errorf("unsupported event type: %s", event.Type)
+	}
+
+	for _, source := range event.SourceList {
+		if source.Type == "" || source.Source == "" {
+			return fmt.Errorf("invalid sourceList entry")
+		}
+	}
+	for _, destination := range event.DestinationList {
+		if destination.Type == "" || destination.Destination == "" {
+			return fmt.Errorf("invalid destinationList entry")
+		}
+	}
+	for _, tx := range event.BizTransactionList {
+		if tx.BizTransaction == "" {
+			return fmt.Errorf("invalid bizTransactionList entry")
+		}
+	}
+
+	return nil
+}
+
+func validateAction(action string) error {
+	switch action {
+	case ActionAdd, ActionObserve, ActionDelete:
+		return nil
+	default:
+		return fmt.Errorf("invalid action: %s", action)
+	}
+}
@@ -0,0 +1,131 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrUnauthenticated  = errors.New("missing or invalid credential")
+	ErrIdentityMismatch = fmt.Errorf("%s does not match the credential", IdentityHeader)
+)
+
+// 把请求绑定到调用身份
+type Authenticator interface {
+	// 返回请求的身份名
+	Authenticate(r *http.Request) (string, error)
+}
+
+// 按 identities.json 中配置的凭证认证:
+// Authorization: Bearer <token> 与身份的 token_sha256 对应,
+// 或者经过 ClientCAs 校验的 mTLS 客户端证书的 CN 与身份的 client_cn 一致。
+// X-Fabric-Identity 头可以省略, 给出时必须与凭证对应的身份一致, 不能单独选择身份
+type CredentialAuth struct {
+	names      []string
+	identities map[string]*Identity
+}
+
+func NewCredentialAuth(identities map[string]*Identity) (*CredentialAuth, error) {
+	names := make([]string, 0, len(identities))
+	for name := range identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tokens := make(map[string]string)
+	cns := make(map[string]string)
+	for _, name := range names {
+		identity := identities[name]
+		if identity.TokenSHA256 != "" {
+			hash, err := hex.DecodeString(identity.TokenSHA256)
+			if err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("identity %s: token_sha256 must be a hex sha256 digest", name)
+			}
+			token := strings.ToLower(identity.TokenSHA256)
+			if other, ok := tokens[token]; ok {
+				return nil, fmt.Errorf("identities %s and %s share a token", other, name)
+			}
+			tokens[token] = name
+		}
+		if identity.ClientCN != "" {
+			if other, ok := cns[identity.ClientCN]; ok {
+				return nil, fmt.Errorf("identities %s and %s share client_cn %s", other, name, identity.ClientCN)
+			}
+			cns[identity.ClientCN] = name
+		}
+	}
+	if len(tokens)+len(cns) == 0 {
+		return nil, fmt.Errorf("no identity has a token_sha256 or client_cn credential")
+	}
+
+	return &CredentialAuth{names: names, identities: identities}, nil
+}
+
+// 是否有身份使用 mTLS 客户端证书认证
+func (a *CredentialAuth) UsesClientCerts() bool {
+	for _, name := range a.names {
+		if a.identities[name].ClientCN != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *CredentialAuth) Authenticate(r *http.Request) (string, error) {
+	name := a.credential(r)
+	if name == "" {
+		return "", ErrUnauthenticated
+	}
+	if header := r.Header.Get(IdentityHeader); header != "" && header != name {
+		return "", ErrIdentityMismatch
+	}
+
+	return name, nil
+}
+
+// 凭证对应的身份名, 没有有效凭证时返回空
+func (a *CredentialAuth) credential(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		sum := sha256.Sum256([]byte(strings.TrimPrefix(auth, "Bearer ")))
+		digest := hex.EncodeToString(sum[:])
+		matched := ""
+		for _, name := range a.names {
+			expected := strings.ToLower(a.identities[name].TokenSHA256)
+			if expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(digest)) == 1 {
+				matched = name
+			}
+		}
+		return matched
+	}
+
+	// 只接受经过 CA 校验的证书链
+	if r.TLS != nil && len(r.TLS.VerifiedChains) != 0 && len(r.TLS.VerifiedChains[0]) != 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		for _, name := range a.names {
+			if cn != "" && a.identities[name].ClientCN == cn {
+				return name
+			}
+		}
+	}
+
+	return ""
+}
+
+// 只用 X-Fabric-Identity 头选择身份, 不校验凭证, 只用于 -mock 的测试和演示
+type HeaderAuth struct{}
+
+func (HeaderAuth) Authenticate(r *http.Request) (string, error) {
+	identity := r.Header.Get(IdentityHeader)
+	if identity == "" {
+		return "", fmt.Errorf("missing %s header", IdentityHeader)
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,109 @@
+package gateway_test
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+)
+
+// 记录调用身份的后端
+type recordBackend struct {
+	identity string
+}
+
+func (b *recordBackend) Query(identity, function string, args []string) ([]byte, error) {
+	b.identity = identity
+	return []byte(`{}`), nil
+}
+
+func (b *recordBackend) Invoke(identity, function string, args []string) ([]byte, error) {
+	b.identity = identity
+	return nil, nil
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCredentialAuth(t *testing.T) {
+	auth, err := gateway.NewCredentialAuth(map[string]*gateway.Identity{
+		"org1admin": {MSPID: "Org1MSP", TokenSHA256: tokenHash("secret1")},
+		"certifier": {MSPID: "CertMSP", TokenSHA256: tokenHash("secret2")},
+		"scanner":   {MSPID: "Org1MSP", ClientCN: "scanner.org1"},
+		"reader":    {MSPID: "Org1MSP"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		cn       string
+		verified bool
+		header   string
+		status   int
+		identity string
+	}{
+		{"header only", "", "", false, "org1admin", http.StatusUnauthorized, ""},
+		{"no credential", "", "", false, "", http.StatusUnauthorized, ""},
+		{"wrong token", "guess", "", false, "", http.StatusUnauthorized, ""},
+		{"token", "secret2", "", false, "", http.StatusOK, "certifier"},
+		{"token and matching header", "secret1", "", false, "org1admin", http.StatusOK, "org1admin"},
+		{"token and other header", "secret1", "", false, "certifier", http.StatusForbidden, ""},
+		{"client certificate", "", "scanner.org1", true, "", http.StatusOK, "scanner"},
+		{"unverified client certificate", "", "scanner.org1", false, "", http.StatusUnauthorized, ""},
+		{"unknown client certificate", "", "other.org1", true, "", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		backend := new(recordBackend)
+		server := gateway.NewServer(backend, auth)
+		req := httptest.NewRequest("GET", "/users/user1", nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		if tt.cn != "" {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: tt.cn}}
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+			if tt.verified {
+				req.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+			}
+		}
+		if tt.header != "" {
+			req.Header.Set(gateway.IdentityHeader, tt.header)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status || backend.identity != tt.identity {
+			t.Errorf("%s: expected %d as %q, got %d as %q: %s", tt.name, tt.status, tt.identity, rec.Code, backend.identity, rec.Body)
+		}
+	}
+}
+
+func TestNewCredentialAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		identities map[string]*gateway.Identity
+	}{
+		{"no credentials", map[string]*gateway.Identity{"a": {MSPID: "Org1MSP"}}},
+		{"invalid digest", map[string]*gateway.Identity{"a": {MSPID: "Org1MSP", TokenSHA256: "secret"}}},
+		{"shared token", map[string]*gateway.Identity{"a": {TokenSHA256: tokenHash("t")}, "b": {TokenSHA256: tokenHash("t")}}},
+		{"shared client cn", map[string]*gateway.Identity{"a": {ClientCN: "c"}, "b": {ClientCN: "c"}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := gateway.NewCredentialAuth(tt.identities); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Package gateway 把链码函数以 REST API 的形式对外提供。
+//
+// 每个请求由 Authenticator 按凭证(bearer token 或 mTLS 客户端证书)确定调用身份,
+// 由后端决定如何以该身份执行链码: SDKBackend 通过 fabric-sdk-go 与网络保持长连接,
+// PeerBackend 调用本机的 peer 命令, 只用于开发环境, MockBackend 在进程内的模拟账本上执行链码,
+// 用于测试和演示, 此时可以只用 X-Fabric-Identity 头选择身份。
+package gateway
+
+import "github.com/Blockchain-book/Fabric-Food/backend"
+
+// 调用身份、执行方式和链码错误定义在 backend 包, simulator 也使用这些类型
+type (
+	Identity       = backend.Identity
+	Backend        = backend.Backend
+	ChaincodeError = backend.ChaincodeError
+)
+
+var ErrUnknownIdentity = backend.ErrUnknownIdentity
+
+// 解析链码写在 message 中的结构化错误
+func NewChaincodeError(status int32, message string) *ChaincodeError {
+	return backend.NewChaincodeError(status, message)
+}
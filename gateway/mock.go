@@ -0,0 +1,23 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/Blockchain-book/Fabric-Food/simulator"
+)
+
+// 在进程内的模拟账本上执行链码
+// 身份证书、交易写集和事件由 simulator 处理, 与真实网络一致
+type MockBackend struct {
+	*simulator.Simulator
+}
+
+// config 为 Init 时写入的链码配置, 可以为空
+func NewMockBackend(config string) (*MockBackend, error) {
+	sim, err := simulator.New(config, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MockBackend{Simulator: sim}, nil
+}
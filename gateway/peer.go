@@ -0,0 +1,97 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	peerPayloadPattern  = regexp.MustCompile(`payload:("(?:[^"\\]|\\.)*")`)
+	peerResponsePattern = regexp.MustCompile(`status:(\d+) message:("(?:[^"\\]|\\.)*")`)
+)
+
+// 通过本机的 peer 命令执行链码, 按身份切换 MSP 目录
+// 每次调用启动一个 peer 进程, 结果和错误从命令输出中解析, 只用于开发环境中的命令行工具;
+// 网关和长期运行的服务使用 SDKBackend
+type PeerBackend struct {
+	PeerBin    string
+	Channel    string
+	Chaincode  string
+	Orderer    string
+	Identities map[string]*Identity
+}
+
+func (p *PeerBackend) Query(identity, function string, args []string) ([]byte, error) {
+	stdout, _, err := p.run(identity, "query", function, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSpace(stdout), nil
+}
+
+func (p *PeerBackend) Invoke(identity, function string, args []string) ([]byte, error) {
+	extra := []string{"--waitForEvent"}
+	if p.Orderer != "" {
+		extra = append(extra, "-o", p.Orderer)
+	}
+	_, stderr, err := p.run(identity, "invoke", function, args, extra...)
+	if err != nil {
+		return nil, err
+	}
+
+	// peer 把执行结果以 protobuf 文本格式打印在日志里
+	match := peerPayloadPattern.FindSubmatch(stderr)
+	if match == nil {
+		return nil, nil
+	}
+	payload, err := strconv.Unquote(string(match[1]))
+	if err != nil {
+		return nil, fmt.Errorf("parse peer output error: %s", err)
+	}
+
+	return []byte(payload), nil
+}
+
+// 返回 peer 命令的标准输出和标准错误
+func (p *PeerBackend) run(identity, command, function string, args []string, extra ...string) ([]byte, []byte, error) {
+	id, ok := p.Identities[identity]
+	if !ok {
+		return nil, nil, ErrUnknownIdentity
+	}
+
+	input, err := json.Marshal(map[string][]string{"Args": append([]string{function}, args...)})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmdArgs := append([]string{"chaincode", command, "-C", p.Channel, "-n", p.Chaincode, "-c", string(input)}, extra...)
+	cmd := exec.Command(p.PeerBin, cmdArgs...)
+	cmd.Env = append(os.Environ(), "CORE_PEER_LOCALMSPID="+id.MSPID)
+	if id.MSPConfigPath != "" {
+		cmd.Env = append(cmd.Env, "CORE_PEER_MSPCONFIGPATH="+id.MSPConfigPath)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// 背书失败时取出链码返回的错误信息
+		if match := peerResponsePattern.FindSubmatch(stderr.Bytes()); match != nil {
+			status, _ := strconv.Atoi(string(match[1]))
+			message, unquoteErr := strconv.Unquote(string(match[2]))
+			if unquoteErr == nil {
+				return nil, nil, NewChaincodeError(int32(status), message)
+			}
+		}
+		return nil, nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), stderr.Bytes(), nil
+}
@@ -0,0 +1,314 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+)
+
+// 接口的参数取自链码函数的请求结构 (food.NewRequest), 字段名为请求结构的json标签:
+// 路径中的 {name} 对应同名字段, 其余字段 GET 请求取自查询参数, 其他请求取自 JSON 请求体,
+// 参数顺序和可选参数由请求结构的 Args 决定, 路由中不再重复定义
+type Route struct {
+	Method   string
+	Path     string
+	Function string
+	// 整个请求体对应的请求结构字段, 用于批量接口和配置
+	BodyField string
+	// 成功时返回 201 Created
+	Created bool
+	// 以下用于生成 OpenAPI 文档和客户端
+	Summary string
+	// 成功时返回的结构, nil 表示没有返回值
+	Response interface{}
+}
+
+// 路由的单个参数
+type Param struct {
+	Name     string // 请求结构的json标签
+	Source   string // path|query|body
+	Type     string // string|array|number|integer|object, 整个请求体时为 body
+	Optional bool
+	// 请求结构中的字段类型
+	Field reflect.Type
+}
+
+// 全部链码函数, 按顺序匹配, 固定路径写在带参数的路径之前
+var Routes = []*Route{
+	// 用户
+	{Method: http.MethodPost, Path: "/users", Function: "userRegister", Created: true,
+		Summary: "注册用户"},
+	{Method: http.MethodGet, Path: "/users/{id}", Function: "queryUser",
+		Summary: "查询用户", Response: &food.User{}},
+	{Method: http.MethodDelete, Path: "/users/{id}", Function: "userDestroy",
+		Summary: "删除用户, 名下资产按配置的删除策略处理"},
+
+	// 食材
+	{Method: http.MethodPost, Path: "/ingredients", Function: "ingredientEnroll", Created: true,
+		Summary: "登记食材"},
+	{Method: http.MethodGet, Path: "/ingredients/{id}", Function: "queryIngredient",
+		Summary: "查询食材", Response: &food.Ingredient{}},
+	{Method: http.MethodGet, Path: "/ingredients/{ingredient_id}/history", Function: "queryIngredientHistory",
+		Summary: "查询食材流通记录, query_type 为 all|enroll|exchange", Response: []*food.IngredientHistory{}},
+	{Method: http.MethodPost, Path: "/ingredients/{ingredient_id}/exchange", Function: "ingredientExchange",
+		Summary: "转让食材"},
+	{Method: http.MethodPost, Path: "/ingredients/{ingredient_id}/compose", Function: "ingredientExchangeFood",
+		Summary: "食材并入食品"},
+
+	// 食品
+	{Method: http.MethodPost, Path: "/foods", Function: "foodEnroll", Created: true,
+		Summary: "登记食品"},
+	{Method: http.MethodGet, Path: "/foods/{id}", Function: "queryFood",
+		Summary: "查询食品", Response: &food.Food{}},
+	{Method: http.MethodGet, Path: "/foods/{food_id}/history", Function: "queryFoodHistory",
+		Summary: "查询食品流通记录, query_type 为 all|enroll|exchange|process", Response: []*food.FoodHistory{}},
+	{Method: http.MethodGet, Path: "/foods/{food_id}/provenance", Function: "queryFoodProvenance",
+		Summary: "查询食品的完整溯源树", Response: &food.FoodProvenance{}},
+	{Method: http.MethodGet, Path: "/foods/{food_id}/allergens", Function: "queryFoodAllergens",
+		Summary: "查询食品的过敏原标签", Response: &food.AllergenLabel{}},
+	{Method: http.MethodPost, Path: "/foods/{food_id}/exchange", Function: "foodExchange",
+		Summary: "转让食品, 需要多方审批时返回待审批的转让申请", Response: &food.Transfer{}},
+	{Method: http.MethodPost, Path: "/foods/{food_id}/compose", Function: "foodExchangeFood",
+		Summary: "食品并入其他食品"},
+	{Method: http.MethodPost, Path: "/foods/{food_id}/recall", Function: "foodRecall",
+		Summary: "召回食品或解除召回, 仅限监管机构", Response: &food.FoodRecall{}},
+	{Method: http.MethodGet, Path: "/products/{serial}/verify", Function: "verifyProduct",
+		Summary: "按序列号验证产品", Response: &food.ProductVerification{}},
+
+	// 批量
+	{Method: http.MethodPost, Path: "/batches/ingredients", Function: "ingredientEnrollBatch", BodyField: "items", Created: true,
+		Summary: "批量登记食材, 任一条失败时全部拒绝", Response: []*food.BatchResult{}},
+	{Method: http.MethodPost, Path: "/batches/foods", Function: "foodEnrollBatch", BodyField: "items", Created: true,
+		Summary: "批量登记食品, 任一条失败时全部拒绝", Response: []*food.BatchResult{}},
+	{Method: http.MethodPost, Path: "/batches/ingredient-exchanges", Function: "ingredientExchangeBatch", BodyField: "items",
+		Summary: "批量转让食材, 任一条失败时全部拒绝", Response: []*food.BatchResult{}},
+
+	// 加工
+	{Method: http.MethodPost, Path: "/process-steps", Function: "foodProcess", Created: true,
+		Summary: "记录加工步骤"},
+	{Method: http.MethodGet, Path: "/process-steps/{id}", Function: "queryProcessStep",
+		Summary: "查询加工步骤", Response: &food.ProcessStep{}},
+
+	// 容器
+	{Method: http.MethodPost, Path: "/containers", Function: "containerEnroll", Created: true,
+		Summary: "登记容器"},
+	{Method: http.MethodGet, Path: "/containers/{id}", Function: "queryContainer",
+		Summary: "查询容器及其内容", Response: &food.ContainerContents{}},
+	{Method: http.MethodGet, Path: "/containers/{container_id}/history", Function: "queryContainerHistory",
+		Summary: "查询容器流通记录, query_type 为 all|enroll|exchange", Response: []*food.ContainerHistory{}},
+	{Method: http.MethodPost, Path: "/containers/{container_id}/pack", Function: "containerPack",
+		Summary: "装箱, kind 为 ingredient|food|container"},
+	{Method: http.MethodPost, Path: "/containers/{container_id}/unpack", Function: "containerUnpack",
+		Summary: "拆箱, kind 为 ingredient|food|container"},
+	{Method: http.MethodPost, Path: "/containers/{container_id}/exchange", Function: "containerExchange",
+		Summary: "转让容器及其全部内容"},
+
+	// 设施
+	{Method: http.MethodPost, Path: "/facilities", Function: "facilityRegister", Created: true,
+		Summary: "登记设施, type 为 farm|factory|warehouse|store"},
+	{Method: http.MethodGet, Path: "/facilities/{id}", Function: "queryFacility",
+		Summary: "查询设施", Response: &food.Facility{}},
+	{Method: http.MethodGet, Path: "/facilities/{facility_id}/items", Function: "queryFacilityItems",
+		Summary: "查询经过设施的资产", Response: []*food.FacilityVisit{}},
+
+	// 认证
+	{Method: http.MethodPost, Path: "/certificates", Function: "certificateIssue", Created: true,
+		Summary: "签发认证证书, 仅限认证机构"},
+	{Method: http.MethodGet, Path: "/certificates", Function: "queryCertificates",
+		Summary: "查询持有者的证书", Response: []*food.Certificate{}},
+	{Method: http.MethodGet, Path: "/certificates/{id}", Function: "queryCertificate",
+		Summary: "查询证书", Response: &food.Certificate{}},
+	{Method: http.MethodPost, Path: "/certificates/{id}/revoke", Function: "certificateRevoke",
+		Summary: "撤销证书, 返回失去认证的资产键", Response: []string{}},
+
+	// 多方审批
+	{Method: http.MethodGet, Path: "/transfers/{id}", Function: "queryTransfer",
+		Summary: "查询转让申请", Response: &food.Transfer{}},
+	{Method: http.MethodPost, Path: "/transfers/{transfer_id}/decision", Function: "transferApprove",
+		Summary: "审批转让申请, decision 为 approve|reject", Response: &food.Transfer{}},
+
+	// 资产通用
+	{Method: http.MethodGet, Path: "/assets/{kind}/{asset_id}/transfers", Function: "queryTransfers",
+		Summary: "查询资产的全部转让申请", Response: []*food.Transfer{}},
+	{Method: http.MethodGet, Path: "/assets/{kind}/{asset_id}/endorsement", Function: "queryEndorsement",
+		Summary: "查询资产键的背书组织", Response: &food.KeyEndorsement{}},
+
+	// 管理
+	{Method: http.MethodGet, Path: "/config", Function: "queryConfig",
+		Summary: "查询链码配置, 可以指定历史版本", Response: &food.Config{}},
+	{Method: http.MethodPut, Path: "/config", Function: "updateConfig", BodyField: "config",
+		Summary: "更新链码配置, 仅限管理员, version 必须是当前版本"},
+	{Method: http.MethodPost, Path: "/migrations", Function: "migrate",
+		Summary: "分页迁移旧版本数据, 仅限管理员", Response: &food.MigrateResult{}},
+	{Method: http.MethodGet, Path: "/consistency", Function: "checkConsistency",
+		Summary: "分页检查账本一致性", Response: &food.ConsistencyReport{}},
+	{Method: http.MethodGet, Path: "/repairs", Function: "queryRepairs",
+		Summary: "查询修复记录", Response: []*food.RepairRecord{}},
+	{Method: http.MethodPost, Path: "/repairs/orphan", Function: "repairOrphan",
+		Summary: "把无主资产分配给用户, 仅限管理员", Response: &food.RepairRecord{}},
+	{Method: http.MethodPost, Path: "/repairs/reference", Function: "repairReference",
+		Summary: "删除持有者中失效的资产引用, 仅限管理员", Response: &food.RepairRecord{}},
+	{Method: http.MethodPost, Path: "/repairs/holdings", Function: "repairHoldings",
+		Summary: "按流通记录重建用户的资产列表, 仅限管理员", Response: &food.RepairRecord{}},
+}
+
+// 匹配转义后的路径, 返回解码后的路径参数, id 中转义的 / 不会被当作分隔符
+func (r *Route) match(path string) (map[string]string, bool) {
+	pattern := strings.Split(strings.Trim(r.Path, "/"), "/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, p := range pattern {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			value, err := url.PathUnescape(segments[i])
+			if err != nil || value == "" {
+				return nil, false
+			}
+			params[p[1:len(p)-1]] = value
+			continue
+		}
+		if p != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// 路由的参数, 按链码的参数顺序排列
+func (r *Route) Params() ([]*Param, error) {
+	req := food.NewRequest(r.Function)
+	if req == nil {
+		return nil, fmt.Errorf("%s: unknown function", r.Function)
+	}
+	t := reflect.TypeOf(req).Elem()
+
+	order, err := argOrder(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", r.Function, err)
+	}
+
+	pathParams := make(map[string]bool)
+	for _, segment := range strings.Split(strings.Trim(r.Path, "/"), "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			pathParams[segment[1:len(segment)-1]] = true
+		}
+	}
+
+	params := make([]*Param, 0, t.NumField())
+	for _, i := range order {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		p := &Param{
+			Name:     tag[0],
+			Source:   "body",
+			Type:     paramType(field.Type),
+			Optional: len(tag) > 1 && tag[1] == "omitempty",
+			Field:    field.Type,
+		}
+		switch {
+		case pathParams[p.Name]:
+			p.Source = "path"
+			delete(pathParams, p.Name)
+		case r.BodyField == p.Name:
+			p.Type = "body"
+		case r.readOnly():
+			p.Source = "query"
+		}
+		params = append(params, p)
+	}
+	for name := range pathParams {
+		return nil, fmt.Errorf("%s: path parameter %s is not a request field", r.Function, name)
+	}
+	if r.BodyField != "" && (len(params) != 1 || params[0].Type != "body") {
+		return nil, fmt.Errorf("%s: body field %s must be the only request field", r.Function, r.BodyField)
+	}
+
+	return params, nil
+}
+
+// 参数的类型
+func paramType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Slice:
+		return "array"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	case reflect.Map, reflect.Ptr, reflect.Struct:
+		return "object"
+	default:
+		return "string"
+	}
+}
+
+// 请求结构字段在 Args 中的顺序: 每个字段填入不同的标记值, 再看标记出现在第几个参数
+func argOrder(req food.Request) ([]int, error) {
+	v := reflect.New(reflect.TypeOf(req).Elem())
+	t := v.Elem().Type()
+	if t.NumField() == 1 {
+		return []int{0}, nil
+	}
+
+	markers := make([]string, t.NumField())
+	for i := range markers {
+		field := v.Elem().Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			markers[i] = fmt.Sprintf("@arg%d@", i)
+			field.SetString(markers[i])
+		case reflect.Slice:
+			markers[i] = fmt.Sprintf("@arg%d@", i)
+			field.Set(reflect.ValueOf([]string{markers[i]}))
+		case reflect.Map:
+			markers[i] = fmt.Sprintf("@arg%d@", i)
+			field.Set(reflect.ValueOf(map[string]string{"marker": markers[i]}))
+		case reflect.Int, reflect.Int64:
+			markers[i] = fmt.Sprint(1000000 + i)
+			field.SetInt(int64(1000000 + i))
+		case reflect.Float64:
+			markers[i] = fmt.Sprint(1000000 + i)
+			field.SetFloat(float64(1000000 + i))
+		default:
+			return nil, fmt.Errorf("unsupported field type %s", field.Type())
+		}
+	}
+
+	args := v.Interface().(food.Request).Args()
+	order := make([]int, 0, len(markers))
+	used := make(map[int]bool)
+	for _, arg := range args {
+		found := -1
+		for i, marker := range markers {
+			if strings.Contains(arg, marker) {
+				if found >= 0 {
+					return nil, fmt.Errorf("argument %q matches several fields", arg)
+				}
+				found = i
+			}
+		}
+		if found < 0 || used[found] {
+			return nil, fmt.Errorf("argument %q does not match a field", arg)
+		}
+		used[found] = true
+		order = append(order, found)
+	}
+	if len(order) != len(markers) {
+		return nil, fmt.Errorf("only %d of %d fields used by Args", len(order), len(markers))
+	}
+
+	return order, nil
+}
+
+// GET 为只读查询, 其余方法提交交易
+func (r *Route) readOnly() bool {
+	return r.Method == http.MethodGet
+}
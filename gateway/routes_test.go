@@ -0,0 +1,95 @@
+package gateway_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+)
+
+// 记录链码参数的后端
+type argsBackend struct {
+	function string
+	args     []string
+}
+
+func (b *argsBackend) Query(identity, function string, args []string) ([]byte, error) {
+	b.function, b.args = function, args
+	return []byte(`{}`), nil
+}
+
+func (b *argsBackend) Invoke(identity, function string, args []string) ([]byte, error) {
+	b.function, b.args = function, args
+	return nil, nil
+}
+
+// 每个路由的参数都能从请求结构推导出来
+func TestRouteParams(t *testing.T) {
+	for _, route := range gateway.Routes {
+		params, err := route.Params()
+		if err != nil {
+			t.Errorf("%s: %s", route.Function, err)
+			continue
+		}
+		if len(params) == 0 {
+			t.Errorf("%s: no params", route.Function)
+		}
+	}
+}
+
+func TestRouteArgs(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		args   []string
+		code   string
+	}{
+		{"POST", "/facilities", `{"id":"fac1","name":"kitchen","type":"factory","owner_id":"u1","latitude":30.5,"longitude":120,"license":"L1"}`,
+			http.StatusCreated, []string{"fac1", "kitchen", "factory", "u1", "", "30.5", "120", "L1"}, ""},
+		{"POST", "/facilities", `{"id":"fac1","name":"kitchen","facility_type":"factory","owner_id":"u1","license":"L1"}`,
+			http.StatusBadRequest, nil, food.CodeInvalidArgument},
+		{"GET", "/ingredients/rice%2F1/history?query_type=exchange", "",
+			http.StatusOK, []string{"rice/1", "exchange"}, ""},
+		{"POST", "/foods/f1/compose", `{"owner_id":"u1","target_food_id":"f2"}`,
+			http.StatusNoContent, []string{"u1", "f1", "f2"}, ""},
+		{"GET", "/consistency?page_size=10", "",
+			http.StatusOK, []string{"10"}, ""},
+		{"GET", "/consistency?page_size=ten", "",
+			http.StatusBadRequest, nil, food.CodeInvalidArgument},
+		{"POST", "/containers/c1/pack", `{"owner_id":"u1","kind":"food","ids":["f1","f2"]}`,
+			http.StatusNoContent, []string{"u1", "c1", "food", "f1,f2"}, ""},
+		{"POST", "/batches/ingredients", `[{"name":"rice","id":"i1","owner_id":"u1"}]`,
+			http.StatusCreated, []string{`[{"name":"rice","id":"i1","metadata":"","owner_id":"u1","allergens":null,"facility_id":"","certificate_ids":null}]`}, ""},
+	}
+
+	for _, tt := range tests {
+		backend := new(argsBackend)
+		server := gateway.NewServer(backend, gateway.HeaderAuth{})
+		req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader([]byte(tt.body)))
+		req.Header.Set(gateway.IdentityHeader, "admin")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: expected %d, got %d: %s", tt.method, tt.path, tt.status, rec.Code, rec.Body)
+			continue
+		}
+		if tt.code != "" {
+			resp := new(gateway.ErrorResponse)
+			if err := json.Unmarshal(rec.Body.Bytes(), resp); err != nil || resp.Code != tt.code {
+				t.Errorf("%s %s: expected code %s, got %s", tt.method, tt.path, tt.code, rec.Body)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(backend.args, tt.args) {
+			t.Errorf("%s %s: expected args %q, got %q", tt.method, tt.path, tt.args, backend.args)
+		}
+	}
+}
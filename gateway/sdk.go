@@ -0,0 +1,192 @@
+package gateway
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	mspclient "github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+)
+
+// 通过 fabric-sdk-go 执行链码, 与 peer 和 orderer 的连接在进程内保持
+// 每个身份第一次调用时用 MSP 目录中的证书和私钥创建 channel client, 之后的请求复用
+type SDKBackend struct {
+	Channel    string
+	Chaincode  string
+	Identities map[string]*Identity
+
+	sdk     *fabsdk.FabricSDK
+	orgs    map[string]string // MSP id 到连接配置中的组织名
+	mu      sync.Mutex
+	clients map[string]*channel.Client
+}
+
+// profile 为 SDK 的连接配置文件, 其中的组织需要包含身份使用的 MSP
+func NewSDKBackend(profile, channelID, chaincodeID string, identities map[string]*Identity) (*SDKBackend, error) {
+	sdk, err := fabsdk.New(config.FromFile(profile))
+	if err != nil {
+		return nil, fmt.Errorf("create sdk error: %s", err)
+	}
+
+	backends, err := sdk.Config()
+	if err != nil {
+		sdk.Close()
+		return nil, fmt.Errorf("read %s error: %s", profile, err)
+	}
+	endpoints, err := fab.ConfigFromBackend(backends)
+	if err != nil {
+		sdk.Close()
+		return nil, fmt.Errorf("read %s error: %s", profile, err)
+	}
+	orgs := make(map[string]string)
+	for name, org := range endpoints.NetworkConfig().Organizations {
+		orgs[org.MSPID] = name
+	}
+
+	return &SDKBackend{
+		Channel:    channelID,
+		Chaincode:  chaincodeID,
+		Identities: identities,
+		sdk:        sdk,
+		orgs:       orgs,
+		clients:    make(map[string]*channel.Client),
+	}, nil
+}
+
+// 关闭与网络的连接
+func (b *SDKBackend) Close() {
+	b.sdk.Close()
+}
+
+func (b *SDKBackend) Query(identity, function string, args []string) ([]byte, error) {
+	client, err := b.client(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Query(b.request(function, args), channel.WithRetry(retry.DefaultChannelOpts))
+	if err != nil {
+		return nil, sdkError(err)
+	}
+
+	return response.Payload, nil
+}
+
+// 提交交易并等待提交结果
+func (b *SDKBackend) Invoke(identity, function string, args []string) ([]byte, error) {
+	client, err := b.client(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Execute(b.request(function, args), channel.WithRetry(retry.DefaultChannelOpts))
+	if err != nil {
+		return nil, sdkError(err)
+	}
+
+	return response.Payload, nil
+}
+
+func (b *SDKBackend) request(function string, args []string) channel.Request {
+	input := make([][]byte, 0, len(args))
+	for _, arg := range args {
+		input = append(input, []byte(arg))
+	}
+
+	return channel.Request{ChaincodeID: b.Chaincode, Fcn: function, Args: input}
+}
+
+func (b *SDKBackend) client(identity string) (*channel.Client, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if client, ok := b.clients[identity]; ok {
+		return client, nil
+	}
+
+	id, ok := b.Identities[identity]
+	if !ok {
+		return nil, ErrUnknownIdentity
+	}
+	signer, err := b.signingIdentity(id)
+	if err != nil {
+		return nil, fmt.Errorf("load identity %s error: %s", identity, err)
+	}
+	client, err := channel.New(b.sdk.ChannelContext(b.Channel, fabsdk.WithIdentity(signer)))
+	if err != nil {
+		return nil, fmt.Errorf("create channel client for %s error: %s", identity, err)
+	}
+	b.clients[identity] = client
+
+	return client, nil
+}
+
+// 读取 MSP 目录中的签名证书和私钥
+func (b *SDKBackend) signingIdentity(id *Identity) (msp.SigningIdentity, error) {
+	org, ok := b.orgs[id.MSPID]
+	if !ok {
+		return nil, fmt.Errorf("no organization with msp %s in the connection profile", id.MSPID)
+	}
+	if id.MSPConfigPath == "" {
+		return nil, fmt.Errorf("empty msp_path")
+	}
+
+	cert, err := firstFile(filepath.Join(id.MSPConfigPath, "signcerts"))
+	if err != nil {
+		return nil, err
+	}
+	key, err := firstFile(filepath.Join(id.MSPConfigPath, "keystore"))
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := mspclient.New(b.sdk.Context(), mspclient.WithOrg(org))
+	if err != nil {
+		return nil, err
+	}
+
+	return client.CreateSigningIdentity(msp.WithCert(cert), msp.WithPrivateKey(key))
+}
+
+// 目录中的第一个文件, MSP 的 signcerts 和 keystore 中各只有一个文件
+func firstFile(dir string) ([]byte, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if !file.IsDir() {
+			return ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		}
+	}
+
+	return nil, fmt.Errorf("no file in %s", dir)
+}
+
+// 链码返回的错误转为 ChaincodeError, 多个背书节点返回的错误取第一个链码错误
+func sdkError(err error) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	if s.Group == status.ChaincodeStatus {
+		return NewChaincodeError(s.Code, s.Message)
+	}
+	for _, detail := range s.Details {
+		if detailErr, ok := detail.(error); ok {
+			if d, ok := status.FromError(detailErr); ok && d.Group == status.ChaincodeStatus {
+				return NewChaincodeError(d.Code, d.Message)
+			}
+		}
+	}
+
+	return err
+}
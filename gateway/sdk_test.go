@@ -0,0 +1,34 @@
+package gateway
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/multi"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+)
+
+func TestSDKError(t *testing.T) {
+	ccStatus := status.New(status.ChaincodeStatus, 500, `{"code":"NOT_FOUND","message":"user not found","entity_id":"u1"}`, nil)
+	tests := []struct {
+		name string
+		err  error
+		code string
+	}{
+		{"chaincode status", ccStatus, "NOT_FOUND"},
+		{"every endorser failed", multi.Errors{ccStatus, ccStatus}, "NOT_FOUND"},
+		{"transport error", status.New(status.GRPCTransportStatus, 14, "connection refused", nil), ""},
+		{"other error", errors.New("timeout"), ""},
+	}
+
+	for _, tt := range tests {
+		err := sdkError(tt.err)
+		ccErr, ok := err.(*ChaincodeError)
+		switch {
+		case tt.code == "" && ok:
+			t.Errorf("%s: expected a non-chaincode error, got %v", tt.name, err)
+		case tt.code != "" && (!ok || ccErr.Detail == nil || ccErr.Detail.Code != tt.code || ccErr.Status != 500):
+			t.Errorf("%s: expected chaincode error %s, got %v", tt.name, tt.code, err)
+		}
+	}
+}
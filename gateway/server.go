@@ -0,0 +1,271 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+)
+
+const (
+	IdentityHeader = "X-Fabric-Identity"
+
+	maxBodySize = 8 << 20
+)
+
+// 错误响应
+type ErrorResponse struct {
+	Error string `json:"error"`
+	// 链码错误码, 见 food.Code* 常量
+	Code     string             `json:"code,omitempty"`
+	Field    string             `json:"field,omitempty"`
+	EntityId string             `json:"entity_id,omitempty"`
+	Fields   []*food.FieldError `json:"fields,omitempty"`
+}
+
+type Server struct {
+	Backend Backend
+	Auth    Authenticator
+	Routes  []*Route
+	Logger  *log.Logger
+}
+
+func NewServer(backend Backend, auth Authenticator) *Server {
+	return &Server{Backend: backend, Auth: auth, Routes: Routes}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	route, params, allowed := s.lookup(r.Method, path)
+	if route == nil {
+		if len(allowed) != 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeError(w, http.StatusNotFound, "route not found")
+		return
+	}
+
+	identity, err := s.Auth.Authenticate(r)
+	if err != nil {
+		status := http.StatusUnauthorized
+		if err == ErrIdentityMismatch {
+			status = http.StatusForbidden
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
+	args, err := buildArgs(w, r, route, params)
+	if err != nil {
+		resp := &ErrorResponse{Error: err.Error()}
+		if argsErr, ok := err.(*food.ArgsError); ok {
+			resp.Code = food.CodeInvalidArgument
+			resp.Fields = argsErr.Fields
+			if len(argsErr.Fields) == 1 {
+				resp.Field = argsErr.Fields[0].Field
+			}
+		}
+		writeErrorResponse(w, http.StatusBadRequest, resp)
+		return
+	}
+
+	var payload []byte
+	if route.readOnly() {
+		payload, err = s.Backend.Query(identity, route.Function, args)
+	} else {
+		payload, err = s.Backend.Invoke(identity, route.Function, args)
+	}
+	if s.Logger != nil {
+		s.Logger.Printf("%s %s identity=%s function=%s err=%v", r.Method, path, identity, route.Function, err)
+	}
+	if err != nil {
+		status, resp := errorStatus(err)
+		writeErrorResponse(w, status, resp)
+		return
+	}
+
+	status := http.StatusOK
+	if route.Created {
+		status = http.StatusCreated
+	}
+	writePayload(w, status, payload)
+}
+
+// 查找路由, 路径存在但方法不匹配时返回允许的方法
+func (s *Server) lookup(method, path string) (*Route, map[string]string, []string) {
+	allowed := make([]string, 0)
+	for _, route := range s.Routes {
+		params, ok := route.match(path)
+		if !ok {
+			continue
+		}
+		if route.Method == method {
+			return route, params, nil
+		}
+		allowed = append(allowed, route.Method)
+	}
+
+	return nil, nil, allowed
+}
+
+// 路径参数、查询参数和请求体字段合成链码的命名参数, 由请求结构校验并给出位置参数
+func buildArgs(w http.ResponseWriter, r *http.Request, route *Route, params map[string]string) ([]string, error) {
+	req := food.NewRequest(route.Function)
+	if req == nil {
+		return nil, fmt.Errorf("unknown function %s", route.Function)
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			return nil, fmt.Errorf("read body error: %s", err)
+		}
+		body = bytes.TrimSpace(body)
+	}
+
+	fields := make(map[string]json.RawMessage)
+	switch {
+	case route.BodyField != "":
+		if len(body) == 0 {
+			return nil, fmt.Errorf("request body is required")
+		}
+		if !json.Valid(body) {
+			return nil, fmt.Errorf("invalid JSON body")
+		}
+		fields[route.BodyField] = body
+	case len(body) != 0:
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, fmt.Errorf("invalid JSON body: %s", err)
+		}
+	}
+
+	// 查询参数和路径参数按字段类型转换为JSON, 未知字段由请求结构报错
+	types := make(map[string]reflect.Type)
+	t := reflect.TypeOf(req).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		types[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = t.Field(i).Type
+	}
+	for name, values := range r.URL.Query() {
+		fields[name] = stringField(types[name], values[0])
+	}
+	for name, value := range params {
+		fields[name] = stringField(types[name], value)
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := food.DecodeRequest(data, req); err != nil {
+		return nil, err
+	}
+
+	return req.Args(), nil
+}
+
+// 路径或查询参数转换为字段的JSON值, 数组按逗号分隔, 不是数字的数值参数原样作为字符串由请求结构报错
+func stringField(t reflect.Type, value string) json.RawMessage {
+	var v interface{} = value
+	if t != nil {
+		switch paramType(t) {
+		case "array":
+			ids := make([]string, 0)
+			for _, id := range strings.Split(value, ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					ids = append(ids, id)
+				}
+			}
+			v = ids
+		case "integer", "number":
+			var number json.Number
+			if err := json.Unmarshal([]byte(value), &number); err == nil {
+				v = number
+			}
+		}
+	}
+
+	raw, _ := json.Marshal(v)
+	return raw
+}
+
+// 链码错误码映射为 HTTP 状态码
+var codeStatus = map[string]int{
+	food.CodeInvalidArgument:    http.StatusBadRequest,
+	food.CodeNotFound:           http.StatusNotFound,
+	food.CodeAlreadyExists:      http.StatusConflict,
+	food.CodeConflict:           http.StatusConflict,
+	food.CodeOwnerMismatch:      http.StatusUnprocessableEntity,
+	food.CodeFailedPrecondition: http.StatusUnprocessableEntity,
+	food.CodeBatchRejected:      http.StatusUnprocessableEntity,
+	food.CodeForbidden:          http.StatusForbidden,
+	food.CodeFunctionDisabled:   http.StatusForbidden,
+	food.CodeInternal:           http.StatusInternalServerError,
+}
+
+func errorStatus(err error) (int, *ErrorResponse) {
+	if err == ErrUnknownIdentity {
+		return http.StatusUnauthorized, &ErrorResponse{Error: err.Error()}
+	}
+
+	ccErr, ok := err.(*ChaincodeError)
+	if !ok {
+		return http.StatusBadGateway, &ErrorResponse{Error: err.Error()}
+	}
+	if ccErr.Detail == nil {
+		return http.StatusInternalServerError, &ErrorResponse{Error: ccErr.Message}
+	}
+
+	detail := ccErr.Detail
+	status, ok := codeStatus[detail.Code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	return status, &ErrorResponse{
+		Error:    detail.Message,
+		Code:     detail.Code,
+		Field:    detail.Field,
+		EntityId: detail.EntityId,
+		Fields:   detail.Fields,
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeErrorResponse(w, status, &ErrorResponse{Error: message})
+}
+
+func writeErrorResponse(w http.ResponseWriter, status int, resp *ErrorResponse) {
+	body, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+// 链码返回的 JSON 原样输出, 其他内容包装为 JSON 字符串
+// 没有返回值时不输出响应体, 状态码 200 改为 204
+func writePayload(w http.ResponseWriter, status int, payload []byte) {
+	if len(payload) == 0 {
+		if status == http.StatusOK {
+			status = http.StatusNoContent
+		}
+		w.WriteHeader(status)
+		return
+	}
+	if !json.Valid(payload) {
+		payload, _ = json.Marshal(string(payload))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(payload, '\n'))
+}
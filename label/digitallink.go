@@ -0,0 +1,157 @@
+// Package label 为已登记的食品生成GS1 Digital Link链接和二维码标签,
+// 完全离线运行, 不依赖链码或任何在线服务。
+package label
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// GS1 应用标识符
+	aiGTIN   = "01"
+	aiSerial = "21"
+
+	maxSerialLength = 20
+	gtinLength      = 14
+
+	// GS1 AI 21 可用的字符集82
+	serialSymbols = "!\"%&'()*+,-./:;<=>?_"
+)
+
+var (
+	ErrInvalidGTIN     = errors.New("invalid gtin")
+	ErrInvalidSerial   = errors.New("invalid serial")
+	ErrInvalidResolver = errors.New("invalid resolver url")
+)
+
+// queryFood 返回的食品, 只保留生成标签需要的字段
+type Food struct {
+	Name     string `json:"name"`
+	Id       string `json:"id"`
+	Metadata string `json:"metadata"`
+	Serial   string `json:"serial,omitempty"`
+}
+
+// 从 queryFood 的返回结果解析食品
+func ParseFood(foodBytes []byte) (*Food, error) {
+	food := new(Food)
+	if err := json.Unmarshal(foodBytes, food); err != nil {
+		return nil, fmt.Errorf("unmarshal food error: %s", err)
+	}
+	if food.Id == "" {
+		return nil, errors.New("food id is empty")
+	}
+
+	return food, nil
+}
+
+// 食品metadata为JSON对象时读取其中的gtin字段
+func (f *Food) GTIN() string {
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(f.Metadata), &fields); err != nil {
+		return ""
+	}
+	gtin, _ := fields["gtin"].(string)
+
+	return gtin
+}
+
+// 序列号, 未登记序列号时使用食品id
+func (f *Food) SerialNumber() string {
+	if f.Serial != "" {
+		return f.Serial
+	}
+
+	return f.Id
+}
+
+// 校验GTIN-8/12/13/14并补齐为14位
+func NormalizeGTIN(gtin string) (string, error) {
+	gtin = strings.TrimSpace(gtin)
+	switch len(gtin) {
+	case 8, 12, 13, 14:
+	default:
+		return "", ErrInvalidGTIN
+	}
+	for _, r := range gtin {
+		if r < '0' || r > '9' {
+			return "", ErrInvalidGTIN
+		}
+	}
+
+	gtin = strings.Repeat("0", gtinLength-len(gtin)) + gtin
+	if checkDigit(gtin[:gtinLength-1]) != gtin[gtinLength-1] {
+		return "", ErrInvalidGTIN
+	}
+
+	return gtin, nil
+}
+
+// GS1 模10校验位
+func checkDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		// 从右往左奇数位乘3
+		if (len(digits)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
+
+// 序列号是否为1到20个字符集82中的字符
+func ValidSerial(serial string) bool {
+	if serial == "" || len(serial) > maxSerialLength {
+		return false
+	}
+	for _, r := range serial {
+		switch {
+		case r >= '0' && r <= '9', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case strings.ContainsRune(serialSymbols, r):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// 生成 GS1 Digital Link URI, 形如 https://id.example.com/01/09506000134352/21/food1
+func DigitalLink(resolver, gtin, serial string) (string, error) {
+	base, err := url.Parse(resolver)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return "", ErrInvalidResolver
+	}
+
+	gtin, err = NormalizeGTIN(gtin)
+	if err != nil {
+		return "", err
+	}
+
+	if !ValidSerial(serial) {
+		return "", ErrInvalidSerial
+	}
+
+	return fmt.Sprintf("%s/%s/%s/%s/%s",
+		strings.TrimRight(base.String(), "/"),
+		aiGTIN, gtin,
+		aiSerial, url.PathEscape(serial),
+	), nil
+}
+
+// 为食品生成 GS1 Digital Link, 食品metadata中没有gtin时使用 defaultGTIN
+func FoodDigitalLink(resolver string, food *Food, defaultGTIN string) (string, error) {
+	gtin := food.GTIN()
+	if gtin == "" {
+		gtin = defaultGTIN
+	}
+
+	return DigitalLink(resolver, gtin, food.SerialNumber())
+}
@@ -0,0 +1,106 @@
+package label
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeGTIN(t *testing.T) {
+	tests := []struct {
+		gtin     string
+		expected string
+		err      error
+	}{
+		// GS1 文档中的示例 GTIN
+		{"09506000134352", "09506000134352", nil},
+		{"9506000134352", "09506000134352", nil},
+		{" 9506000134352 ", "09506000134352", nil},
+		{"4006381333931", "04006381333931", nil},
+		{"036000291452", "00036000291452", nil},
+		{"96385074", "00000096385074", nil},
+		{"09506000134353", "", ErrInvalidGTIN},
+		{"96385075", "", ErrInvalidGTIN},
+		{"0950600013435A", "", ErrInvalidGTIN},
+		{"950600013435", "", ErrInvalidGTIN},
+		{"0950600013", "", ErrInvalidGTIN},
+		{"", "", ErrInvalidGTIN},
+	}
+
+	for _, tt := range tests {
+		gtin, err := NormalizeGTIN(tt.gtin)
+		if gtin != tt.expected || err != tt.err {
+			t.Errorf("%q: expected %q %v, got %q %v", tt.gtin, tt.expected, tt.err, gtin, err)
+		}
+	}
+}
+
+func TestValidSerial(t *testing.T) {
+	tests := []struct {
+		serial string
+		valid  bool
+	}{
+		{"food1", true},
+		{"SN-0001/A", true},
+		{`!"%&'()*+,-./:;<=>?_`, true},
+		{strings.Repeat("9", 20), true},
+		{strings.Repeat("9", 21), false},
+		{"", false},
+		{"SN 0001", false},
+		{"SN#1", false},
+		{"食品1", false},
+	}
+
+	for _, tt := range tests {
+		if valid := ValidSerial(tt.serial); valid != tt.valid {
+			t.Errorf("%q: expected %v, got %v", tt.serial, tt.valid, valid)
+		}
+	}
+}
+
+func TestDigitalLink(t *testing.T) {
+	tests := []struct {
+		resolver string
+		gtin     string
+		serial   string
+		expected string
+		err      error
+	}{
+		{"https://id.example.com", "9506000134352", "food1", "https://id.example.com/01/09506000134352/21/food1", nil},
+		{"https://id.example.com/", "09506000134352", "food1", "https://id.example.com/01/09506000134352/21/food1", nil},
+		{"https://example.com/dl", "96385074", "SN1", "https://example.com/dl/01/00000096385074/21/SN1", nil},
+		// 序列号中的 / 和 % 需要转义, 否则会被解析为路径分隔符和转义序列
+		{"https://id.example.com", "09506000134352", "A/B%1", "https://id.example.com/01/09506000134352/21/A%2FB%251", nil},
+		{"id.example.com", "09506000134352", "food1", "", ErrInvalidResolver},
+		{"https://id.example.com", "09506000134353", "food1", "", ErrInvalidGTIN},
+		{"https://id.example.com", "09506000134352", "SN 1", "", ErrInvalidSerial},
+	}
+
+	for _, tt := range tests {
+		link, err := DigitalLink(tt.resolver, tt.gtin, tt.serial)
+		if link != tt.expected || err != tt.err {
+			t.Errorf("%s %s %s: expected %q %v, got %q %v", tt.resolver, tt.gtin, tt.serial, tt.expected, tt.err, link, err)
+		}
+	}
+}
+
+func TestFoodDigitalLink(t *testing.T) {
+	tests := []struct {
+		food     string
+		expected string
+	}{
+		{`{"id":"food1","metadata":"{\"gtin\":\"4006381333931\"}","serial":"SN1"}`, "https://id.example.com/01/04006381333931/21/SN1"},
+		// metadata 中没有 gtin 时使用默认 GTIN, 没有序列号时使用食品id
+		{`{"id":"food1","metadata":"plain text"}`, "https://id.example.com/01/09506000134352/21/food1"},
+	}
+
+	for _, tt := range tests {
+		food, err := ParseFood([]byte(tt.food))
+		if err != nil {
+			t.Fatal(err)
+		}
+		link, err := FoodDigitalLink("https://id.example.com", food, "09506000134352")
+		if err != nil || link != tt.expected {
+			t.Errorf("%s: expected %q, got %q %v", tt.food, tt.expected, link, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package label
+
+import (
+	"bytes"
+	"fmt"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+
+	// SVG每个模块的边长
+	svgModuleSize = 8
+)
+
+// 生成PNG二维码, size为图片边长(像素)
+func PNG(content string, size int) ([]byte, error) {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("encode qrcode error: %s", err)
+	}
+
+	return code.PNG(size)
+}
+
+// 生成SVG二维码, 矢量图便于印刷
+func SVG(content string) ([]byte, error) {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("encode qrcode error: %s", err)
+	}
+
+	bitmap := code.Bitmap()
+	dimension := len(bitmap) * svgModuleSize
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		dimension, dimension, dimension, dimension)
+	fmt.Fprintf(buf, `<rect width="%d" height="%d" fill="#ffffff"/>`, dimension, dimension)
+	buf.WriteString(`<path fill="#000000" d="`)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(buf, "M%d %dh%dv%dh-%dz", x*svgModuleSize, y*svgModuleSize, svgModuleSize, svgModuleSize, svgModuleSize)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes(), nil
+}
+
+// 按格式生成二维码图片
+func Image(content, format string, size int) ([]byte, error) {
+	switch format {
+	case FormatPNG:
+		return PNG(content, size)
+	case FormatSVG:
+		return SVG(content)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
@@ -0,0 +1,272 @@
+// Package sdk 用链码自己定义的请求结构调用链码, 返回值解码为链码的数据结构。
+//
+//	c := sdk.New(&sdk.BackendInvoker{Backend: backend, Identity: "org1admin"})
+//	err := c.Execute(&food.IngredientEnrollRequest{Name: "rice", Id: "ingredient1", OwnerId: "user1"}, nil)
+//	history, err := c.QueryIngredientHistory(&food.QueryIngredientHistoryRequest{IngredientId: "ingredient1"})
+//
+// 请求结构和返回结构都定义在链码包中, 参数顺序由链码维护, 调用方不再按位置拼参数。
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/Blockchain-book/Fabric-Food/gateway"
+)
+
+// 链码调用方式, 与 epcis.Invoker 相同
+type Invoker interface {
+	Invoke(function string, args []string) ([]byte, error)
+}
+
+// 支持只读查询的调用方式, 查询不提交交易
+type Querier interface {
+	Query(function string, args []string) ([]byte, error)
+}
+
+// 以指定身份通过网关后端调用
+type BackendInvoker struct {
+	Backend  gateway.Backend
+	Identity string
+}
+
+func (b *BackendInvoker) Invoke(function string, args []string) ([]byte, error) {
+	return b.Backend.Invoke(b.Identity, function, args)
+}
+
+func (b *BackendInvoker) Query(function string, args []string) ([]byte, error) {
+	return b.Backend.Query(b.Identity, function, args)
+}
+
+type Client struct {
+	invoker Invoker
+}
+
+func New(invoker Invoker) *Client {
+	return &Client{invoker: invoker}
+}
+
+// 只读的链码函数
+func readOnly(function string) bool {
+	return strings.HasPrefix(function, "query") || function == "verifyProduct" || function == "checkConsistency"
+}
+
+// 执行请求, 链码有返回值时解码到 result, result 为 nil 时忽略返回值
+func (c *Client) Execute(req food.Request, result interface{}) error {
+	var payload []byte
+	var err error
+	if querier, ok := c.invoker.(Querier); ok && readOnly(req.Function()) {
+		payload, err = querier.Query(req.Function(), req.Args())
+	} else {
+		payload, err = c.invoker.Invoke(req.Function(), req.Args())
+	}
+	if err != nil {
+		return err
+	}
+
+	if result == nil || len(payload) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(payload, result); err != nil {
+		return fmt.Errorf("unmarshal %s result error: %s", req.Function(), err)
+	}
+
+	return nil
+}
+
+func (c *Client) QueryUser(req *food.QueryUserRequest) (*food.User, error) {
+	var user *food.User
+	err := c.Execute(req, &user)
+	return user, err
+}
+
+func (c *Client) QueryIngredient(req *food.QueryIngredientRequest) (*food.Ingredient, error) {
+	var ingredient *food.Ingredient
+	err := c.Execute(req, &ingredient)
+	return ingredient, err
+}
+
+func (c *Client) QueryFood(req *food.QueryFoodRequest) (*food.Food, error) {
+	var f *food.Food
+	err := c.Execute(req, &f)
+	return f, err
+}
+
+func (c *Client) QueryIngredientHistory(req *food.QueryIngredientHistoryRequest) ([]*food.IngredientHistory, error) {
+	var histories []*food.IngredientHistory
+	err := c.Execute(req, &histories)
+	return histories, err
+}
+
+func (c *Client) QueryFoodHistory(req *food.QueryFoodHistoryRequest) ([]*food.FoodHistory, error) {
+	var histories []*food.FoodHistory
+	err := c.Execute(req, &histories)
+	return histories, err
+}
+
+func (c *Client) QueryContainerHistory(req *food.QueryContainerHistoryRequest) ([]*food.ContainerHistory, error) {
+	var histories []*food.ContainerHistory
+	err := c.Execute(req, &histories)
+	return histories, err
+}
+
+func (c *Client) QueryFoodProvenance(req *food.QueryFoodProvenanceRequest) (*food.FoodProvenance, error) {
+	var provenance *food.FoodProvenance
+	err := c.Execute(req, &provenance)
+	return provenance, err
+}
+
+func (c *Client) QueryFoodAllergens(req *food.QueryFoodAllergensRequest) (*food.AllergenLabel, error) {
+	var label *food.AllergenLabel
+	err := c.Execute(req, &label)
+	return label, err
+}
+
+func (c *Client) VerifyProduct(req *food.VerifyProductRequest) (*food.ProductVerification, error) {
+	var verification *food.ProductVerification
+	err := c.Execute(req, &verification)
+	return verification, err
+}
+
+func (c *Client) QueryProcessStep(req *food.QueryProcessStepRequest) (*food.ProcessStep, error) {
+	var step *food.ProcessStep
+	err := c.Execute(req, &step)
+	return step, err
+}
+
+func (c *Client) QueryContainer(req *food.QueryContainerRequest) (*food.ContainerContents, error) {
+	var contents *food.ContainerContents
+	err := c.Execute(req, &contents)
+	return contents, err
+}
+
+func (c *Client) QueryFacility(req *food.QueryFacilityRequest) (*food.Facility, error) {
+	var facility *food.Facility
+	err := c.Execute(req, &facility)
+	return facility, err
+}
+
+func (c *Client) QueryFacilityItems(req *food.QueryFacilityItemsRequest) ([]*food.FacilityVisit, error) {
+	var visits []*food.FacilityVisit
+	err := c.Execute(req, &visits)
+	return visits, err
+}
+
+func (c *Client) QueryCertificate(req *food.QueryCertificateRequest) (*food.Certificate, error) {
+	var certificate *food.Certificate
+	err := c.Execute(req, &certificate)
+	return certificate, err
+}
+
+func (c *Client) QueryCertificates(req *food.QueryCertificatesRequest) ([]*food.Certificate, error) {
+	var certificates []*food.Certificate
+	err := c.Execute(req, &certificates)
+	return certificates, err
+}
+
+// 撤销证书, 返回失去认证的资产键
+func (c *Client) CertificateRevoke(req *food.CertificateRevokeRequest) ([]string, error) {
+	var keys []string
+	err := c.Execute(req, &keys)
+	return keys, err
+}
+
+// 召回食品或解除召回, 返回召回记录
+func (c *Client) FoodRecall(req *food.FoodRecallRequest) (*food.FoodRecall, error) {
+	var recall *food.FoodRecall
+	err := c.Execute(req, &recall)
+	return recall, err
+}
+
+// 转让食品, 需要多方审批时返回待审批的转让申请, 否则返回 nil
+func (c *Client) FoodExchange(req *food.FoodExchangeRequest) (*food.Transfer, error) {
+	var transfer *food.Transfer
+	err := c.Execute(req, &transfer)
+	return transfer, err
+}
+
+func (c *Client) TransferApprove(req *food.TransferApproveRequest) (*food.Transfer, error) {
+	var transfer *food.Transfer
+	err := c.Execute(req, &transfer)
+	return transfer, err
+}
+
+func (c *Client) QueryTransfer(req *food.QueryTransferRequest) (*food.Transfer, error) {
+	var transfer *food.Transfer
+	err := c.Execute(req, &transfer)
+	return transfer, err
+}
+
+func (c *Client) QueryTransfers(req *food.QueryTransfersRequest) ([]*food.Transfer, error) {
+	var transfers []*food.Transfer
+	err := c.Execute(req, &transfers)
+	return transfers, err
+}
+
+func (c *Client) QueryEndorsement(req *food.QueryEndorsementRequest) (*food.KeyEndorsement, error) {
+	var endorsement *food.KeyEndorsement
+	err := c.Execute(req, &endorsement)
+	return endorsement, err
+}
+
+func (c *Client) IngredientEnrollBatch(req *food.IngredientEnrollBatchRequest) ([]*food.BatchResult, error) {
+	var results []*food.BatchResult
+	err := c.Execute(req, &results)
+	return results, err
+}
+
+func (c *Client) FoodEnrollBatch(req *food.FoodEnrollBatchRequest) ([]*food.BatchResult, error) {
+	var results []*food.BatchResult
+	err := c.Execute(req, &results)
+	return results, err
+}
+
+func (c *Client) IngredientExchangeBatch(req *food.IngredientExchangeBatchRequest) ([]*food.BatchResult, error) {
+	var results []*food.BatchResult
+	err := c.Execute(req, &results)
+	return results, err
+}
+
+func (c *Client) QueryConfig(req *food.QueryConfigRequest) (*food.Config, error) {
+	var cfg *food.Config
+	err := c.Execute(req, &cfg)
+	return cfg, err
+}
+
+func (c *Client) Migrate(req *food.MigrateRequest) (*food.MigrateResult, error) {
+	var result *food.MigrateResult
+	err := c.Execute(req, &result)
+	return result, err
+}
+
+func (c *Client) CheckConsistency(req *food.CheckConsistencyRequest) (*food.ConsistencyReport, error) {
+	var report *food.ConsistencyReport
+	err := c.Execute(req, &report)
+	return report, err
+}
+
+func (c *Client) QueryRepairs(req *food.QueryRepairsRequest) ([]*food.RepairRecord, error) {
+	var records []*food.RepairRecord
+	err := c.Execute(req, &records)
+	return records, err
+}
+
+func (c *Client) RepairOrphan(req *food.RepairOrphanRequest) (*food.RepairRecord, error) {
+	var record *food.RepairRecord
+	err := c.Execute(req, &record)
+	return record, err
+}
+
+func (c *Client) RepairReference(req *food.RepairReferenceRequest) (*food.RepairRecord, error) {
+	var record *food.RepairRecord
+	err := c.Execute(req, &record)
+	return record, err
+}
+
+func (c *Client) RepairHoldings(req *food.RepairHoldingsRequest) (*food.RepairRecord, error) {
+	var record *food.RepairRecord
+	err := c.Execute(req, &record)
+	return record, err
+}
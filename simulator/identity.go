@@ -0,0 +1,106 @@
+package simulator
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/Blockchain-book/Fabric-Food/backend"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/msp"
+)
+
+// Fabric CA 写入证书属性的扩展
+var attributesOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// 每个MSP一个测试CA, 身份证书由CA签发
+// 证书的主题和颁发者只由身份名和MSP决定, 重新生成后 cid.GetID 不变
+type certAuthority struct {
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newCertAuthority(mspID string) (*certAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca." + mspID, Organization: []string{mspID}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &certAuthority{key: key, cert: cert}, nil
+}
+
+// 签发身份证书并序列化为 peer 交给链码的创建者身份
+func (ca *certAuthority) issue(name string, identity *backend.Identity) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name, Organization: []string{identity.MSPID}, OrganizationalUnit: []string{"client"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if len(identity.Attributes) != 0 {
+		value, err := json.Marshal(map[string]map[string]string{"attrs": identity.Attributes})
+		if err != nil {
+			return nil, err
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: attributesOID, Value: value}}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	return proto.Marshal(&msp.SerializedIdentity{Mspid: identity.MSPID, IdBytes: certPEM})
+}
+
+// 为身份生成证书, 同一MSP的身份共用一个CA
+func (s *Simulator) newCreator(name string, identity *backend.Identity) ([]byte, error) {
+	if identity.MSPID == "" {
+		return nil, fmt.Errorf("empty msp id")
+	}
+
+	ca, ok := s.cas[identity.MSPID]
+	if !ok {
+		var err error
+		ca, err = newCertAuthority(identity.MSPID)
+		if err != nil {
+			return nil, err
+		}
+		s.cas[identity.MSPID] = ca
+	}
+
+	return ca.issue(name, identity)
+}
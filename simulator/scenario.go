@@ -0,0 +1,376 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/Blockchain-book/Fabric-Food/backend"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// 用 YAML 编写的场景, 按顺序执行每一步并检查期望的结果
+//
+//	config: {admin_msps: [Org1MSP], approval_policies: {frozen: {required: 1, roles: [qa]}}}
+//	start: 2024-01-01T08:00:00Z
+//	identities:
+//	  admin: {msp_id: Org1MSP}
+//	  qa: {msp_id: QAMSP, attrs: {role: qa}}
+//	steps:
+//	  - name: register alice
+//	    as: admin
+//	    invoke: userRegister
+//	    args: {name: alice, id: user1}
+//	  - invoke: userRegister
+//	    args: [alice, user1]
+//	    expect: {code: ALREADY_EXISTS}
+//	  - advance: 48h
+//	  - query: queryUser
+//	    args: {id: user1}
+//	    expect: {payload: {name: alice}}
+//
+// args 为对象时以命名参数调用, 为列表时按位置传参; as 省略时沿用上一步的身份, 第一步默认取第一个身份。
+// 交易id依次为 tx1, tx2, ..., 查询也占用交易id, 转让申请等以交易id为编号的数据可以直接引用。
+type Scenario struct {
+	// Init 时写入的链码配置, 只用于新的模拟账本
+	Config interface{} `yaml:"config"`
+	// 模拟时钟的起点和每个交易的步长, 只用于新的模拟账本
+	Start      string                       `yaml:"start"`
+	Step       string                       `yaml:"step"`
+	Identities map[string]*ScenarioIdentity `yaml:"identities"`
+	Steps      []*ScenarioStep              `yaml:"steps"`
+}
+
+type ScenarioIdentity struct {
+	MSPID      string            `yaml:"msp_id"`
+	Attributes map[string]string `yaml:"attrs"`
+}
+
+// 场景的一步, invoke、query、advance 三选一
+type ScenarioStep struct {
+	Name    string      `yaml:"name"`
+	As      string      `yaml:"as"`
+	Invoke  string      `yaml:"invoke"`
+	Query   string      `yaml:"query"`
+	Args    interface{} `yaml:"args"`
+	Advance string      `yaml:"advance"`
+	Expect  *Expect     `yaml:"expect"`
+}
+
+// 期望的结果, 省略时期望执行成功
+type Expect struct {
+	// 期望的错误码, 为空表示期望成功
+	Code string `yaml:"code"`
+	// 返回值中应包含的字段, 对象只比较列出的字段, 数组逐个比较
+	Payload interface{} `yaml:"payload"`
+}
+
+// 场景的执行报告
+type Report struct {
+	Passed bool          `json:"passed"`
+	Steps  []*StepReport `json:"steps"`
+}
+
+type StepReport struct {
+	Step      int             `json:"step"`
+	Name      string          `json:"name,omitempty"`
+	Function  string          `json:"function,omitempty"`
+	Identity  string          `json:"identity,omitempty"`
+	TxId      string          `json:"tx_id,omitempty"`
+	Block     uint64          `json:"block,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+	Code      string          `json:"code,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Events    []*Event        `json:"events,omitempty"`
+	Passed    bool            `json:"passed"`
+	Failure   string          `json:"failure,omitempty"`
+}
+
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseScenario(data)
+}
+
+func ParseScenario(data []byte) (*Scenario, error) {
+	scenario := new(Scenario)
+	if err := yaml.UnmarshalStrict(data, scenario); err != nil {
+		return nil, fmt.Errorf("parse scenario error: %s", err)
+	}
+
+	for i, step := range scenario.Steps {
+		actions := 0
+		for _, action := range []string{step.Invoke, step.Query, step.Advance} {
+			if action != "" {
+				actions++
+			}
+		}
+		if actions != 1 {
+			return nil, fmt.Errorf("step %d: expected exactly one of invoke, query, advance", i+1)
+		}
+		if step.Advance != "" {
+			if _, err := time.ParseDuration(step.Advance); err != nil {
+				return nil, fmt.Errorf("step %d: invalid advance %s", i+1, step.Advance)
+			}
+		}
+		if step.As != "" && scenario.Identities[step.As] == nil {
+			return nil, fmt.Errorf("step %d: unknown identity %s", i+1, step.As)
+		}
+	}
+
+	return scenario, nil
+}
+
+// 以场景的配置和时钟创建新的模拟账本
+func (sc *Scenario) NewSimulator() (*Simulator, error) {
+	config := ""
+	if sc.Config != nil {
+		configBytes, err := json.Marshal(jsonValue(sc.Config))
+		if err != nil {
+			return nil, fmt.Errorf("marshal config error: %s", err)
+		}
+		config = string(configBytes)
+	}
+
+	var start time.Time
+	if sc.Start != "" {
+		var err error
+		if start, err = time.Parse(time.RFC3339, sc.Start); err != nil {
+			return nil, fmt.Errorf("invalid start %s", sc.Start)
+		}
+	}
+
+	s, err := New(config, start)
+	if err != nil {
+		return nil, err
+	}
+	if sc.Step != "" {
+		if s.Step, err = time.ParseDuration(sc.Step); err != nil {
+			return nil, fmt.Errorf("invalid step %s", sc.Step)
+		}
+	}
+
+	return s, nil
+}
+
+// 在模拟账本上执行场景, 某一步不符合期望时继续执行后面的步骤
+func (s *Simulator) Run(sc *Scenario) (*Report, error) {
+	names := make([]string, 0, len(sc.Identities))
+	for name := range sc.Identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		identity := sc.Identities[name]
+		if err := s.AddIdentity(name, &backend.Identity{MSPID: identity.MSPID, Attributes: identity.Attributes}); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &Report{Passed: true, Steps: make([]*StepReport, 0, len(sc.Steps))}
+	identity := ""
+	if len(names) != 0 {
+		identity = names[0]
+	}
+	for i, step := range sc.Steps {
+		if step.As != "" {
+			identity = step.As
+		}
+
+		stepReport, err := s.runStep(step, identity)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %s", i+1, err)
+		}
+		stepReport.Step = i + 1
+		report.Steps = append(report.Steps, stepReport)
+		report.Passed = report.Passed && stepReport.Passed
+	}
+
+	return report, nil
+}
+
+func (s *Simulator) runStep(step *ScenarioStep, identity string) (*StepReport, error) {
+	report := &StepReport{Name: step.Name, Passed: true}
+	if step.Advance != "" {
+		d, _ := time.ParseDuration(step.Advance)
+		s.Advance(d)
+		report.Timestamp = s.Now()
+		return report, nil
+	}
+
+	function, commit := step.Invoke, true
+	if function == "" {
+		function, commit = step.Query, false
+	}
+	args, err := stepArgs(step.Args)
+	if err != nil {
+		return nil, err
+	}
+	report.Function = function
+	report.Identity = identity
+
+	result, err := s.Execute(identity, function, args, commit)
+	if result == nil {
+		return nil, err
+	}
+	report.TxId = result.TxId
+	report.Block = result.Block
+	report.Timestamp = result.Timestamp
+	report.Events = result.Events
+	if len(result.Payload) != 0 {
+		report.Payload = json.RawMessage(result.Payload)
+	}
+
+	if err != nil {
+		report.Error = err.Error()
+		if ccErr, ok := err.(*backend.ChaincodeError); ok {
+			report.Error = ccErr.Message
+			if ccErr.Detail != nil {
+				report.Code = ccErr.Detail.Code
+			}
+		}
+	}
+
+	if failure := checkExpect(step.Expect, report, result.Payload); failure != "" {
+		report.Passed = false
+		report.Failure = failure
+	}
+
+	return report, nil
+}
+
+// args 为对象时转为命名参数, 为列表时按位置传参
+func stepArgs(args interface{}) ([]string, error) {
+	switch v := args.(type) {
+	case nil:
+		return []string{}, nil
+	case map[interface{}]interface{}:
+		argBytes, err := json.Marshal(jsonValue(v))
+		if err != nil {
+			return nil, fmt.Errorf("marshal args error: %s", err)
+		}
+		return []string{string(argBytes)}, nil
+	case []interface{}:
+		positional := make([]string, 0, len(v))
+		for _, arg := range v {
+			switch a := arg.(type) {
+			case nil:
+				positional = append(positional, "")
+			case string:
+				positional = append(positional, a)
+			case map[interface{}]interface{}, []interface{}:
+				argBytes, err := json.Marshal(jsonValue(a))
+				if err != nil {
+					return nil, fmt.Errorf("marshal args error: %s", err)
+				}
+				positional = append(positional, string(argBytes))
+			default:
+				positional = append(positional, fmt.Sprint(a))
+			}
+		}
+		return positional, nil
+	default:
+		return nil, fmt.Errorf("args must be an object or a list")
+	}
+}
+
+func checkExpect(expect *Expect, report *StepReport, payload []byte) string {
+	code := ""
+	if expect != nil {
+		code = expect.Code
+	}
+	if report.Code != code || (code == "" && report.Error != "") {
+		if code == "" {
+			return fmt.Sprintf("expected success, got %s", report.Error)
+		}
+		return fmt.Sprintf("expected %s, got %s", code, report.Code)
+	}
+
+	if expect == nil || expect.Payload == nil {
+		return ""
+	}
+
+	// 期望值和返回值都按JSON解码后比较, 数值统一为 float64
+	expectedBytes, err := json.Marshal(jsonValue(expect.Payload))
+	if err != nil {
+		return fmt.Sprintf("marshal expected payload error: %s", err)
+	}
+	var expected, actual interface{}
+	json.Unmarshal(expectedBytes, &expected)
+	if err := json.Unmarshal(payload, &actual); err != nil {
+		return "payload is not json"
+	}
+	return contains(actual, expected, "payload")
+}
+
+// actual 是否包含 expected, 不包含时说明第一个不一致的位置
+func contains(actual, expected interface{}, path string) string {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return mismatch(actual, expected, path)
+		}
+		keys := make([]string, 0, len(e))
+		for key := range e {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if failure := contains(a[key], e[key], path+"."+key); failure != "" {
+				return failure
+			}
+		}
+		return ""
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return mismatch(actual, expected, path)
+		}
+		for i := range e {
+			if failure := contains(a[i], e[i], fmt.Sprintf("%s[%d]", path, i)); failure != "" {
+				return failure
+			}
+		}
+		return ""
+	default:
+		if !reflect.DeepEqual(actual, expected) {
+			return mismatch(actual, expected, path)
+		}
+		return ""
+	}
+}
+
+func mismatch(actual, expected interface{}, path string) string {
+	actualBytes, _ := json.Marshal(actual)
+	expectedBytes, _ := json.Marshal(expected)
+
+	return fmt.Sprintf("%s: expected %s, got %s", path, expectedBytes, actualBytes)
+}
+
+// yaml 解码的 map[interface{}]interface{} 转为可以编码为JSON的值
+func jsonValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			object[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(value))
+		for i, item := range value {
+			array[i] = jsonValue(item)
+		}
+		return array
+	default:
+		return value
+	}
+}
@@ -0,0 +1,30 @@
+package simulator_test
+
+import (
+	"testing"
+
+	"github.com/Blockchain-book/Fabric-Food/simulator"
+)
+
+func TestScenario(t *testing.T) {
+	for _, path := range []string{"testdata/frozen_transfer.yaml"} {
+		sc, err := simulator.LoadScenario(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sim, err := sc.NewSimulator()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := sim.Run(sc)
+		if err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		for _, step := range report.Steps {
+			if !step.Passed {
+				t.Errorf("%s step %d %s: %s", path, step.Step, step.Function, step.Failure)
+			}
+		}
+	}
+}
@@ -0,0 +1,338 @@
+// Package simulator 在进程内运行完整的食品溯源链码, 不需要 Docker 和 peer 节点。
+//
+//	sim, err := simulator.Open("state.json", `{"admin_msps":["Org1MSP"]}`)
+//	err = sim.AddIdentity("admin", &backend.Identity{MSPID: "Org1MSP"})
+//	payload, err := sim.Invoke("admin", "userRegister", []string{"alice", "user1"})
+//	err = sim.Save("state.json")
+//
+// 在 MockStub 的基础上:
+//   - 世界状态、键的历史、交易计数和模拟时钟保存在文件中, 多次运行之间延续
+//   - 交易时间取自模拟时钟, 每个提交的交易是一个区块, 时钟前进 Step, 也可以用 Advance 跳过一段时间
+//   - 每个MSP生成一个测试CA, 身份证书由CA签发, 链码通过 cid 读到的 MSP 和属性与真实网络一致
+//   - 支持 GetHistoryForKey, 只返回已提交的修改
+//
+// Simulator 实现了 gateway.Backend, 可以直接交给 REST 网关、sdk 和 foodctl 使用。
+// 用 YAML 编写的场景见 Scenario。
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Blockchain-book/Fabric-Food/backend"
+	"github.com/Blockchain-book/Fabric-Food/chaincode/food"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	// 每个交易默认让时钟前进一秒
+	defaultStep = time.Second
+	// 实例化链码的 peer 管理员所属的MSP
+	initMSP = "SimulatorMSP"
+)
+
+type Simulator struct {
+	mu         sync.Mutex
+	cc         shim.Chaincode
+	Stub       *shim.MockStub
+	history    map[string][]*Modification
+	identities map[string]*backend.Identity
+	creators   map[string][]byte
+	cas        map[string]*certAuthority
+	clock      time.Time
+	block      uint64
+	tx         int
+	// 每个提交的交易之后时钟前进的时间
+	Step time.Duration
+}
+
+// 交易的执行结果
+type Result struct {
+	TxId      string
+	Block     uint64 // 提交所在的区块, 查询和失败的交易为 0
+	Timestamp time.Time
+	Payload   []byte
+	Events    []*Event
+}
+
+// 保存到文件的模拟账本
+// 链码写入的值都是文本, 以字符串保存便于查看; 背书策略为二进制
+type State struct {
+	Clock      time.Time                    `json:"clock"`
+	Step       string                       `json:"step"`
+	Block      uint64                       `json:"block"`
+	Tx         int                          `json:"tx"`
+	World      map[string]string            `json:"world"`
+	History    map[string][]*Modification   `json:"history"`
+	Policies   map[string][]byte            `json:"policies,omitempty"`
+	Identities map[string]*backend.Identity `json:"identities,omitempty"`
+}
+
+func newSimulator(start time.Time) *Simulator {
+	cc := new(food.IngredientsExchangeCC)
+	return &Simulator{
+		cc:         cc,
+		Stub:       shim.NewMockStub("food", cc),
+		history:    make(map[string][]*Modification),
+		identities: make(map[string]*backend.Identity),
+		creators:   make(map[string][]byte),
+		cas:        make(map[string]*certAuthority),
+		clock:      start.UTC(),
+		Step:       defaultStep,
+	}
+}
+
+// 新的模拟账本, config 为 Init 时写入的链码配置, 可以为空
+// 时钟从 start 开始, 为零值时取当前时间
+func New(config string, start time.Time) (*Simulator, error) {
+	if start.IsZero() {
+		start = time.Now().Truncate(time.Second)
+	}
+
+	s := newSimulator(start)
+	creator, err := s.newCreator("init", &backend.Identity{MSPID: initMSP})
+	if err != nil {
+		return nil, fmt.Errorf("create init identity error: %s", err)
+	}
+	if _, err := s.execute(creator, [][]byte{[]byte("init"), []byte(config)}, true, true); err != nil {
+		return nil, fmt.Errorf("init chaincode error: %s", err)
+	}
+
+	return s, nil
+}
+
+// 从文件恢复模拟账本
+func Load(path string) (*Simulator, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	state := new(State)
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("unmarshal %s error: %s", path, err)
+	}
+
+	s := newSimulator(state.Clock)
+	s.block = state.Block
+	s.tx = state.Tx
+	if state.Step != "" {
+		if s.Step, err = time.ParseDuration(state.Step); err != nil {
+			return nil, fmt.Errorf("invalid step %s", state.Step)
+		}
+	}
+
+	// 范围查询依赖有序的键列表, 逐个写入由 MockStub 维护
+	keys := make([]string, 0, len(state.World))
+	for key := range state.World {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	s.Stub.MockTransactionStart("load")
+	for _, key := range keys {
+		s.Stub.PutState(key, []byte(state.World[key]))
+	}
+	for key, ep := range state.Policies {
+		s.Stub.SetStateValidationParameter(key, ep)
+	}
+	s.Stub.MockTransactionEnd("load")
+
+	for key, modifications := range state.History {
+		s.history[key] = modifications
+	}
+
+	names := make([]string, 0, len(state.Identities))
+	for name := range state.Identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := s.AddIdentity(name, state.Identities[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// 文件存在时恢复, 否则以 config 初始化新的模拟账本
+func Open(path, config string) (*Simulator, error) {
+	s, err := Load(path)
+	if os.IsNotExist(err) {
+		return New(config, time.Time{})
+	}
+
+	return s, err
+}
+
+func (s *Simulator) state() *State {
+	state := &State{
+		Clock:      s.clock,
+		Step:       s.Step.String(),
+		Block:      s.block,
+		Tx:         s.tx,
+		World:      make(map[string]string, len(s.Stub.State)),
+		History:    s.history,
+		Policies:   make(map[string][]byte),
+		Identities: s.identities,
+	}
+	for key, value := range s.Stub.State {
+		state.World[key] = string(value)
+	}
+	for key, ep := range s.Stub.EndorsementPolicies[""] {
+		state.Policies[key] = ep
+	}
+
+	return state
+}
+
+// 保存到文件, 先写临时文件再改名, 中途失败不会损坏原有状态
+func (s *Simulator) Save(path string) error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s.state(), "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// 添加调用身份, 同名身份会被替换
+func (s *Simulator) AddIdentity(name string, identity *backend.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	creator, err := s.newCreator(name, identity)
+	if err != nil {
+		return fmt.Errorf("create identity %s error: %s", name, err)
+	}
+	s.identities[name] = identity
+	s.creators[name] = creator
+
+	return nil
+}
+
+// 当前模拟时间, 即下一个交易的时间
+func (s *Simulator) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.clock
+}
+
+// 时钟前进一段时间, 例如让转让申请过期
+func (s *Simulator) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clock = s.clock.Add(d)
+}
+
+// 键的全部已提交修改
+func (s *Simulator) History(key string) []*Modification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*Modification(nil), s.history[key]...)
+}
+
+func (s *Simulator) Query(identity, function string, args []string) ([]byte, error) {
+	result, err := s.Execute(identity, function, args, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Payload, nil
+}
+
+func (s *Simulator) Invoke(identity, function string, args []string) ([]byte, error) {
+	result, err := s.Execute(identity, function, args, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Payload, nil
+}
+
+// 以身份执行链码函数, commit 为 false 时只查询不提交
+// 链码返回错误时为 *backend.ChaincodeError, Result 仍带有交易id和时间
+func (s *Simulator) Execute(identity, function string, args []string, commit bool) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	creator, ok := s.creators[identity]
+	if !ok {
+		return nil, backend.ErrUnknownIdentity
+	}
+
+	input := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		input = append(input, []byte(arg))
+	}
+
+	return s.execute(creator, input, commit, false)
+}
+
+func (s *Simulator) execute(creator []byte, input [][]byte, commit, init bool) (*Result, error) {
+	txID := "init"
+	if !init {
+		s.tx++
+		txID = fmt.Sprintf("tx%d", s.tx)
+	}
+
+	stub := &txStub{
+		MockStub:  s.Stub,
+		sim:       s,
+		creator:   creator,
+		args:      input,
+		timestamp: s.clock,
+		writes:    make(map[string][]byte),
+		policies:  make(map[string][]byte),
+	}
+	result := &Result{TxId: txID, Timestamp: s.clock}
+
+	s.Stub.MockTransactionStart(txID)
+	var response pb.Response
+	if init {
+		response = s.cc.Init(stub)
+	} else {
+		response = s.cc.Invoke(stub)
+	}
+	if response.Status == shim.OK && commit {
+		stub.commit()
+		s.block++
+		s.clock = s.clock.Add(s.Step)
+		result.Block = s.block
+		result.Events = stub.events
+	}
+	s.Stub.MockTransactionEnd(txID)
+
+	if response.Status != shim.OK {
+		return result, backend.NewChaincodeError(response.Status, response.Message)
+	}
+	result.Payload = response.Payload
+
+	return result, nil
+}
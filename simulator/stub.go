@@ -0,0 +1,172 @@
+package simulator
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+)
+
+// 链码发出的事件
+type Event struct {
+	Name    string `json:"name"`
+	Payload string `json:"payload,omitempty"`
+}
+
+// 单个交易的执行环境, 在 MockStub 上补充创建者、模拟时间和键的历史
+// 与 peer 一样, 交易内读不到自己的写入, 写集在链码成功返回后才提交
+type txStub struct {
+	*shim.MockStub
+	sim       *Simulator
+	creator   []byte
+	args      [][]byte
+	timestamp time.Time
+	writes    map[string][]byte // nil 表示删除
+	policies  map[string][]byte
+	events    []*Event
+}
+
+func (s *txStub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+func (s *txStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *txStub) GetStringArgs() []string {
+	args := make([]string, 0, len(s.args))
+	for _, arg := range s.args {
+		args = append(args, string(arg))
+	}
+
+	return args
+}
+
+func (s *txStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+
+	return args[0], args[1:]
+}
+
+func (s *txStub) GetArgsSlice() ([]byte, error) {
+	args := make([]byte, 0)
+	for _, arg := range s.args {
+		args = append(args, arg...)
+	}
+
+	return args, nil
+}
+
+func (s *txStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: s.timestamp.Unix(), Nanos: int32(s.timestamp.Nanosecond())}, nil
+}
+
+func (s *txStub) PutState(key string, value []byte) error {
+	if key == "" {
+		return fmt.Errorf("key must not be an empty string")
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	s.writes[key] = value
+
+	return nil
+}
+
+func (s *txStub) DelState(key string) error {
+	s.writes[key] = nil
+
+	return nil
+}
+
+func (s *txStub) SetStateValidationParameter(key string, ep []byte) error {
+	s.policies[key] = ep
+
+	return nil
+}
+
+func (s *txStub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return fmt.Errorf("event name can not be nil string")
+	}
+	s.events = append(s.events, &Event{Name: name, Payload: string(payload)})
+
+	return nil
+}
+
+// 键的历史只包含已提交的交易, 按提交顺序返回
+func (s *txStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &historyIterator{modifications: s.sim.history[key]}, nil
+}
+
+// 按键排序提交写集, 同时记录每个键的历史
+func (s *txStub) commit() {
+	keys := make([]string, 0, len(s.writes))
+	for key := range s.writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := s.writes[key]
+		if value != nil {
+			s.MockStub.PutState(key, value)
+		} else {
+			s.MockStub.DelState(key)
+		}
+		s.sim.history[key] = append(s.sim.history[key], &Modification{
+			TxId:      s.TxID,
+			Value:     string(value),
+			Timestamp: s.timestamp,
+			IsDelete:  value == nil,
+		})
+	}
+	for key, ep := range s.policies {
+		s.MockStub.SetStateValidationParameter(key, ep)
+	}
+}
+
+var _ shim.ChaincodeStubInterface = (*txStub)(nil)
+
+// 键的一次修改
+type Modification struct {
+	TxId      string    `json:"tx_id"`
+	Value     string    `json:"value,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"is_delete,omitempty"`
+}
+
+type historyIterator struct {
+	modifications []*Modification
+	next          int
+}
+
+func (it *historyIterator) HasNext() bool {
+	return it.next < len(it.modifications)
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if !it.HasNext() {
+		return nil, fmt.Errorf("no more history")
+	}
+	m := it.modifications[it.next]
+	it.next++
+
+	return &queryresult.KeyModification{
+		TxId:      m.TxId,
+		Value:     []byte(m.Value),
+		Timestamp: &timestamp.Timestamp{Seconds: m.Timestamp.Unix(), Nanos: int32(m.Timestamp.Nanosecond())},
+		IsDelete:  m.IsDelete,
+	}, nil
+}
+
+func (it *historyIterator) Close() error {
+	return nil
+}